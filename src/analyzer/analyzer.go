@@ -2,12 +2,16 @@ package analyzer
 
 import (
 	"base"
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"logging"
 	"middleware"
 	"net/http"
 	"net/url"
+	"runtime/debug"
+	"time"
 )
 
 var logger logging.Logger = base.NewLogger()
@@ -23,12 +27,25 @@ type Analyzer interface {
 	Analyze(respParses []ParseResponse, resp base.Response) ([]base.Data, []error)
 }
 
+// 单个响应解析函数的默认执行时限。
+const DEFAULT_PARSER_TIMEOUT = 30 * time.Second
+
 type myAnalyzer struct {
-	id uint32
+	id            uint32
+	parserTimeout time.Duration //单个响应解析函数的执行时限
 }
 
 func NewAnalyzer() Analyzer {
-	return &myAnalyzer{id: genAnalyzerId()}
+	return NewAnalyzerWithTimeout(DEFAULT_PARSER_TIMEOUT)
+}
+
+// 创建分析器。参数parserTimeout为单个响应解析函数的执行时限，不大于0时使用默认时限。
+// 超时的响应解析函数会被放弃，其结果会被丢弃。
+func NewAnalyzerWithTimeout(parserTimeout time.Duration) Analyzer {
+	if parserTimeout <= 0 {
+		parserTimeout = DEFAULT_PARSER_TIMEOUT
+	}
+	return &myAnalyzer{id: genAnalyzerId(), parserTimeout: parserTimeout}
 }
 
 func genAnalyzerId() uint32 {
@@ -55,16 +72,49 @@ func (analyzer *myAnalyzer) Analyze(
 	logger.Infof("Parse the response (reqUrl=%s)... \n", reqUrl)
 	respDepth := resp.Depth()
 
+	// 读取HTTP响应体，使每个响应解析函数都能获得完整的响应体。
+	body, err := readBody(httpResp)
+	if err != nil {
+		return nil, []error{err}
+	}
+
 	// 解析HTTP响应。
-	dataList = make([]base.Data, 0)
-	errorList = make([]error, 0)
+	dataList, errorList = runParsers(respParsers, nil, httpResp, body, respDepth, analyzer.parserTimeout)
+	return dataList, errorList
+}
+
+// 读取并关闭HTTP响应体。
+func readBody(httpResp *http.Response) ([]byte, error) {
+	if httpResp.Body == nil {
+		return nil, nil
+	}
+	defer httpResp.Body.Close()
+	return ioutil.ReadAll(httpResp.Body)
+}
+
+// 依次使用各个响应解析函数解析HTTP响应。每个响应解析函数都会获得一个从头读起的响应体。
+// 参数routes为各个响应解析函数所属的路由规则的名称，为nil表示不经路由器，此时错误中带有响应解析函数的序号。
+// 参数timeout为单个响应解析函数的执行时限，不大于0表示不限时。
+func runParsers(
+	respParsers []ParseResponse,
+	routes []string,
+	httpResp *http.Response,
+	body []byte,
+	respDepth uint32,
+	timeout time.Duration) ([]base.Data, []error) {
+	dataList := make([]base.Data, 0)
+	errorList := make([]error, 0)
 	for i, respParser := range respParsers {
 		if respParser == nil {
 			err := errors.New(fmt.Sprintf("The document parser [%d] is invalid!", i))
 			errorList = append(errorList, err)
 			continue
 		}
-		pDataList, pErrorList := respParser(httpResp, respDepth)
+		route := ""
+		if routes != nil {
+			route = routes[i]
+		}
+		pDataList, pErrorList := runParser(i, route, respParser, httpResp, body, respDepth, timeout)
 		if pDataList != nil {
 			for _, pData := range pDataList {
 				dataList = appendDataList(dataList, pData, respDepth)
@@ -79,6 +129,67 @@ func (analyzer *myAnalyzer) Analyze(
 	return dataList, errorList
 }
 
+// 响应解析函数的结果。
+type parseResult struct {
+	dataList  []base.Data
+	errorList []error
+}
+
+// 在隔离的环境中执行响应解析函数。
+// 响应解析函数中发生的运行时恐慌以及超时都会被转换为分析器错误，而不会影响其他响应解析函数。
+func runParser(
+	index int,
+	route string,
+	respParser ParseResponse,
+	httpResp *http.Response,
+	body []byte,
+	respDepth uint32,
+	timeout time.Duration) ([]base.Data, []error) {
+	// 每个响应解析函数都使用HTTP响应的副本，以免超时后仍在执行的函数与后续的函数相互干扰。
+	// 响应头会被某些响应解析函数（如语言过滤器）修改，因此也需要复制。
+	resp := *httpResp
+	resp.Header = httpResp.Header.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	reqUrl := httpResp.Request.URL.String()
+	call := func() (result parseResult) {
+		defer func() {
+			if p := recover(); p != nil {
+				errMsg := fmt.Sprintf("Parser panic: %v", p)
+				err := newParserError(index, route, reqUrl, errMsg)
+				logger.Errorf("%s\n%s", err, debug.Stack())
+				result = parseResult{errorList: []error{err}}
+			}
+		}()
+		dataList, errorList := respParser(&resp, respDepth)
+		return parseResult{dataList: dataList, errorList: errorList}
+	}
+	if timeout <= 0 {
+		result := call()
+		return result.dataList, result.errorList
+	}
+	resultChan := make(chan parseResult, 1)
+	go func() {
+		resultChan <- call()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-resultChan:
+		return result.dataList, result.errorList
+	case <-timer.C:
+		errMsg := fmt.Sprintf("Parser timeout after %s", timeout)
+		return nil, []error{newParserError(index, route, reqUrl, errMsg)}
+	}
+}
+
+// 生成响应解析函数的分析器错误。由路由器分派的响应解析函数的错误中带有路由规则的名称，而不是其在匹配结果中的序号。
+func newParserError(index int, route string, reqUrl string, errMsg string) error {
+	if route != "" {
+		return base.NewRouteAnalyzerError(route, reqUrl, errMsg)
+	}
+	return base.NewAnalyzerError(index, reqUrl, errMsg)
+}
+
 func appendDataList(dataList []base.Data, data base.Data, respDepth uint32) []base.Data {
 	if data == nil {
 		return dataList
@@ -87,11 +198,12 @@ func appendDataList(dataList []base.Data, data base.Data, respDepth uint32) []ba
 	if !ok {
 		return append(dataList, data)
 	}
-	newDetpth := respDepth + 1
-	if req.Depth() != newDetpth {
-		req = base.NewRequest(req.HttpReq(), newDetpth)
+	// 请求的深度由响应解析函数决定（通常为响应深度加1，翻页请求则与响应深度相同），
+	// 但不能小于响应的深度。
+	if req.Depth() < respDepth {
+		req = base.NewRequestWithPriority(req.HttpReq(), respDepth, req.Priority())
 	}
-	return append(dataList, data)
+	return append(dataList, req)
 }
 
 func appendErrorList(errorList []error, err error) []error {
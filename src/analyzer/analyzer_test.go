@@ -0,0 +1,106 @@
+package analyzer
+
+import (
+	"base"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 读取整个响应体并把它作为条目的解析函数。
+func bodyParser(name string) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		body, err := ioutil.ReadAll(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+		item := base.Item{"parser": name, "body": string(body)}
+		return []base.Data{&item}, nil
+	}
+}
+
+// 获得解析结果中的条目。
+func analyzedItems(dataList []base.Data) []base.Item {
+	items := make([]base.Item, 0)
+	for _, data := range dataList {
+		if item, ok := data.(*base.Item); ok {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+func TestAnalyzerParserIsolation(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	respParsers := []ParseResponse{
+		bodyParser("first"),
+		func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			// 修改响应头和读取一部分响应体都不应影响后续的解析函数。
+			httpResp.Header.Set("Content-Type", "application/octet-stream")
+			httpResp.Body.Read(make([]byte, 4))
+			panic("boom")
+		},
+		func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			<-release
+			item := base.Item{"parser": "slow"}
+			return []base.Data{&item}, nil
+		},
+		nil,
+		func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			item := base.Item{"parser": "last", "body": httpResp.Header.Get("Content-Type")}
+			return []base.Data{&item}, nil
+		},
+	}
+	httpResp := newTestResponse(t, "http://example.com/page", "text/html", "<html>content</html>")
+	analyzer := NewAnalyzerWithTimeout(50 * time.Millisecond)
+	start := time.Now()
+	dataList, errs := analyzer.Analyze(respParsers, *base.NewResponse(httpResp, 0))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("The slow parser should be abandoned: %s", elapsed)
+	}
+	items := analyzedItems(dataList)
+	if len(items) != 2 || items[0]["body"] != "<html>content</html>" || items[1]["body"] != "text/html" {
+		t.Errorf("Unexpected items: %v", items)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	for i, expectedIndex := range []int{1, 2} {
+		analyzerErr, ok := errs[i].(base.AnalyzerError)
+		if !ok || analyzerErr.Type() != base.ANALYZER_ERROR {
+			t.Errorf("The error should be an analyzer error: %v", errs[i])
+			continue
+		}
+		if analyzerErr.ParserIndex() != expectedIndex || analyzerErr.ReqUrl() != "http://example.com/page" {
+			t.Errorf("Unexpected error: %s", analyzerErr)
+		}
+	}
+}
+
+func TestAnalyzerWithoutTimeout(t *testing.T) {
+	// 时限不大于0时使用默认时限，解析函数的结果不会被丢弃。
+	analyzer := NewAnalyzerWithTimeout(0)
+	respParsers := []ParseResponse{bodyParser("first"), bodyParser("second")}
+	httpResp := newTestResponse(t, "http://example.com/page", "text/plain", "text")
+	dataList, errs := analyzer.Analyze(respParsers, *base.NewResponse(httpResp, 0))
+	items := analyzedItems(dataList)
+	if len(errs) != 0 || len(items) != 2 || items[1]["body"] != "text" {
+		t.Errorf("Unexpected result: %v, %v", items, errs)
+	}
+	// 不限时的情况下，运行时恐慌同样被转换为分析器错误。
+	panicParser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		var m map[string]int
+		m["a"] = 1
+		return nil, nil
+	}
+	httpResp = newTestResponse(t, "http://example.com/page", "text/plain", "text")
+	dataList, errs = runParsers([]ParseResponse{panicParser, bodyParser("after")}, nil, httpResp, []byte("text"), 0, 0)
+	if len(errs) != 1 || len(analyzedItems(dataList)) != 1 {
+		t.Errorf("Unexpected result: %v, %v", dataList, errs)
+	}
+	if analyzerErr, ok := errs[0].(base.AnalyzerError); !ok || analyzerErr.ParserIndex() != 0 {
+		t.Errorf("Unexpected error: %v", errs[0])
+	}
+}
@@ -0,0 +1,211 @@
+package analyzer
+
+import (
+	"base"
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// 网页级爬取指令过滤器的参数容器的描述模板。
+var robotsArgsTemplate string = "{ ignore: %v, userAgent: %s }"
+
+// 网页级爬取指令过滤器的参数容器。
+type RobotsArgs struct {
+	// 是否忽略网页级爬取指令。用于内部审计等需要完整抓取的场合。
+	// 把过滤器设为链接解析函数的Robots参数，即可由该开关同时决定是否忽略链接上的rel="nofollow"。
+	Ignore bool
+	// 爬虫的名称，如mybot。为空表示只认可针对所有爬虫的指令。
+	// 不为空时，<meta name="mybot">以及以“mybot:”开头的X-Robots-Tag也会被认可。
+	UserAgent string
+}
+
+func (args *RobotsArgs) Check() error {
+	if strings.ContainsAny(args.UserAgent, ":, ") {
+		return errors.New(fmt.Sprintf("Invalid user agent '%s'!\n", args.UserAgent))
+	}
+	return nil
+}
+
+func (args *RobotsArgs) String() string {
+	return fmt.Sprintf(robotsArgsTemplate, args.Ignore, args.UserAgent)
+}
+
+// 网页级爬取指令。
+type RobotsDirectives struct {
+	NoIndex  bool // 是否禁止收录网页的内容。
+	NoFollow bool // 是否禁止跟随网页中的链接。
+}
+
+// 网页级爬取指令过滤器的接口类型。
+// 它会读取<meta name="robots">以及X-Robots-Tag响应头中的指令，
+// 并丢弃带有nofollow指令的网页中的请求以及带有noindex指令的网页中的条目。
+type RobotsFilter interface {
+	// 包装响应解析函数。忽略网页级爬取指令时直接返回原函数。
+	Wrap(respParser ParseResponse) ParseResponse
+	// 获得HTTP响应中的网页级爬取指令。参数body为响应体，只有HTML文档中的指令会被读取。
+	Directives(httpResp *http.Response, body []byte) RobotsDirectives
+	// 是否忽略爬取指令。忽略时链接上的rel="nofollow"也应被忽略。
+	Ignored() bool
+	// 获得带有noindex和nofollow指令的HTTP响应的计数值。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建网页级爬取指令过滤器。
+func NewRobotsFilter(args RobotsArgs) (RobotsFilter, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	return &myRobotsFilter{args: args, userAgent: strings.ToLower(args.UserAgent)}, nil
+}
+
+// 网页级爬取指令过滤器的实现类型。
+type myRobotsFilter struct {
+	args      RobotsArgs // 参数。
+	userAgent string     // 小写的爬虫名称。
+	noIndex   uint64     // 带有noindex指令的HTTP响应的数量。
+	noFollow  uint64     // 带有nofollow指令的HTTP响应的数量。
+}
+
+func (filter *myRobotsFilter) Wrap(respParser ParseResponse) ParseResponse {
+	if filter.args.Ignore {
+		return respParser
+	}
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		body, err := readBody(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		httpResp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		dataList, errorList := respParser(httpResp, respDepth)
+		directives := filter.Directives(httpResp, body)
+		if !directives.NoIndex && !directives.NoFollow {
+			return dataList, errorList
+		}
+		if directives.NoIndex {
+			atomic.AddUint64(&filter.noIndex, 1)
+		}
+		if directives.NoFollow {
+			atomic.AddUint64(&filter.noFollow, 1)
+		}
+		kept := make([]base.Data, 0, len(dataList))
+		for _, data := range dataList {
+			switch data.(type) {
+			case *base.Request:
+				if directives.NoFollow {
+					continue
+				}
+			case *base.Item:
+				if directives.NoIndex {
+					continue
+				}
+			}
+			kept = append(kept, data)
+		}
+		return kept, errorList
+	}
+}
+
+func (filter *myRobotsFilter) Ignored() bool {
+	return filter.args.Ignore
+}
+
+func (filter *myRobotsFilter) Directives(httpResp *http.Response, body []byte) RobotsDirectives {
+	var directives RobotsDirectives
+	for _, value := range httpResp.Header["X-Robots-Tag"] {
+		// 形如“mybot: noindex”的值只针对特定的爬虫。
+		if index := strings.Index(value, ":"); index >= 0 {
+			agent := strings.ToLower(strings.TrimSpace(value[:index]))
+			if !strings.ContainsAny(agent, ", ") {
+				if agent != filter.userAgent {
+					continue
+				}
+				value = value[index+1:]
+			}
+		}
+		directives.merge(value)
+	}
+	if strings.Contains(mediaType(httpResp, body), "html") {
+		for _, meta := range robotsMetas(body) {
+			if meta[0] == "robots" || (filter.userAgent != "" && meta[0] == filter.userAgent) {
+				directives.merge(meta[1])
+			}
+		}
+	}
+	return directives
+}
+
+// 合并以逗号分隔的指令。
+func (directives *RobotsDirectives) merge(value string) {
+	for _, directive := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			directives.NoIndex = true
+		case "nofollow":
+			directives.NoFollow = true
+		case "none":
+			directives.NoIndex = true
+			directives.NoFollow = true
+		}
+	}
+}
+
+// 获得HTML文档头部中所有meta标签的名称（小写）和内容。
+func robotsMetas(body []byte) [][2]string {
+	metas := make([][2]string, 0)
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return metas
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "body":
+				return metas
+			case "meta":
+				var metaName, content string
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					switch string(key) {
+					case "name":
+						metaName = strings.ToLower(strings.TrimSpace(string(val)))
+					case "content":
+						content = string(val)
+					}
+				}
+				if metaName != "" {
+					metas = append(metas, [2]string{metaName, content})
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "head" {
+				return metas
+			}
+		}
+	}
+}
+
+func (filter *myRobotsFilter) Count() []uint64 {
+	counts := make([]uint64, 2)
+	counts[0] = atomic.LoadUint64(&filter.noIndex)
+	counts[1] = atomic.LoadUint64(&filter.noFollow)
+	return counts
+}
+
+// 摘要信息模板。
+var robotsSummaryTemplate = "ignore: %v, noindex: %d, nofollow: %d"
+
+func (filter *myRobotsFilter) Summary() string {
+	counts := filter.Count()
+	return fmt.Sprintf(robotsSummaryTemplate, filter.args.Ignore, counts[0], counts[1])
+}
@@ -0,0 +1,102 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestRobotsDirectives(t *testing.T) {
+	filter, err := NewRobotsFilter(RobotsArgs{UserAgent: "MyBot"})
+	if err != nil {
+		t.Fatalf("Can not create the filter: %s", err)
+	}
+	cases := []struct {
+		contentType string
+		headers     []string
+		body        string
+		expected    RobotsDirectives
+	}{
+		{"text/html", nil, `<html><head><title>Plain</title></head><body></body></html>`,
+			RobotsDirectives{}},
+		{"text/html", nil, `<html><head><meta name="robots" content="noindex"></head></html>`,
+			RobotsDirectives{NoIndex: true}},
+		{"text/html", nil, `<html><head><META NAME="Robots" CONTENT="NoFollow, NoArchive"/></head></html>`,
+			RobotsDirectives{NoFollow: true}},
+		{"text/html", nil, `<html><head><meta name="robots" content="none"></head></html>`,
+			RobotsDirectives{NoIndex: true, NoFollow: true}},
+		// 针对本爬虫的和针对其他爬虫的meta标签。
+		{"text/html", nil, `<html><head><meta name="mybot" content="nofollow"><meta name="otherbot" content="noindex"></head></html>`,
+			RobotsDirectives{NoFollow: true}},
+		// 正文中的meta标签不被认可。
+		{"text/html", nil, `<html><head></head><body><meta name="robots" content="noindex"></body></html>`,
+			RobotsDirectives{}},
+		{"text/html", []string{"noindex, nofollow"}, `<html></html>`,
+			RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"application/pdf", []string{"noindex"}, `%PDF-1.4`,
+			RobotsDirectives{NoIndex: true}},
+		// 带有爬虫名称的X-Robots-Tag只针对该爬虫。
+		{"text/html", []string{"otherbot: noindex", "MyBot: nofollow"}, `<html></html>`,
+			RobotsDirectives{NoFollow: true}},
+		{"text/html", []string{"unavailable_after: 25 Jun 2030 15:00:00 PST"}, `<html></html>`,
+			RobotsDirectives{}},
+		// 非HTML文档中的meta标签不被认可。
+		{"text/plain", nil, `<meta name="robots" content="noindex">`,
+			RobotsDirectives{}},
+	}
+	for _, c := range cases {
+		httpResp := newTestResponse(t, "http://example.com/", c.contentType, c.body)
+		for _, value := range c.headers {
+			httpResp.Header.Add("X-Robots-Tag", value)
+		}
+		if directives := filter.Directives(httpResp, []byte(c.body)); directives != c.expected {
+			t.Errorf("Unexpected directives of %v %q: %+v", c.headers, c.body, directives)
+		}
+	}
+	// 未设定爬虫名称时，只认可针对所有爬虫的指令。
+	filter, _ = NewRobotsFilter(RobotsArgs{})
+	httpResp := newTestResponse(t, "http://example.com/", "text/html", "")
+	httpResp.Header.Add("X-Robots-Tag", "mybot: noindex")
+	body := []byte(`<html><head><meta name="mybot" content="nofollow"></head></html>`)
+	if directives := filter.Directives(httpResp, body); directives != (RobotsDirectives{}) {
+		t.Errorf("Unexpected directives: %+v", directives)
+	}
+}
+
+func TestRobotsFilterWrap(t *testing.T) {
+	filter, _ := NewRobotsFilter(RobotsArgs{})
+	parser := filter.Wrap(itemAndLinkParser(t))
+	cases := []struct {
+		meta  string
+		items int
+		reqs  int
+	}{
+		{"", 1, 1},
+		{"noindex", 0, 1},
+		{"nofollow", 1, 0},
+		{"noindex,nofollow", 0, 0},
+	}
+	for _, c := range cases {
+		page := `<html><head><meta name="robots" content="` + c.meta + `"></head><body></body></html>`
+		dataList, _ := parser(newTestResponse(t, "http://example.com/", "text/html", page), 0)
+		if items, reqs := countData(dataList); items != c.items || reqs != c.reqs {
+			t.Errorf("Unexpected result of %q: %d items, %d requests", c.meta, items, reqs)
+		}
+	}
+	if counts := filter.Count(); counts[0] != 2 || counts[1] != 2 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+	// 忽略爬取指令时，解析结果保持不变。
+	filter, _ = NewRobotsFilter(RobotsArgs{Ignore: true})
+	if !filter.Ignored() {
+		t.Errorf("The filter should be ignored")
+	}
+	page := `<html><head><meta name="robots" content="none"></head></html>`
+	dataList, _ := filter.Wrap(itemAndLinkParser(t))(newTestResponse(t, "http://example.com/", "text/html", page), 0)
+	if items, reqs := countData(dataList); items != 1 || reqs != 1 {
+		t.Errorf("Unexpected result: %d items, %d requests", items, reqs)
+	}
+	for _, userAgent := range []string{"my bot", "mybot:", "a,b"} {
+		if _, err := NewRobotsFilter(RobotsArgs{UserAgent: userAgent}); err == nil {
+			t.Errorf("The user agent '%s' should be invalid", userAgent)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package jsonapi
+
+import (
+	"analyzer/parsers"
+	"base"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// 翻页方式的类型。
+type PagingType string
+
+const (
+	PAGING_NEXT_URL PagingType = "next_url" // 响应中直接给出下一页的网址。
+	PAGING_CURSOR   PagingType = "cursor"   // 响应中给出下一页的游标，以参数的形式传给下一个请求。
+	PAGING_OFFSET   PagingType = "offset"   // 以偏移量和每页条目数翻页。
+	PAGING_PAGE     PagingType = "page"     // 以页码翻页。
+)
+
+// 翻页设定的描述模板。
+var pagingTemplate string = "{ type: %s, nextPath: %s, param: %s," +
+	" limitParam: %s, limit: %d, totalPath: %s, hasMorePath: %s }"
+
+// 翻页设定。
+// 对于GET请求，翻页参数会被放入下一个请求的查询字符串中；
+// 对于带有JSON对象请求体的其他请求（如POST请求），翻页参数会被放入请求体的顶层成员中。
+type Paging struct {
+	Type        PagingType // 翻页方式。
+	NextPath    string     // 下一页的网址（next_url方式）或游标（cursor方式）的路径。
+	Param       string     // 游标、偏移量或页码的参数名称。
+	LimitParam  string     // 每页条目数的参数名称（offset方式）。为空表示不传递该参数。
+	Limit       int64      // 每页条目数（offset方式）。
+	TotalPath   string     // 条目总数（offset方式）或总页数（page方式）的路径。可以为空。
+	HasMorePath string     // 表示是否还有下一页的布尔值的路径。可以为空。
+}
+
+func (paging *Paging) Check() error {
+	switch paging.Type {
+	case PAGING_NEXT_URL:
+		if paging.NextPath == "" {
+			return errors.New("The next url path is missing!\n")
+		}
+	case PAGING_CURSOR:
+		if paging.NextPath == "" || paging.Param == "" {
+			return errors.New("The cursor path or cursor param is missing!\n")
+		}
+	case PAGING_OFFSET:
+		if paging.Param == "" || paging.Limit <= 0 {
+			return errors.New("The offset param or page limit is invalid!\n")
+		}
+	case PAGING_PAGE:
+		if paging.Param == "" {
+			return errors.New("The page param is missing!\n")
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unsupported paging type '%s'!\n", paging.Type))
+	}
+	return nil
+}
+
+func (paging *Paging) String() string {
+	return fmt.Sprintf(pagingTemplate,
+		paging.Type, paging.NextPath, paging.Param,
+		paging.LimitParam, paging.Limit, paging.TotalPath, paging.HasMorePath)
+}
+
+// 编译后的翻页设定。
+type compiledPaging struct {
+	Paging
+	nextPath    *Path // 下一页的网址或游标的路径。
+	totalPath   *Path // 条目总数或总页数的路径。
+	hasMorePath *Path // 是否还有下一页的路径。
+}
+
+// 编译翻页设定中的路径。
+func (paging *Paging) compile() (*compiledPaging, error) {
+	cp := &compiledPaging{Paging: *paging}
+	var err error
+	if paging.NextPath != "" {
+		if cp.nextPath, err = CompilePath(paging.NextPath); err != nil {
+			return nil, err
+		}
+	}
+	if paging.TotalPath != "" {
+		if cp.totalPath, err = CompilePath(paging.TotalPath); err != nil {
+			return nil, err
+		}
+	}
+	if paging.HasMorePath != "" {
+		if cp.hasMorePath, err = CompilePath(paging.HasMorePath); err != nil {
+			return nil, err
+		}
+	}
+	return cp, nil
+}
+
+// 生成下一页的请求。没有下一页时结果值为nil。
+// 参数req代表（重定向之前的）原始请求，参数docUrl代表本页的实际网址，相对的下一页网址以它为基准。
+// 参数itemCount代表本页的条目数量，参数counted代表该数量是否可用。
+func (cp *compiledPaging) next(
+	req *base.Request,
+	docUrl *url.URL,
+	doc interface{},
+	itemCount int,
+	counted bool) (*base.Request, error) {
+	if cp.hasMorePath != nil {
+		if hasMore, ok := cp.hasMorePath.FindOne(doc); ok {
+			if b, ok := hasMore.(bool); ok && !b {
+				return nil, nil
+			}
+		}
+	}
+	switch cp.Type {
+	case PAGING_NEXT_URL:
+		value, _ := cp.nextPath.FindOne(doc)
+		href, ok := value.(string)
+		if !ok || href == "" {
+			return nil, nil
+		}
+		nextUrl, err := parsers.ResolveHref(docUrl, href)
+		if err != nil || nextUrl == nil {
+			return nil, err
+		}
+		httpReq, err := http.NewRequest("GET", nextUrl.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return base.NewRequest(httpReq, req.Depth()), nil
+	case PAGING_CURSOR:
+		value, _ := cp.nextPath.FindOne(doc)
+		if value == nil || value == "" {
+			return nil, nil
+		}
+		return withParams(req, map[string]interface{}{cp.Param: value})
+	case PAGING_OFFSET:
+		offset, err := currentParam(req, cp.Param, 0)
+		if err != nil {
+			return nil, err
+		}
+		if counted && int64(itemCount) < cp.Limit {
+			return nil, nil
+		}
+		nextOffset := offset + cp.Limit
+		if total, ok := cp.total(doc); ok && nextOffset >= total {
+			return nil, nil
+		}
+		params := map[string]interface{}{cp.Param: nextOffset}
+		if cp.LimitParam != "" {
+			params[cp.LimitParam] = cp.Limit
+		}
+		return withParams(req, params)
+	case PAGING_PAGE:
+		page, err := currentParam(req, cp.Param, 1)
+		if err != nil {
+			return nil, err
+		}
+		if counted && itemCount == 0 {
+			return nil, nil
+		}
+		if total, ok := cp.total(doc); ok && page >= total {
+			return nil, nil
+		}
+		return withParams(req, map[string]interface{}{cp.Param: page + 1})
+	}
+	return nil, nil
+}
+
+// 获得条目总数或总页数。
+func (cp *compiledPaging) total(doc interface{}) (int64, bool) {
+	if cp.totalPath == nil {
+		return 0, false
+	}
+	value, ok := cp.totalPath.FindOne(doc)
+	if !ok {
+		return 0, false
+	}
+	return toInt64(value)
+}
+
+// 将值转换为int64类型。
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// 判断请求是否以JSON对象请求体传递参数。
+func usesJsonBody(req *base.Request) bool {
+	method := req.HttpReq().Method
+	return method != "" && method != "GET" && req.Body() != nil
+}
+
+// 从请求中获得整数参数的当前值。参数不存在时返回默认值。
+func currentParam(req *base.Request, name string, defaultValue int64) (int64, error) {
+	var value interface{}
+	if usesJsonBody(req) {
+		obj, err := Decode(bytes.NewReader(req.Body()))
+		if err != nil {
+			return 0, err
+		}
+		if m, ok := obj.(map[string]interface{}); ok {
+			value = m[name]
+		}
+	} else if query := req.HttpReq().URL.Query(); query.Get(name) != "" {
+		value = query.Get(name)
+	}
+	if value == nil {
+		return defaultValue, nil
+	}
+	i, ok := toInt64(value)
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("Invalid paging param '%s': %v", name, value))
+	}
+	return i, nil
+}
+
+// 以原有请求为模板生成带有新参数的请求。
+func withParams(req *base.Request, params map[string]interface{}) (*base.Request, error) {
+	httpReq := req.HttpReq()
+	nextUrl := *httpReq.URL
+	var body []byte
+	if usesJsonBody(req) {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(req.Body(), &obj); err != nil {
+			return nil, errors.New(
+				fmt.Sprintf("The request body is not a json object: %s", err))
+		}
+		for name, value := range params {
+			obj[name] = value
+		}
+		var err error
+		if body, err = json.Marshal(obj); err != nil {
+			return nil, err
+		}
+	} else {
+		query := nextUrl.Query()
+		for name, value := range params {
+			query.Set(name, fmt.Sprint(value))
+		}
+		nextUrl.RawQuery = query.Encode()
+	}
+	var nextHttpReq *http.Request
+	var err error
+	if body != nil {
+		nextHttpReq, err = http.NewRequest(httpReq.Method, nextUrl.String(), bytes.NewReader(body))
+	} else {
+		nextHttpReq, err = http.NewRequest(httpReq.Method, nextUrl.String(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range httpReq.Header {
+		nextHttpReq.Header[key] = append([]string(nil), values...)
+	}
+	return base.NewRequest(nextHttpReq, req.Depth()), nil
+}
@@ -0,0 +1,300 @@
+package jsonapi
+
+import (
+	"base"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 生成针对请求的JSON响应。
+func jsonResponse(t *testing.T, httpReq *http.Request, body string) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    httpReq,
+	}
+}
+
+// 生成GET请求。
+func getRequest(t *testing.T, reqUrl string) *http.Request {
+	httpReq, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		t.Fatalf("Can not create the request: %s", err)
+	}
+	return httpReq
+}
+
+// 用JSON解析函数解析响应，获得条目以及各个请求。
+func parseJson(t *testing.T, args JsonArgs, httpResp *http.Response) ([]base.Item, []*base.Request) {
+	parser, err := NewJsonParser(args)
+	if err != nil {
+		t.Fatalf("Can not create the json parser: %s", err)
+	}
+	dataList, errs := parser(httpResp, 2)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	items := make([]base.Item, 0)
+	reqs := make([]*base.Request, 0)
+	for _, data := range dataList {
+		switch d := data.(type) {
+		case *base.Item:
+			items = append(items, *d)
+		case *base.Request:
+			reqs = append(reqs, d)
+		}
+	}
+	return items, reqs
+}
+
+// 获得分页请求的网址。没有分页请求时返回空字符串。
+func nextUrl(t *testing.T, args JsonArgs, reqUrl string, body string) string {
+	_, reqs := parseJson(t, args, jsonResponse(t, getRequest(t, reqUrl), body))
+	if len(reqs) == 0 {
+		return ""
+	}
+	if len(reqs) > 1 {
+		t.Errorf("Unexpected requests: %d", len(reqs))
+	}
+	if reqs[0].Depth() != 2 {
+		t.Errorf("The next page should keep the depth: %d", reqs[0].Depth())
+	}
+	return reqs[0].HttpReq().URL.String()
+}
+
+func TestJsonItemsAndFollow(t *testing.T) {
+	args := JsonArgs{
+		ItemsPath:  "$.data.items[*]",
+		Fields:     map[string]string{"id": "id", "title": "$.attrs.title"},
+		FollowPath: "$.data.items[*].url",
+	}
+	body := `{"data": {"items": [
+  {"id": 1, "attrs": {"title": "one"}, "url": "/items/1"},
+  {"id": 2, "url": "http://other.example.com/items/2"},
+  {"attrs": {}, "url": 3},
+  "plain"
+]}}`
+	httpResp := jsonResponse(t, getRequest(t, "http://api.example.com/v1/items"), body)
+	items, reqs := parseJson(t, args, httpResp)
+	expected := []base.Item{
+		{"id": int64(1), "title": "one", "parent_url": "http://api.example.com/v1/items"},
+		{"id": int64(2), "parent_url": "http://api.example.com/v1/items"},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items: %v", items)
+	}
+	urls := make([]string, 0)
+	for _, req := range reqs {
+		urls = append(urls, req.HttpReq().URL.String())
+	}
+	if !reflect.DeepEqual(urls, []string{"http://api.example.com/items/1", "http://other.example.com/items/2"}) {
+		t.Errorf("Unexpected follow requests: %v", urls)
+	}
+	// 没有字段设定时，整个对象（或包装后的值）作为条目。
+	args = JsonArgs{ItemsPath: "$[*]"}
+	httpResp = jsonResponse(t, getRequest(t, "http://api.example.com/v1/list"), `[{"a": 1}, "b", null]`)
+	items, _ = parseJson(t, args, httpResp)
+	expected = []base.Item{
+		{"a": int64(1), "parent_url": "http://api.example.com/v1/list"},
+		{"value": "b", "parent_url": "http://api.example.com/v1/list"},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items: %v", items)
+	}
+}
+
+func TestJsonNotJson(t *testing.T) {
+	args := JsonArgs{ItemsPath: "$[*]"}
+	httpResp := jsonResponse(t, getRequest(t, "http://api.example.com/page"), "<html></html>")
+	httpResp.Header.Set("Content-Type", "text/html")
+	if items, reqs := parseJson(t, args, httpResp); len(items) != 0 || len(reqs) != 0 {
+		t.Errorf("An html response should be ignored")
+	}
+	// 未声明为JSON但看起来像JSON的响应也会被解析。
+	httpResp = jsonResponse(t, getRequest(t, "http://api.example.com/page"), ` [1, 2]`)
+	httpResp.Header.Set("Content-Type", "text/plain")
+	if items, _ := parseJson(t, args, httpResp); len(items) != 2 {
+		t.Errorf("Unexpected items: %v", items)
+	}
+	parser, _ := NewJsonParser(args)
+	httpResp = jsonResponse(t, getRequest(t, "http://api.example.com/page"), `{"a": `)
+	if _, errs := parser(httpResp, 0); len(errs) != 1 {
+		t.Errorf("An invalid json should be reported: %v", errs)
+	}
+}
+
+func TestPagingNextUrl(t *testing.T) {
+	args := JsonArgs{
+		ItemsPath: "$.items[*]",
+		Paging:    &Paging{Type: PAGING_NEXT_URL, NextPath: "$.links.next", HasMorePath: "$.has_more"},
+	}
+	cases := []struct {
+		body     string
+		expected string
+	}{
+		{`{"items": [1], "links": {"next": "?page=3"}}`, "http://api.example.com/v1/items?page=3"},
+		{`{"items": [1], "links": {"next": "http://cdn.example.com/p3"}, "has_more": true}`, "http://cdn.example.com/p3"},
+		{`{"items": [1], "links": {"next": "?page=3"}, "has_more": false}`, ""},
+		{`{"items": [1], "links": {"next": ""}}`, ""},
+		{`{"items": [1], "links": {"next": null}}`, ""},
+		{`{"items": [1]}`, ""},
+	}
+	for _, c := range cases {
+		if next := nextUrl(t, args, "http://api.example.com/v1/items?page=2", c.body); next != c.expected {
+			t.Errorf("Unexpected next page of %s: %q (expected %q)", c.body, next, c.expected)
+		}
+	}
+}
+
+func TestPagingCursor(t *testing.T) {
+	args := JsonArgs{
+		ItemsPath: "$.items[*]",
+		Paging:    &Paging{Type: PAGING_CURSOR, NextPath: "$.cursor", Param: "after"},
+	}
+	next := nextUrl(t, args, "http://api.example.com/v1/items?after=abc&q=go", `{"items": [1], "cursor": "def"}`)
+	if next != "http://api.example.com/v1/items?after=def&q=go" {
+		t.Errorf("Unexpected next page: %s", next)
+	}
+	if next := nextUrl(t, args, "http://api.example.com/v1/items", `{"items": [1], "cursor": ""}`); next != "" {
+		t.Errorf("An empty cursor should stop the paging: %s", next)
+	}
+}
+
+func TestPagingOffset(t *testing.T) {
+	args := JsonArgs{
+		ItemsPath: "$.items[*]",
+		Paging: &Paging{Type: PAGING_OFFSET, Param: "offset", LimitParam: "limit",
+			Limit: 2, TotalPath: "$.total"},
+	}
+	cases := []struct {
+		reqUrl   string
+		body     string
+		expected string
+	}{
+		{"http://api.example.com/v1/items", `{"items": [1, 2], "total": 5}`,
+			"http://api.example.com/v1/items?limit=2&offset=2"},
+		{"http://api.example.com/v1/items?offset=2&limit=2", `{"items": [3, 4], "total": "5"}`,
+			"http://api.example.com/v1/items?limit=2&offset=4"},
+		// 到达条目总数时停止。
+		{"http://api.example.com/v1/items?offset=2", `{"items": [3, 4], "total": 4}`, ""},
+		// 本页不满一页时停止。
+		{"http://api.example.com/v1/items?offset=4", `{"items": [5]}`, ""},
+		{"http://api.example.com/v1/items?offset=4", `{"items": []}`, ""},
+	}
+	for _, c := range cases {
+		if next := nextUrl(t, args, c.reqUrl, c.body); next != c.expected {
+			t.Errorf("Unexpected next page of %s: %q (expected %q)", c.reqUrl, next, c.expected)
+		}
+	}
+	parser, _ := NewJsonParser(args)
+	httpResp := jsonResponse(t, getRequest(t, "http://api.example.com/v1/items?offset=x"), `{"items": [1, 2]}`)
+	if _, errs := parser(httpResp, 0); len(errs) != 1 {
+		t.Errorf("An invalid offset should be reported: %v", errs)
+	}
+}
+
+func TestPagingPage(t *testing.T) {
+	args := JsonArgs{
+		ItemsPath: "$.items[*]",
+		Paging:    &Paging{Type: PAGING_PAGE, Param: "p", TotalPath: "$.pages"},
+	}
+	cases := []struct {
+		reqUrl   string
+		body     string
+		expected string
+	}{
+		{"http://api.example.com/v1/items", `{"items": [1], "pages": 3}`, "http://api.example.com/v1/items?p=2"},
+		{"http://api.example.com/v1/items?p=2", `{"items": [1]}`, "http://api.example.com/v1/items?p=3"},
+		// 到达总页数时停止。
+		{"http://api.example.com/v1/items?p=3", `{"items": [1], "pages": 3}`, ""},
+		// 本页没有条目时停止。
+		{"http://api.example.com/v1/items?p=4", `{"items": []}`, ""},
+	}
+	for _, c := range cases {
+		if next := nextUrl(t, args, c.reqUrl, c.body); next != c.expected {
+			t.Errorf("Unexpected next page of %s: %q (expected %q)", c.reqUrl, next, c.expected)
+		}
+	}
+	// 只有是否还有下一页的标志时，以它为停止条件。
+	args = JsonArgs{Paging: &Paging{Type: PAGING_PAGE, Param: "p", HasMorePath: "$.more"}}
+	if next := nextUrl(t, args, "http://api.example.com/v1/items?p=9", `{"more": true}`); next != "http://api.example.com/v1/items?p=10" {
+		t.Errorf("Unexpected next page: %s", next)
+	}
+	if next := nextUrl(t, args, "http://api.example.com/v1/items?p=10", `{"more": false}`); next != "" {
+		t.Errorf("Unexpected next page: %s", next)
+	}
+}
+
+func TestPagingPostBody(t *testing.T) {
+	args := JsonArgs{
+		ItemsPath: "$.items[*]",
+		Paging:    &Paging{Type: PAGING_PAGE, Param: "page", TotalPath: "$.pages"},
+	}
+	body := []byte(`{"query": "go", "page": 1}`)
+	httpReq, err := http.NewRequest("POST", "http://api.example.com/search", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Can not create the request: %s", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	// 模拟一次重定向，下一页的请求应以原始请求为模板。
+	redirected := getRequest(t, "http://api.example.com/search/results")
+	redirected.Response = &http.Response{StatusCode: 307, Request: httpReq}
+	_, reqs := parseJson(t, args, jsonResponse(t, redirected, `{"items": [1], "pages": 2}`))
+	if len(reqs) != 1 {
+		t.Fatalf("Unexpected requests: %d", len(reqs))
+	}
+	nextReq := reqs[0].HttpReq()
+	if nextReq.Method != "POST" || nextReq.URL.String() != "http://api.example.com/search" ||
+		nextReq.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected request: %s %s %v", nextReq.Method, nextReq.URL, nextReq.Header)
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(reqs[0].Body(), &params); err != nil {
+		t.Fatalf("Invalid request body: %s", err)
+	}
+	if !reflect.DeepEqual(params, map[string]interface{}{"query": "go", "page": float64(2)}) {
+		t.Errorf("Unexpected request body: %v", params)
+	}
+}
+
+func TestJsonArgsCheck(t *testing.T) {
+	invalid := []JsonArgs{
+		{},
+		{ItemsPath: "$.items[*]", Paging: &Paging{Type: "token"}},
+		{ItemsPath: "$.items[*]", Paging: &Paging{Type: PAGING_NEXT_URL}},
+		{ItemsPath: "$.items[*]", Paging: &Paging{Type: PAGING_CURSOR, NextPath: "$.cursor"}},
+		{ItemsPath: "$.items[*]", Paging: &Paging{Type: PAGING_OFFSET, Param: "offset"}},
+		{ItemsPath: "$.items[*]", Paging: &Paging{Type: PAGING_PAGE}},
+		// 没有停止条件的翻页。
+		{Paging: &Paging{Type: PAGING_OFFSET, Param: "offset", Limit: 10}},
+		{Paging: &Paging{Type: PAGING_PAGE, Param: "page"}},
+	}
+	for _, args := range invalid {
+		if _, err := NewJsonParser(args); err == nil {
+			t.Errorf("The args should be invalid: %s", args.String())
+		}
+	}
+	invalidPaths := []JsonArgs{
+		{UrlPattern: "(", ItemsPath: "$.items"},
+		{ItemsPath: "$["},
+		{ItemsPath: "$.items", Fields: map[string]string{"a": "$."}},
+		{FollowPath: "$..*"},
+		{Paging: &Paging{Type: PAGING_PAGE, Param: "page", TotalPath: "$["}},
+	}
+	for _, args := range invalidPaths {
+		if _, err := NewJsonParser(args); err == nil {
+			t.Errorf("The args should be invalid: %s", args.String())
+		}
+	}
+	if _, err := NewJsonParser(JsonArgs{Paging: &Paging{Type: PAGING_CURSOR, NextPath: "$.next", Param: "c"}}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,251 @@
+package jsonapi
+
+import (
+	"analyzer"
+	"analyzer/parsers"
+	"base"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JSON解析函数的参数容器的描述模板。
+var jsonArgsTemplate string = "{ urlPattern: %s, itemsPath: %s, fields: %v," +
+	" followPath: %s, paging: %v }"
+
+// JSON解析函数的参数容器。
+type JsonArgs struct {
+	UrlPattern string            // 适用的网址的正则表达式。为空表示适用于所有JSON响应。
+	ItemsPath  string            // 条目列表的路径，如$.data.items[*]。为空表示不生成条目。
+	Fields     map[string]string // 条目字段名与（相对于条目的）路径的映射。为空表示将整个对象作为条目。
+	FollowPath string            // 需要跟随的网址的路径，如$.data.items[*].url。为空表示不跟随。
+	Paging     *Paging           // 翻页设定。为nil表示不翻页。
+}
+
+func (args *JsonArgs) Check() error {
+	if args.ItemsPath == "" && args.FollowPath == "" && args.Paging == nil {
+		return errors.New("The json parser would produce nothing!\n")
+	}
+	if args.Paging != nil {
+		if err := args.Paging.Check(); err != nil {
+			return err
+		}
+		// 按偏移量或页码翻页时，必须能够判断何时到达最后一页，否则会无休止地请求下一页。
+		switch args.Paging.Type {
+		case PAGING_OFFSET, PAGING_PAGE:
+			if args.ItemsPath == "" && args.Paging.TotalPath == "" && args.Paging.HasMorePath == "" {
+				return errors.New(fmt.Sprintf(
+					"The %s paging needs an items path, a total path or a has-more path to stop!\n",
+					args.Paging.Type))
+			}
+		}
+	}
+	return nil
+}
+
+func (args *JsonArgs) String() string {
+	return fmt.Sprintf(jsonArgsTemplate,
+		args.UrlPattern, args.ItemsPath, args.Fields, args.FollowPath, args.Paging)
+}
+
+// 编译后的JSON解析函数的参数。
+type jsonParser struct {
+	urlRegexp  *regexp.Regexp   // 适用的网址的正则表达式。
+	itemsPath  *Path            // 条目列表的路径。
+	fields     map[string]*Path // 条目字段的路径。
+	followPath *Path            // 需要跟随的网址的路径。
+	paging     *compiledPaging  // 编译后的翻页设定。
+}
+
+// 创建JSON解析函数。
+// 该函数只处理状态码为200且内容为JSON的响应。生成的条目中除了各个字段之外还包含parent_url字段。
+func NewJsonParser(args JsonArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	jp := &jsonParser{fields: make(map[string]*Path)}
+	var err error
+	if args.UrlPattern != "" {
+		if jp.urlRegexp, err = regexp.Compile(args.UrlPattern); err != nil {
+			return nil, err
+		}
+	}
+	if args.ItemsPath != "" {
+		if jp.itemsPath, err = CompilePath(args.ItemsPath); err != nil {
+			return nil, err
+		}
+	}
+	for name, expr := range args.Fields {
+		if jp.fields[name], err = CompilePath(expr); err != nil {
+			return nil, err
+		}
+	}
+	if args.FollowPath != "" {
+		if jp.followPath, err = CompilePath(args.FollowPath); err != nil {
+			return nil, err
+		}
+	}
+	if args.Paging != nil {
+		if jp.paging, err = args.Paging.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return jp.parse, nil
+}
+
+// 判断HTTP响应的内容是否为JSON。
+func IsJson(httpResp *http.Response) bool {
+	contentType := strings.ToLower(httpResp.Header.Get("Content-Type"))
+	return strings.Contains(contentType, "json") || strings.Contains(contentType, "javascript")
+}
+
+// 解码JSON文档。数字会被转换为int64（若为整数）或float64。
+func Decode(reader io.Reader) (interface{}, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return normalize(doc), nil
+}
+
+// 转换JSON文档中的数字。
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, member := range v {
+			v[key] = normalize(member)
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = normalize(element)
+		}
+	}
+	return value
+}
+
+// 获得对象的所有键，并按字典序排列。
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// JSON解析函数。
+func (jp *jsonParser) parse(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	reqUrl := httpResp.Request.URL
+	if jp.urlRegexp != nil && !jp.urlRegexp.MatchString(reqUrl.String()) {
+		return nil, nil
+	}
+	if httpResp.Body == nil {
+		return nil, nil
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != 200 {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, []error{err}
+	}
+	// 未声明为JSON的响应只有在看起来像JSON时才会被解析。
+	trimmed := bytes.TrimSpace(body)
+	if !IsJson(httpResp) &&
+		(len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[')) {
+		return nil, nil
+	}
+	doc, err := Decode(bytes.NewReader(trimmed))
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid json: %s (reqUrl=%s)", err, reqUrl)
+		return nil, []error{errors.New(errMsg)}
+	}
+	c := parsers.NewCollector(httpResp, respDepth)
+	itemCount := 0
+	if jp.itemsPath != nil {
+		for _, element := range jp.itemsPath.Find(doc) {
+			imap := jp.extractItem(element)
+			if imap == nil {
+				continue
+			}
+			c.AddItem(imap)
+			itemCount++
+		}
+	}
+	if jp.followPath != nil {
+		for _, value := range jp.followPath.Find(doc) {
+			href, ok := value.(string)
+			if !ok {
+				continue
+			}
+			followUrl, err := parsers.ResolveHref(reqUrl, href)
+			if err != nil {
+				c.AddError(err)
+				continue
+			}
+			if followUrl != nil {
+				c.AddRequest(followUrl)
+			}
+		}
+	}
+	if jp.paging != nil {
+		// 重定向后的请求已丢失原有的请求方法和请求体，因此要以原始请求为模板生成下一页的请求。
+		req := base.NewRequest(originalRequest(httpResp), respDepth)
+		nextReq, err := jp.paging.next(req, reqUrl, doc, itemCount, jp.itemsPath != nil)
+		if err != nil {
+			c.AddError(err)
+		} else if nextReq != nil {
+			c.AddCustomRequest(nextReq)
+		}
+	}
+	return c.Result()
+}
+
+// 沿着重定向链获得HTTP响应对应的原始请求。
+func originalRequest(httpResp *http.Response) *http.Request {
+	req := httpResp.Request
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req
+}
+
+// 从条目列表中的元素中提取条目。
+func (jp *jsonParser) extractItem(element interface{}) map[string]interface{} {
+	imap := make(map[string]interface{})
+	if len(jp.fields) == 0 {
+		if obj, ok := element.(map[string]interface{}); ok {
+			for key, value := range obj {
+				imap[key] = value
+			}
+		} else if element != nil {
+			imap["value"] = element
+		}
+	} else {
+		for name, path := range jp.fields {
+			if value, ok := path.FindOne(element); ok && value != nil {
+				imap[name] = value
+			}
+		}
+	}
+	if len(imap) == 0 {
+		return nil
+	}
+	return imap
+}
@@ -0,0 +1,200 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 路径中的步骤的类型。
+type stepType uint8
+
+const (
+	stepKey       stepType = 0 // 按键获取对象的成员。
+	stepIndex     stepType = 1 // 按索引获取数组的元素，负数表示从末尾倒数。
+	stepWildcard  stepType = 2 // 获取对象的所有成员或数组的所有元素。
+	stepRecursive stepType = 3 // 递归地获取所有后代中与键相匹配的成员。
+)
+
+// 路径中的步骤。
+type step struct {
+	typ   stepType // 步骤的类型。
+	key   string   // 键。
+	index int      // 索引。
+}
+
+// 类似于JSONPath的路径表达式。
+// 支持的语法包括：根对象$、成员.key和['key']、数组元素[n]、通配符.*和[*]，以及递归查找..key。
+// 例如：$.data.items[*].name、$..id、$['next-page'].url。
+type Path struct {
+	expr  string // 原始的路径表达式。
+	steps []step // 编译后的步骤。
+}
+
+// 编译路径表达式。
+func CompilePath(expr string) (*Path, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, errors.New("The path expression is empty!")
+	}
+	rest := expr
+	if strings.HasPrefix(rest, "$") {
+		rest = rest[1:]
+	} else if !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "[") {
+		// 允许省略开头的“$.”。
+		rest = "." + rest
+	}
+	steps := make([]step, 0)
+	for rest != "" {
+		var s step
+		var err error
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			s, rest, err = parseKeyStep(rest[2:], expr)
+			if s.typ != stepKey {
+				err = invalidPathError(expr)
+			}
+			s.typ = stepRecursive
+		case strings.HasPrefix(rest, "."):
+			s, rest, err = parseKeyStep(rest[1:], expr)
+		case strings.HasPrefix(rest, "["):
+			s, rest, err = parseBracketStep(rest, expr)
+		default:
+			err = invalidPathError(expr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+	return &Path{expr: expr, steps: steps}, nil
+}
+
+// 编译路径表达式。若编译失败则引发运行时恐慌。
+func MustCompilePath(expr string) *Path {
+	path, err := CompilePath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// 生成路径表达式无效的错误。
+func invalidPathError(expr string) error {
+	return errors.New(fmt.Sprintf("Invalid path expression '%s'!", expr))
+}
+
+// 解析点号之后的键或通配符。
+func parseKeyStep(rest string, expr string) (step, string, error) {
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		end = len(rest)
+	}
+	key := rest[:end]
+	if key == "" {
+		return step{}, "", invalidPathError(expr)
+	}
+	if key == "*" {
+		return step{typ: stepWildcard}, rest[end:], nil
+	}
+	return step{typ: stepKey, key: key}, rest[end:], nil
+}
+
+// 解析方括号中的键、索引或通配符。
+func parseBracketStep(rest string, expr string) (step, string, error) {
+	end := strings.Index(rest, "]")
+	if end < 0 {
+		return step{}, "", invalidPathError(expr)
+	}
+	content := strings.TrimSpace(rest[1:end])
+	rest = rest[end+1:]
+	if content == "*" {
+		return step{typ: stepWildcard}, rest, nil
+	}
+	if len(content) >= 2 && (content[0] == '\'' || content[0] == '"') &&
+		content[len(content)-1] == content[0] {
+		return step{typ: stepKey, key: content[1 : len(content)-1]}, rest, nil
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return step{}, "", invalidPathError(expr)
+	}
+	return step{typ: stepIndex, index: index}, rest, nil
+}
+
+// 获得路径表达式的字符串形式。
+func (path *Path) String() string {
+	return path.expr
+}
+
+// 获得文档中与路径相匹配的所有值。
+func (path *Path) Find(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, s := range path.steps {
+		next := make([]interface{}, 0)
+		for _, value := range current {
+			next = s.apply(value, next)
+		}
+		current = next
+		if len(current) == 0 {
+			break
+		}
+	}
+	return current
+}
+
+// 获得文档中与路径相匹配的第一个值。若不存在则第二个结果值为false。
+func (path *Path) FindOne(doc interface{}) (interface{}, bool) {
+	values := path.Find(doc)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// 将步骤作用于值，并把结果追加到结果列表中。
+func (s step) apply(value interface{}, result []interface{}) []interface{} {
+	switch s.typ {
+	case stepKey:
+		if obj, ok := value.(map[string]interface{}); ok {
+			if member, ok := obj[s.key]; ok {
+				result = append(result, member)
+			}
+		}
+	case stepIndex:
+		if arr, ok := value.([]interface{}); ok {
+			index := s.index
+			if index < 0 {
+				index += len(arr)
+			}
+			if index >= 0 && index < len(arr) {
+				result = append(result, arr[index])
+			}
+		}
+	case stepWildcard:
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, key := range sortedKeys(v) {
+				result = append(result, v[key])
+			}
+		case []interface{}:
+			result = append(result, v...)
+		}
+	case stepRecursive:
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, key := range sortedKeys(v) {
+				if key == s.key {
+					result = append(result, v[key])
+				}
+				result = s.apply(v[key], result)
+			}
+		case []interface{}:
+			for _, element := range v {
+				result = s.apply(element, result)
+			}
+		}
+	}
+	return result
+}
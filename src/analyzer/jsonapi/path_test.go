@@ -0,0 +1,78 @@
+package jsonapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pathDoc = `{
+  "data": {
+    "items": [
+      {"id": 1, "name": "first", "tags": ["a", "b"]},
+      {"id": 2, "name": "second", "owner": {"id": 20}},
+      {"id": 3.5, "name": "third"}
+    ],
+    "next-page": {"url": "/api?page=2"}
+  },
+  "id": 0
+}`
+
+func TestPathFind(t *testing.T) {
+	doc, err := Decode(strings.NewReader(pathDoc))
+	if err != nil {
+		t.Fatalf("Can not decode the document: %s", err)
+	}
+	cases := []struct {
+		expr     string
+		expected []interface{}
+	}{
+		{"$.data.items[*].name", []interface{}{"first", "second", "third"}},
+		{"data.items[0].id", []interface{}{int64(1)}},
+		{".data.items[-1].id", []interface{}{3.5}},
+		{"$.data.items[3]", []interface{}{}},
+		{"$.data.items[-4]", []interface{}{}},
+		{"$['data']['next-page'].url", []interface{}{"/api?page=2"}},
+		{`$["data"]["next-page"]["url"]`, []interface{}{"/api?page=2"}},
+		{"$.data.items[0].tags.*", []interface{}{"a", "b"}},
+		{"$.data.items[1].owner[*]", []interface{}{int64(20)}},
+		{"$.data.next-page.*", []interface{}{"/api?page=2"}},
+		// 递归查找按键的字典序和数组的顺序进行。
+		{"$..id", []interface{}{int64(1), int64(2), int64(20), 3.5, int64(0)}},
+		{"$.data..tags[1]", []interface{}{"b"}},
+		{"$.data.items.name", []interface{}{}},
+		{"$.missing.name", []interface{}{}},
+		{"$", []interface{}{doc}},
+	}
+	for _, c := range cases {
+		path, err := CompilePath(c.expr)
+		if err != nil {
+			t.Errorf("Can not compile '%s': %s", c.expr, err)
+			continue
+		}
+		if values := path.Find(doc); !reflect.DeepEqual(values, c.expected) {
+			t.Errorf("Unexpected values of '%s': %v (expected %v)", c.expr, values, c.expected)
+		}
+	}
+	if _, ok := MustCompilePath("$.data.missing").FindOne(doc); ok {
+		t.Errorf("A missing member should not be found")
+	}
+	if value, ok := MustCompilePath("$.data.items[1].name").FindOne(doc); !ok || value != "second" {
+		t.Errorf("Unexpected value: %v", value)
+	}
+}
+
+func TestCompilePathErrors(t *testing.T) {
+	invalid := []string{"", "  ", "$.", "$.data.", "$[0", "$[abc]", "$..*", "$..[0]", "$x", "$.a..", "$['a]"}
+	for _, expr := range invalid {
+		if path, err := CompilePath(expr); err == nil {
+			t.Errorf("The path expression '%s' should be invalid: %v", expr, path.steps)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustCompilePath should panic on an invalid expression")
+		}
+	}()
+	MustCompilePath("$[")
+}
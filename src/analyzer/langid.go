@@ -0,0 +1,386 @@
+package analyzer
+
+import (
+	"base"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
+
+// 被识别出的语言的代码和置信度会被放入这两个响应头中，以便被包装的响应解析函数使用。
+const (
+	LANGUAGE_HEADER            = "X-Detected-Language"
+	LANGUAGE_CONFIDENCE_HEADER = "X-Detected-Language-Confidence"
+)
+
+// 参与语言识别的最大三元组数量。更长的文本只取其开头部分。
+const LANGUAGE_MAX_TRIGRAMS = 2000
+
+// 直接依据文字判断的语言。
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Thai, "th"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Devanagari, "hi"},
+}
+
+// 三元组模型。
+type trigramModel struct {
+	langs    []string                      // 模型中的语言。
+	logProbs map[string]map[string]float64 // 各语言中各三元组的对数概率。
+	unseen   map[string]float64            // 各语言中未出现的三元组的对数概率。
+}
+
+var (
+	latinModel    *trigramModel // 使用拉丁字母的语言的模型。
+	cyrillicModel *trigramModel // 使用西里尔字母的语言的模型。
+	modelOnce     sync.Once
+)
+
+// 由训练样本生成模型。
+func loadLanguageModels() {
+	latinSamples := make(map[string]string)
+	cyrillicSamples := make(map[string]string)
+	for lang, sample := range languageSamples {
+		if countScript(sample, unicode.Cyrillic) > countScript(sample, unicode.Latin) {
+			cyrillicSamples[lang] = sample
+		} else {
+			latinSamples[lang] = sample
+		}
+	}
+	latinModel = newTrigramModel(latinSamples)
+	cyrillicModel = newTrigramModel(cyrillicSamples)
+}
+
+// 获得文本中属于某种文字的字符的数量。
+func countScript(text string, table *unicode.RangeTable) int {
+	count := 0
+	for _, r := range text {
+		if unicode.Is(table, r) {
+			count++
+		}
+	}
+	return count
+}
+
+// 获得文本中的三元组。每个词的首尾会被补上空格，以体现词首和词尾的特征。
+func trigrams(text string, limit int) []string {
+	result := make([]string, 0)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			result = append(result, string(runes[i:i+3]))
+			if limit > 0 && len(result) >= limit {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// 创建三元组模型。概率采用加一平滑。
+func newTrigramModel(samples map[string]string) *trigramModel {
+	model := &trigramModel{
+		langs:    make([]string, 0, len(samples)),
+		logProbs: make(map[string]map[string]float64),
+		unseen:   make(map[string]float64),
+	}
+	counts := make(map[string]map[string]int)
+	vocabulary := make(map[string]bool)
+	for lang, sample := range samples {
+		model.langs = append(model.langs, lang)
+		counts[lang] = make(map[string]int)
+		for _, trigram := range trigrams(sample, 0) {
+			counts[lang][trigram]++
+			vocabulary[trigram] = true
+		}
+	}
+	sort.Strings(model.langs)
+	for lang, langCounts := range counts {
+		total := 0
+		for _, count := range langCounts {
+			total += count
+		}
+		denominator := float64(total + len(vocabulary))
+		model.logProbs[lang] = make(map[string]float64, len(langCounts))
+		for trigram, count := range langCounts {
+			model.logProbs[lang][trigram] = math.Log(float64(count+1) / denominator)
+		}
+		model.unseen[lang] = math.Log(1 / denominator)
+	}
+	return model
+}
+
+// 获得最可能的语言及其后验概率。
+func (model *trigramModel) classify(text string) (string, float64) {
+	grams := trigrams(text, LANGUAGE_MAX_TRIGRAMS)
+	if len(grams) == 0 || len(model.langs) == 0 {
+		return "", 0
+	}
+	scores := make([]float64, len(model.langs))
+	best := 0
+	for i, lang := range model.langs {
+		for _, gram := range grams {
+			if logProb, ok := model.logProbs[lang][gram]; ok {
+				scores[i] += logProb
+			} else {
+				scores[i] += model.unseen[lang]
+			}
+		}
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	var sum float64
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+	return model.langs[best], 1 / sum
+}
+
+// 识别文本的语言。结果值为ISO 639-1语言代码和0到1之间的置信度。无法识别时语言代码为空。
+// 汉语、日语、韩语等语言依据文字判断，使用拉丁字母和西里尔字母的语言依据三元组模型判断。
+func DetectLanguage(text string) (string, float64) {
+	modelOnce.Do(loadLanguageModels)
+	var letters, han, kana, latin, cyrillic int
+	others := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		default:
+			for i, script := range scriptLanguages {
+				if unicode.Is(script.table, r) {
+					others[i]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+	// 找出占多数的文字。model不为nil表示需要用三元组模型判断语言。
+	dominant, lang := han+kana, "zh"
+	if kana*20 > han+kana {
+		lang = "ja"
+	}
+	var model *trigramModel
+	if latin > dominant {
+		dominant, lang, model = latin, "", latinModel
+	}
+	if cyrillic > dominant {
+		dominant, lang, model = cyrillic, "", cyrillicModel
+	}
+	counted := han + kana + latin + cyrillic
+	for i, count := range others {
+		counted += count
+		if count > dominant {
+			dominant, lang, model = count, scriptLanguages[i].lang, nil
+		}
+	}
+	// 不被支持的文字（如格鲁吉亚文、孟加拉文）占多数时无法识别。
+	if dominant == 0 || letters-counted > dominant {
+		return "", 0
+	}
+	ratio := float64(dominant) / float64(letters)
+	if model == nil {
+		return lang, ratio
+	}
+	lang, probability := model.classify(text)
+	return lang, probability * ratio
+}
+
+// 语言过滤器的参数容器的描述模板。
+var languageArgsTemplate string = "{ targets: %v, minConfidence: %v," +
+	" minTextLength: %d, tagItems: %v }"
+
+// 语言过滤器的参数容器。
+type LanguageArgs struct {
+	// 目标语言的列表，如["zh", "en"]。不为空时，可信地识别为其他语言的网页中的链接不会被跟随。
+	Targets []string
+	// 最小置信度。置信度低于此值的识别结果不会导致链接被丢弃。
+	MinConfidence float64
+	// 参与识别的网页的最小文本长度（字符数）。
+	MinTextLength int
+	// 是否在网页生成的条目中加入language.code和language.confidence字段。
+	TagItems bool
+}
+
+// 获得默认的语言过滤器的参数容器。
+func DefaultLanguageArgs() LanguageArgs {
+	return LanguageArgs{
+		MinConfidence: 0.5,
+		MinTextLength: 50,
+		TagItems:      true,
+	}
+}
+
+func (args *LanguageArgs) Check() error {
+	if args.MinConfidence < 0 || args.MinConfidence > 1 {
+		return errors.New("The min confidence must be in [0, 1]!\n")
+	}
+	if args.MinTextLength < 0 {
+		return errors.New("The min text length can not be negative!\n")
+	}
+	if len(args.Targets) == 0 && !args.TagItems {
+		return errors.New("The language filter would do nothing!\n")
+	}
+	return nil
+}
+
+func (args *LanguageArgs) String() string {
+	return fmt.Sprintf(languageArgsTemplate,
+		args.Targets, args.MinConfidence, args.MinTextLength, args.TagItems)
+}
+
+// 语言过滤器的接口类型。
+// 它会识别网页的语言，把结果放入响应头和网页生成的条目中，并按目标语言限定爬取范围。
+type LanguageFilter interface {
+	// 包装响应解析函数。
+	Wrap(respParser ParseResponse) ParseResponse
+	// 获得各语言的网页的数量。
+	Detected() map[string]uint64
+	// 获得因语言不在目标范围内而被丢弃链接的网页的数量。
+	OutOfScope() uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建语言过滤器。
+func NewLanguageFilter(args LanguageArgs) (LanguageFilter, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]bool)
+	for _, target := range args.Targets {
+		targets[primaryLanguage(target)] = true
+	}
+	return &myLanguageFilter{
+		args:     args,
+		targets:  targets,
+		detected: make(map[string]uint64),
+	}, nil
+}
+
+// 获得语言标签中的主语言代码，如zh-CN中的zh。
+func primaryLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if index := strings.IndexAny(tag, "-_"); index >= 0 {
+		tag = tag[:index]
+	}
+	return tag
+}
+
+// 语言过滤器的实现类型。
+type myLanguageFilter struct {
+	args        LanguageArgs      // 参数。
+	targets     map[string]bool   // 目标语言的主语言代码。
+	detected    map[string]uint64 // 各语言的网页的数量。
+	detectedMux sync.Mutex        // 针对各语言的网页数量的互斥锁。
+	outOfScope  uint64            // 因语言不在目标范围内而被丢弃链接的网页的数量。
+}
+
+func (filter *myLanguageFilter) Wrap(respParser ParseResponse) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		if httpResp.StatusCode != 200 {
+			return respParser(httpResp, respDepth)
+		}
+		body, err := readBody(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		httpResp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		var text string
+		mimeType := mediaType(httpResp, body)
+		if strings.Contains(mimeType, "html") {
+			text = visibleText(bytes.NewReader(body))
+		} else if strings.HasPrefix(mimeType, "text/") {
+			text = string(body)
+		}
+		if len([]rune(text)) < filter.args.MinTextLength {
+			return respParser(httpResp, respDepth)
+		}
+		lang, confidence := DetectLanguage(text)
+		if lang == "" {
+			return respParser(httpResp, respDepth)
+		}
+		filter.detectedMux.Lock()
+		filter.detected[lang]++
+		filter.detectedMux.Unlock()
+		if httpResp.Header == nil {
+			httpResp.Header = make(http.Header)
+		}
+		httpResp.Header.Set(LANGUAGE_HEADER, lang)
+		httpResp.Header.Set(LANGUAGE_CONFIDENCE_HEADER, fmt.Sprintf("%.2f", confidence))
+		dataList, errorList := respParser(httpResp, respDepth)
+		outOfScope := len(filter.targets) > 0 &&
+			confidence >= filter.args.MinConfidence && !filter.targets[lang]
+		if outOfScope {
+			atomic.AddUint64(&filter.outOfScope, 1)
+		}
+		kept := make([]base.Data, 0, len(dataList))
+		for _, data := range dataList {
+			switch d := data.(type) {
+			case *base.Request:
+				if outOfScope {
+					continue
+				}
+			case *base.Item:
+				if filter.args.TagItems && *d != nil {
+					(*d)["language.code"] = lang
+					(*d)["language.confidence"] = confidence
+				}
+			}
+			kept = append(kept, data)
+		}
+		return kept, errorList
+	}
+}
+
+func (filter *myLanguageFilter) Detected() map[string]uint64 {
+	filter.detectedMux.Lock()
+	defer filter.detectedMux.Unlock()
+	detected := make(map[string]uint64, len(filter.detected))
+	for lang, count := range filter.detected {
+		detected[lang] = count
+	}
+	return detected
+}
+
+func (filter *myLanguageFilter) OutOfScope() uint64 {
+	return atomic.LoadUint64(&filter.outOfScope)
+}
+
+// 摘要信息模板。
+var languageSummaryTemplate = "targets: %v, detected: %v, outOfScope: %d"
+
+func (filter *myLanguageFilter) Summary() string {
+	return fmt.Sprintf(languageSummaryTemplate,
+		filter.args.Targets, filter.Detected(), filter.OutOfScope())
+}
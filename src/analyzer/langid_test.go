@@ -0,0 +1,40 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		text string
+		lang string
+	}{
+		{"The quick brown fox jumps over the lazy dog while the farmer watches from the house.", "en"},
+		{"Der schnelle braune Fuchs springt über den faulen Hund, während der Bauer zusieht.", "de"},
+		{"Быстрая коричневая лиса прыгает через ленивую собаку, пока фермер смотрит из дома.", "ru"},
+		{"敏捷的棕色狐狸跳过了那只懒狗，农夫在屋里看着。", "zh"},
+		{"素早い茶色の狐が怠け者の犬を飛び越えるのを、農夫は家から見ている。", "ja"},
+		{"빠른 갈색 여우가 게으른 개를 뛰어넘는 것을 농부가 집에서 지켜본다.", "ko"},
+		{"Η γρήγορη καφέ αλεπού πηδά πάνω από τον τεμπέλη σκύλο.", "el"},
+		// 不被支持的文字不应被当作汉语。
+		{"ქართული ენა არის ქართველების მშობლიური ენა.", ""},
+		{"বাংলা ভাষা বাঙালি জাতির মাতৃভাষা।", ""},
+		{"Հայերեն լեզուն հնդեվրոպական լեզու է։", ""},
+		// 不被支持的文字占多数时，少量拉丁字母也不足以判断语言。
+		{"ქართული ენა არის ქართველების მშობლიური ენა, OK.", ""},
+		{"", ""},
+		{"12345 !!! ...", ""},
+	}
+	for _, c := range cases {
+		lang, confidence := DetectLanguage(c.text)
+		if lang != c.lang {
+			t.Errorf("Unexpected language of %q: %q (%.3f)", c.text, lang, confidence)
+		}
+		if lang == "" && confidence != 0 {
+			t.Errorf("An unknown language should have no confidence: %q (%.3f)", c.text, confidence)
+		}
+		if lang != "" && (confidence <= 0 || confidence > 1) {
+			t.Errorf("Unexpected confidence of %q: %.3f", c.text, confidence)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package analyzer
+
+// 语言识别模型的训练样本。
+// 使用拉丁字母和西里尔字母的语言需依据三元组（trigram）区分，模型在首次使用时由这些样本生成。
+// 其他文字（如汉字、假名、谚文）所对应的语言直接依据文字判断，无需样本。
+var languageSamples = map[string]string{
+	"en": `All human beings are born free and equal in dignity and rights. They are endowed with
+reason and conscience and should act towards one another in a spirit of brotherhood. Everyone is
+entitled to all the rights and freedoms set forth in this Declaration, without distinction of any
+kind, such as race, colour, sex, language, religion, political or other opinion, national or social
+origin, property, birth or other status. Everyone has the right to life, liberty and security of
+person. The city council voted on Monday to approve a network of cycling lanes which will connect
+the centre with the northern suburbs. This is the first time that the government has published the
+full report, and the minister said that there would be more information about the plans in the
+coming weeks. What do you think about the weather today? We were going to the market with our
+children when it started to rain, so we stayed at home and watched a film together. Please read
+the terms and conditions before you sign in to your account and check the latest news.`,
+	"de": `Alle Menschen sind frei und gleich an Würde und Rechten geboren. Sie sind mit Vernunft und
+Gewissen begabt und sollen einander im Geist der Brüderlichkeit begegnen. Jeder hat Anspruch auf
+die in dieser Erklärung verkündeten Rechte und Freiheiten ohne irgendeinen Unterschied, etwa nach
+Rasse, Hautfarbe, Geschlecht, Sprache, Religion, politischer oder sonstiger Überzeugung, nationaler
+oder sozialer Herkunft, Vermögen, Geburt oder sonstigem Stand. Jeder hat das Recht auf Leben,
+Freiheit und Sicherheit der Person. Der Stadtrat hat am Montag beschlossen, ein Netz von Radwegen zu
+bauen, das die Innenstadt mit den nördlichen Vororten verbinden soll. Die Regierung hat den Bericht
+zum ersten Mal vollständig veröffentlicht, und der Minister sagte, dass es in den nächsten Wochen
+weitere Informationen geben werde. Wie ist das Wetter heute? Wir waren mit unseren Kindern auf dem
+Weg zum Markt, als es zu regnen begann, also sind wir zu Hause geblieben und haben einen Film
+gesehen. Bitte lesen Sie die Bedingungen, bevor Sie sich bei Ihrem Konto anmelden.`,
+	"fr": `Tous les êtres humains naissent libres et égaux en dignité et en droits. Ils sont doués de
+raison et de conscience et doivent agir les uns envers les autres dans un esprit de fraternité.
+Chacun peut se prévaloir de tous les droits et de toutes les libertés proclamés dans la présente
+Déclaration, sans distinction aucune, notamment de race, de couleur, de sexe, de langue, de
+religion, d'opinion politique ou de toute autre opinion, d'origine nationale ou sociale, de fortune,
+de naissance ou de toute autre situation. Tout individu a droit à la vie, à la liberté et à la
+sûreté de sa personne. Le conseil municipal a approuvé lundi un réseau de pistes cyclables qui
+reliera le centre-ville aux quartiers du nord. C'est la première fois que le gouvernement publie le
+rapport complet, et le ministre a déclaré que des informations supplémentaires seraient données dans
+les prochaines semaines. Quel temps fait-il aujourd'hui? Nous allions au marché avec nos enfants
+quand il a commencé à pleuvoir, alors nous sommes restés à la maison pour regarder un film.`,
+	"es": `Todos los seres humanos nacen libres e iguales en dignidad y derechos y, dotados como están
+de razón y conciencia, deben comportarse fraternalmente los unos con los otros. Toda persona tiene
+todos los derechos y libertades proclamados en esta Declaración, sin distinción alguna de raza,
+color, sexo, idioma, religión, opinión política o de cualquier otra índole, origen nacional o
+social, posición económica, nacimiento o cualquier otra condición. Todo individuo tiene derecho a
+la vida, a la libertad y a la seguridad de su persona. El ayuntamiento aprobó el lunes una red de
+carriles para bicicletas que unirá el centro de la ciudad con los barrios del norte. Es la primera
+vez que el gobierno publica el informe completo, y el ministro dijo que habrá más información sobre
+los planes en las próximas semanas. ¿Qué tiempo hace hoy? Íbamos al mercado con nuestros hijos
+cuando empezó a llover, así que nos quedamos en casa y vimos una película juntos.`,
+	"it": `Tutti gli esseri umani nascono liberi ed eguali in dignità e diritti. Essi sono dotati di
+ragione e di coscienza e devono agire gli uni verso gli altri in spirito di fratellanza. Ad ogni
+individuo spettano tutti i diritti e tutte le libertà enunciate nella presente Dichiarazione, senza
+distinzione alcuna, per ragioni di razza, di colore, di sesso, di lingua, di religione, di opinione
+politica o di altro genere, di origine nazionale o sociale, di ricchezza, di nascita o di altra
+condizione. Ogni individuo ha diritto alla vita, alla libertà ed alla sicurezza della propria
+persona. Il consiglio comunale ha approvato lunedì una rete di piste ciclabili che collegherà il
+centro della città con i quartieri del nord. È la prima volta che il governo pubblica il rapporto
+completo, e il ministro ha detto che ci saranno altre informazioni nelle prossime settimane. Che
+tempo fa oggi? Stavamo andando al mercato con i nostri figli quando ha cominciato a piovere, così
+siamo rimasti a casa e abbiamo guardato un film insieme.`,
+	"pt": `Todos os seres humanos nascem livres e iguais em dignidade e em direitos. Dotados de razão
+e de consciência, devem agir uns para com os outros em espírito de fraternidade. Todos os seres
+humanos podem invocar os direitos e as liberdades proclamados na presente Declaração, sem distinção
+alguma, nomeadamente de raça, de cor, de sexo, de língua, de religião, de opinião política ou outra,
+de origem nacional ou social, de fortuna, de nascimento ou de qualquer outra situação. Todo o
+indivíduo tem direito à vida, à liberdade e à segurança pessoal. A câmara municipal aprovou na
+segunda-feira uma rede de ciclovias que vai ligar o centro da cidade aos bairros do norte. É a
+primeira vez que o governo publica o relatório completo, e o ministro disse que haverá mais
+informações sobre os planos nas próximas semanas. Como está o tempo hoje? Nós íamos ao mercado com
+os nossos filhos quando começou a chover, então ficamos em casa e assistimos a um filme juntos.`,
+	"nl": `Alle mensen worden vrij en gelijk in waardigheid en rechten geboren. Zij zijn begiftigd met
+verstand en geweten, en behoren zich jegens elkander in een geest van broederschap te gedragen.
+Een ieder heeft aanspraak op alle rechten en vrijheden, in deze Verklaring opgesomd, zonder enig
+onderscheid van welke aard ook, zoals ras, kleur, geslacht, taal, godsdienst, politieke of andere
+overtuiging, nationale of maatschappelijke afkomst, eigendom, geboorte of andere status. Een ieder
+heeft het recht op leven, vrijheid en onschendbaarheid van zijn persoon. De gemeenteraad heeft
+maandag een netwerk van fietspaden goedgekeurd dat het centrum met de noordelijke wijken zal
+verbinden. Het is de eerste keer dat de regering het volledige rapport publiceert, en de minister
+zei dat er in de komende weken meer informatie over de plannen zal komen. Hoe is het weer vandaag?
+We gingen met onze kinderen naar de markt toen het begon te regenen, dus zijn we thuis gebleven.`,
+	"sv": `Alla människor är födda fria och lika i värde och rättigheter. De är utrustade med förnuft
+och samvete och bör handla gentemot varandra i en anda av broderskap. Var och en är berättigad till
+alla de rättigheter och friheter som uttalas i denna förklaring utan åtskillnad av något slag,
+såsom ras, hudfärg, kön, språk, religion, politisk eller annan uppfattning, nationellt eller socialt
+ursprung, egendom, börd eller ställning i övrigt. Var och en har rätt till liv, frihet och personlig
+säkerhet. Kommunfullmäktige beslutade på måndagen att bygga ett nät av cykelbanor som ska förbinda
+centrum med de norra förorterna. Det är första gången som regeringen publicerar hela rapporten, och
+ministern sade att det kommer mer information om planerna under de närmaste veckorna. Hur är vädret
+i dag? Vi var på väg till torget med våra barn när det började regna, så vi stannade hemma och
+tittade på en film tillsammans. Jag vet inte varför hon inte kom, men han sa att hon skulle komma
+senare. Hunden springer över gården medan katten sover på trappan. Läs villkoren innan du loggar in
+på ditt konto och se de senaste nyheterna från hela landet.`,
+	"pl": `Wszyscy ludzie rodzą się wolni i równi pod względem swej godności i swych praw. Są oni
+obdarzeni rozumem i sumieniem i powinni postępować wobec innych w duchu braterstwa. Każdy człowiek
+posiada wszystkie prawa i wolności zawarte w niniejszej Deklaracji bez względu na jakiekolwiek
+różnice rasy, koloru skóry, płci, języka, wyznania, poglądów politycznych i innych, narodowości,
+pochodzenia społecznego, majątku, urodzenia lub jakiegokolwiek innego stanu. Każdy człowiek ma
+prawo do życia, wolności i bezpieczeństwa swej osoby. Rada miasta zatwierdziła w poniedziałek sieć
+ścieżek rowerowych, które połączą centrum z północnymi dzielnicami. Po raz pierwszy rząd opublikował
+pełny raport, a minister powiedział, że więcej informacji o planach pojawi się w najbliższych
+tygodniach. Jaka jest dzisiaj pogoda? Szliśmy z dziećmi na targ, kiedy zaczęło padać, więc
+zostaliśmy w domu i obejrzeliśmy razem film.`,
+	"tr": `Bütün insanlar hür, haysiyet ve haklar bakımından eşit doğarlar. Akıl ve vicdana sahiptirler
+ve birbirlerine karşı kardeşlik zihniyeti ile hareket etmelidirler. Herkes, ırk, renk, cinsiyet,
+dil, din, siyasi veya diğer herhangi bir akide, milli veya içtimai menşe, servet, doğuş veya herhangi
+diğer bir fark gözetilmeksizin işbu Beyannamede ilan olunan tekmil haklardan ve bütün hürriyetlerden
+istifade edebilir. Yaşamak, hürriyet ve kişi emniyeti her ferdin hakkıdır. Belediye meclisi pazartesi
+günü şehir merkezini kuzey mahallelerine bağlayacak bir bisiklet yolu ağını onayladı. Hükümet raporun
+tamamını ilk kez yayımladı ve bakan önümüzdeki haftalarda planlar hakkında daha fazla bilgi
+verileceğini söyledi. Bugün hava nasıl? Çocuklarımızla pazara gidiyorduk ki yağmur yağmaya başladı,
+bu yüzden evde kaldık ve birlikte bir film izledik.`,
+	"id": `Semua orang dilahirkan merdeka dan mempunyai martabat dan hak-hak yang sama. Mereka
+dikaruniai akal dan hati nurani dan hendaknya bergaul satu sama lain dalam semangat persaudaraan.
+Setiap orang berhak atas semua hak dan kebebasan yang tercantum di dalam Pernyataan ini tanpa
+perkecualian apapun, seperti ras, warna kulit, jenis kelamin, bahasa, agama, politik atau pendapat
+yang berlainan, asal mula kebangsaan atau kemasyarakatan, hak milik, kelahiran ataupun kedudukan
+lain. Setiap orang berhak atas kehidupan, kebebasan dan keselamatan sebagai individu. Dewan kota
+pada hari Senin menyetujui jaringan jalur sepeda yang akan menghubungkan pusat kota dengan
+permukiman di utara. Ini adalah pertama kalinya pemerintah menerbitkan laporan lengkap, dan menteri
+mengatakan bahwa akan ada informasi lebih lanjut tentang rencana tersebut dalam beberapa minggu ke
+depan. Bagaimana cuaca hari ini? Kami sedang pergi ke pasar dengan anak-anak ketika hujan mulai turun.`,
+	"vi": `Tất cả mọi người sinh ra đều được tự do và bình đẳng về nhân phẩm và quyền lợi. Mọi con
+người đều được tạo hóa ban cho lý trí và lương tâm và cần phải đối xử với nhau trong tình anh em.
+Mọi người đều được hưởng tất cả những quyền và tự do nêu trong Bản tuyên ngôn này, không phân biệt
+chủng tộc, màu da, giới tính, ngôn ngữ, tôn giáo, quan điểm chính trị hoặc quan điểm khác, nguồn
+gốc dân tộc hoặc xã hội, tài sản, thành phần xuất thân hay địa vị khác. Mọi người đều có quyền sống,
+quyền tự do và an toàn cá nhân. Hội đồng thành phố đã thông qua vào thứ Hai một mạng lưới đường dành
+cho xe đạp nối trung tâm với các khu phố phía bắc. Đây là lần đầu tiên chính phủ công bố toàn bộ báo
+cáo, và bộ trưởng nói rằng sẽ có thêm thông tin về kế hoạch trong những tuần tới.`,
+	"ru": `Все люди рождаются свободными и равными в своем достоинстве и правах. Они наделены разумом и
+совестью и должны поступать в отношении друг друга в духе братства. Каждый человек должен обладать
+всеми правами и всеми свободами, провозглашенными настоящей Декларацией, без какого бы то ни было
+различия, как-то в отношении расы, цвета кожи, пола, языка, религии, политических или иных
+убеждений, национального или социального происхождения, имущественного, сословного или иного
+положения. Каждый человек имеет право на жизнь, на свободу и на личную неприкосновенность. Городской
+совет в понедельник одобрил сеть велосипедных дорожек, которая соединит центр города с северными
+районами. Правительство впервые опубликовало полный доклад, и министр сказал, что в ближайшие недели
+появится больше информации о планах. Какая сегодня погода? Мы шли на рынок с детьми, когда начался
+дождь, поэтому остались дома и вместе посмотрели фильм.`,
+	"uk": `Всі люди народжуються вільними і рівними у своїй гідності та правах. Вони наділені розумом
+і совістю і повинні діяти у відношенні один до одного в дусі братерства. Кожна людина повинна мати
+всі права і всі свободи, проголошені цією Декларацією, незалежно від раси, кольору шкіри, статі,
+мови, релігії, політичних або інших переконань, національного чи соціального походження, майнового,
+станового або іншого становища. Кожна людина має право на життя, на свободу і на особисту
+недоторканність. Міська рада в понеділок схвалила мережу велосипедних доріжок, яка з'єднає центр
+міста з північними районами. Уряд уперше оприлюднив повну доповідь, і міністр сказав, що найближчими
+тижнями з'явиться більше інформації про плани. Яка сьогодні погода? Ми йшли на ринок із дітьми, коли
+почався дощ, тому залишилися вдома і разом подивилися фільм.`,
+}
@@ -0,0 +1,177 @@
+package analyzer
+
+import (
+	"base"
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// 近似重复过滤器的参数容器的描述模板。
+var nearDupArgsTemplate string = "{ threshold: %d, minTextLength: %d," +
+	" skipLinks: %v, skipItems: %v }"
+
+// 近似重复过滤器的参数容器。
+type NearDupArgs struct {
+	Threshold     int  // 汉明距离的阈值。与已有指纹的距离不超过该值的网页被视为近似重复。
+	MinTextLength int  // 参与比较的网页的最小文本长度（字符数）。文本过短的网页不计算指纹。
+	SkipLinks     bool // 是否丢弃近似重复网页中的请求（即不跟随其中的链接）。
+	SkipItems     bool // 是否丢弃近似重复网页中的条目。
+}
+
+// 获得默认的近似重复过滤器的参数容器。
+func DefaultNearDupArgs() NearDupArgs {
+	return NearDupArgs{
+		Threshold:     3,
+		MinTextLength: 200,
+		SkipLinks:     true,
+		SkipItems:     true,
+	}
+}
+
+func (args *NearDupArgs) Check() error {
+	if args.Threshold < 0 || args.Threshold > 64 {
+		return errors.New("The hamming distance threshold must be in [0, 64]!\n")
+	}
+	if args.MinTextLength < 0 {
+		return errors.New("The min text length can not be negative!\n")
+	}
+	return nil
+}
+
+func (args *NearDupArgs) String() string {
+	return fmt.Sprintf(nearDupArgsTemplate,
+		args.Threshold, args.MinTextLength, args.SkipLinks, args.SkipItems)
+}
+
+// 近似重复过滤器的接口类型。
+// 它以网页文本的SimHash指纹识别镜像网页以及仅在会话ID等参数上不同的网页。
+type NearDupFilter interface {
+	// 包装响应解析函数。包装后的函数会在近似重复的网页上按策略丢弃请求和条目。
+	Wrap(respParser ParseResponse) ParseResponse
+	// 获得已检查的和被判定为近似重复的HTTP响应的计数值。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建近似重复过滤器。参数index应每次爬取单独创建。
+func NewNearDupFilter(args NearDupArgs, index FingerprintIndex) (NearDupFilter, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	if index == nil {
+		return nil, errors.New("The fingerprint index is invalid!")
+	}
+	return &myNearDupFilter{args: args, index: index}, nil
+}
+
+// 近似重复过滤器的实现类型。
+type myNearDupFilter struct {
+	args       NearDupArgs      // 参数。
+	index      FingerprintIndex // 指纹索引。
+	checked    uint64           // 已检查的HTTP响应的数量。
+	duplicated uint64           // 被判定为近似重复的HTTP响应的数量。
+}
+
+func (filter *myNearDupFilter) Wrap(respParser ParseResponse) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		if httpResp.StatusCode != 200 {
+			return respParser(httpResp, respDepth)
+		}
+		body, err := readBody(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		httpResp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		dataList, errorList := respParser(httpResp, respDepth)
+		if !filter.args.SkipLinks && !filter.args.SkipItems {
+			return dataList, errorList
+		}
+		text := body
+		if strings.Contains(mediaType(httpResp, body), "html") {
+			text = []byte(visibleText(bytes.NewReader(body)))
+		}
+		if len([]rune(string(text))) < filter.args.MinTextLength {
+			return dataList, errorList
+		}
+		atomic.AddUint64(&filter.checked, 1)
+		reqUrl := httpResp.Request.URL.String()
+		dupUrl, dup := filter.index.CheckAndAdd(SimHash(string(text)), reqUrl, filter.args.Threshold)
+		if !dup {
+			return dataList, errorList
+		}
+		atomic.AddUint64(&filter.duplicated, 1)
+		logger.Infof("Near-duplicate page (reqUrl=%s, duplicateOf=%s)\n", reqUrl, dupUrl)
+		kept := make([]base.Data, 0, len(dataList))
+		for _, data := range dataList {
+			switch data.(type) {
+			case *base.Request:
+				if filter.args.SkipLinks {
+					continue
+				}
+			case *base.Item:
+				if filter.args.SkipItems {
+					continue
+				}
+			}
+			kept = append(kept, data)
+		}
+		return kept, errorList
+	}
+}
+
+// 非可见内容的元素。
+var invisibleTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "head": true,
+}
+
+// 获得HTML文档中的可见文本。
+func visibleText(reader io.Reader) string {
+	tokenizer := html.NewTokenizer(reader)
+	var buffer bytes.Buffer
+	skipDepth := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(buffer.String()), " ")
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if invisibleTags[string(name)] {
+				skipDepth++
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if invisibleTags[string(name)] && skipDepth > 0 {
+				skipDepth--
+			}
+		case html.TextToken:
+			if skipDepth == 0 {
+				buffer.Write(tokenizer.Text())
+				buffer.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func (filter *myNearDupFilter) Count() []uint64 {
+	counts := make([]uint64, 2)
+	counts[0] = atomic.LoadUint64(&filter.checked)
+	counts[1] = atomic.LoadUint64(&filter.duplicated)
+	return counts
+}
+
+// 摘要信息模板。
+var nearDupSummaryTemplate = "threshold: %d, fingerprints: %d, checked: %d, duplicated: %d"
+
+func (filter *myNearDupFilter) Summary() string {
+	counts := filter.Count()
+	return fmt.Sprintf(nearDupSummaryTemplate,
+		filter.args.Threshold, filter.index.Count(), counts[0], counts[1])
+}
@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"base"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 生成一个条目和一个请求，并检查响应体是否仍可读取的解析函数。
+func itemAndLinkParser(t *testing.T) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		body, err := ioutil.ReadAll(httpResp.Body)
+		if err != nil || len(body) == 0 {
+			t.Errorf("The wrapped parser should read the whole body: %v", err)
+		}
+		item := base.Item{"url": httpResp.Request.URL.String()}
+		httpReq, _ := http.NewRequest("GET", "http://example.com/next", nil)
+		return []base.Data{&item, base.NewRequest(httpReq, respDepth+1)}, nil
+	}
+}
+
+// 统计解析结果中的条目和请求的数量。
+func countData(dataList []base.Data) (items int, reqs int) {
+	for _, data := range dataList {
+		switch data.(type) {
+		case *base.Item:
+			items++
+		case *base.Request:
+			reqs++
+		}
+	}
+	return
+}
+
+func TestNearDupFilter(t *testing.T) {
+	text := strings.Join(randomWords(1, 1000), " ")
+	page := "<html><head><title>News</title><script>var session = 1;</script></head><body><p>" +
+		text + "</p></body></html>"
+	mirrored := strings.Replace(page, "var session = 1;", "var session = 2;", 1) +
+		"<p>session 8f3a9c</p>"
+	filter, err := NewNearDupFilter(DefaultNearDupArgs(), NewFingerprintIndex())
+	if err != nil {
+		t.Fatalf("Can not create the filter: %s", err)
+	}
+	parser := filter.Wrap(itemAndLinkParser(t))
+	dataList, _ := parser(newTestResponse(t, "http://example.com/a", "text/html", page), 0)
+	if items, reqs := countData(dataList); items != 1 || reqs != 1 {
+		t.Errorf("The first page should be kept: %d items, %d requests", items, reqs)
+	}
+	dataList, _ = parser(newTestResponse(t, "http://example.com/a?sid=2", "text/html", mirrored), 0)
+	if items, reqs := countData(dataList); items != 0 || reqs != 0 {
+		t.Errorf("The mirrored page should be dropped: %d items, %d requests", items, reqs)
+	}
+	other := "<html><body>" + strings.Join(randomWords(2, 1000), " ") + "</body></html>"
+	dataList, _ = parser(newTestResponse(t, "http://example.com/b", "text/html", other), 0)
+	if items, reqs := countData(dataList); items != 1 || reqs != 1 {
+		t.Errorf("A different page should be kept: %d items, %d requests", items, reqs)
+	}
+	// 文本过短的网页不参与比较。
+	short := "<html><body>Not found</body></html>"
+	for i := 0; i < 2; i++ {
+		dataList, _ = parser(newTestResponse(t, "http://example.com/404", "text/html", short), 0)
+		if items, reqs := countData(dataList); items != 1 || reqs != 1 {
+			t.Errorf("A short page should be kept: %d items, %d requests", items, reqs)
+		}
+	}
+	if counts := filter.Count(); counts[0] != 3 || counts[1] != 1 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+func TestNearDupFilterPolicy(t *testing.T) {
+	page := "<html><body>" + strings.Join(randomWords(1, 1000), " ") + "</body></html>"
+	args := DefaultNearDupArgs()
+	args.SkipItems = false
+	filter, _ := NewNearDupFilter(args, NewFingerprintIndex())
+	parser := filter.Wrap(itemAndLinkParser(t))
+	parser(newTestResponse(t, "http://example.com/a", "text/html", page), 0)
+	dataList, _ := parser(newTestResponse(t, "http://example.com/b", "text/html", page), 0)
+	if items, reqs := countData(dataList); items != 1 || reqs != 0 {
+		t.Errorf("Only the requests should be dropped: %d items, %d requests", items, reqs)
+	}
+	invalid := []NearDupArgs{{Threshold: -1}, {Threshold: 65}, {MinTextLength: -1}}
+	for _, args := range invalid {
+		if _, err := NewNearDupFilter(args, NewFingerprintIndex()); err == nil {
+			t.Errorf("The args should be invalid: %s", args.String())
+		}
+	}
+	if _, err := NewNearDupFilter(DefaultNearDupArgs(), nil); err == nil {
+		t.Errorf("A nil index should be rejected")
+	}
+}
@@ -0,0 +1,462 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 默认的可能是非正文的元素的class和id的正则表达式。
+var DEFAULT_UNLIKELY_PATTERN = `(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|` +
+	`footer|gdpr|header|legends|menu|modal|nav|pager|pagination|popup|related|remark|` +
+	`replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tags|tool|widget`
+
+// 默认的可能是正文的元素的class和id的正则表达式。匹配此表达式的元素不会被当作非正文元素去除。
+var DEFAULT_POSITIVE_PATTERN = `(?i)article|body|column|content|entry|hentry|main|page|post|story|text`
+
+// 默认的倾向于非正文的元素的class和id的正则表达式。
+var DEFAULT_NEGATIVE_PATTERN = `(?i)-ad-|ad-break|agegate|byline|caption|comment|foot|hidden|` +
+	`masthead|media|meta|outbrain|promo|scroll|shoutbox|sidebar|sponsor|widget`
+
+// 总是会被去除的元素。
+var removedTags = "script, style, noscript, template, iframe, form, nav, aside, footer, svg, canvas, button, select"
+
+// 正文解析函数的参数容器的描述模板。
+var contentArgsTemplate string = "{ minParagraphLength: %d, minTextLength: %d," +
+	" siblingThreshold: %v, unlikelyPattern: %s, positivePattern: %s," +
+	" negativePattern: %s, links: %v }"
+
+// 正文解析函数的参数容器。
+type ContentArgs struct {
+	MinParagraphLength int       // 参与评分的段落的最小长度（见textWeight）。
+	MinTextLength      int       // 正文的最小长度（见textWeight）。正文短于此值的网页不会生成条目。
+	SiblingThreshold   float64   // 兄弟元素被并入正文所需的得分与最高得分之比。
+	UnlikelyPattern    string    // 可能是非正文的元素的class和id的正则表达式。
+	PositivePattern    string    // 可能是正文的元素的class和id的正则表达式。
+	NegativePattern    string    // 倾向于非正文的元素的class和id的正则表达式。
+	Links              *LinkArgs // 链接发现的参数。不为nil时会在提取正文的同时发现链接。
+}
+
+// 获得默认的正文解析函数的参数容器。
+func DefaultContentArgs() ContentArgs {
+	return ContentArgs{
+		MinParagraphLength: 25,
+		MinTextLength:      250,
+		SiblingThreshold:   0.2,
+		UnlikelyPattern:    DEFAULT_UNLIKELY_PATTERN,
+		PositivePattern:    DEFAULT_POSITIVE_PATTERN,
+		NegativePattern:    DEFAULT_NEGATIVE_PATTERN,
+	}
+}
+
+func (args *ContentArgs) Check() error {
+	if args.MinParagraphLength <= 0 {
+		return errors.New("The min paragraph length must be positive!\n")
+	}
+	if args.SiblingThreshold <= 0 || args.SiblingThreshold > 1 {
+		return errors.New("The sibling threshold must be in (0, 1]!\n")
+	}
+	for _, pattern := range []string{args.UnlikelyPattern, args.PositivePattern, args.NegativePattern} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return err
+		}
+	}
+	if args.Links != nil {
+		return args.Links.Check()
+	}
+	return nil
+}
+
+func (args *ContentArgs) String() string {
+	var links string
+	if args.Links != nil {
+		links = args.Links.String()
+	}
+	return fmt.Sprintf(contentArgsTemplate,
+		args.MinParagraphLength, args.MinTextLength, args.SiblingThreshold,
+		args.UnlikelyPattern, args.PositivePattern, args.NegativePattern, links)
+}
+
+// 网页正文的提取结果。
+type Content struct {
+	Title     string // 标题。
+	Byline    string // 作者。
+	Published string // 发布时间。能被识别的时间会被转换为RFC3339格式。
+	Text      string // 正文文本。段落之间以空行分隔。
+	Language  string // 声明的语言，如zh-CN。
+}
+
+// 正文提取器。
+type contentExtractor struct {
+	args     ContentArgs
+	unlikely *regexp.Regexp
+	positive *regexp.Regexp
+	negative *regexp.Regexp
+}
+
+// 创建正文解析函数。
+// 生成的条目中包含如下字段：parent_url、content.title、content.byline、content.published、
+// content.text、content.language和content.length（正文的字符数）。
+func NewContentParser(args ContentArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	extractor := &contentExtractor{
+		args:     args,
+		unlikely: regexp.MustCompile(args.UnlikelyPattern),
+		positive: regexp.MustCompile(args.PositivePattern),
+		negative: regexp.MustCompile(args.NegativePattern),
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		// 链接需在提取正文之前发现，因为提取正文时会去除导航等元素。
+		if args.Links != nil {
+			CollectLinks(doc, baseUrl, *args.Links, c)
+		}
+		content := extractor.extract(doc, httpResp.Header.Get("Content-Language"))
+		textLength := len([]rune(content.Text))
+		if textWeight(content.Text) >= args.MinTextLength {
+			c.AddItem(map[string]interface{}{
+				"content.title":     content.Title,
+				"content.byline":    content.Byline,
+				"content.published": content.Published,
+				"content.text":      content.Text,
+				"content.language":  content.Language,
+				"content.length":    textLength,
+			})
+		}
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 从文档中提取正文。注意，该方法会修改文档。
+func (e *contentExtractor) extract(doc *goquery.Document, headerLanguage string) Content {
+	content := Content{
+		Title:     extractTitle(doc),
+		Byline:    extractByline(doc),
+		Published: extractPublished(doc),
+		Language:  extractLanguage(doc, headerLanguage),
+	}
+	e.removeBoilerplate(doc)
+	content.Text = e.extractText(doc)
+	return content
+}
+
+// 中日韩文字的每个字符在计算文本长度时相当于的字符数。
+// 这些文字的信息密度远高于拼音文字，若按字符数计算，同样篇幅的正文会因过短而被忽略。
+const CJK_CHAR_WEIGHT = 3
+
+// 获得文本的长度，即字符数，其中中日韩文字的字符按CJK_CHAR_WEIGHT计算。
+func textWeight(text string) int {
+	weight := 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			weight += CJK_CHAR_WEIGHT
+		} else {
+			weight++
+		}
+	}
+	return weight
+}
+
+// 获得元素的class和id。
+func classAndId(sel *goquery.Selection) string {
+	class, _ := sel.Attr("class")
+	id, _ := sel.Attr("id")
+	return class + " " + id
+}
+
+// 去除不可能是正文的元素。
+func (e *contentExtractor) removeBoilerplate(doc *goquery.Document) {
+	doc.Find(removedTags).Remove()
+	doc.Find("*").Each(func(index int, sel *goquery.Selection) {
+		tag := goquery.NodeName(sel)
+		if tag == "html" || tag == "body" || tag == "article" || tag == "main" {
+			return
+		}
+		ci := classAndId(sel)
+		if strings.TrimSpace(ci) == "" {
+			return
+		}
+		if e.unlikely.MatchString(ci) && !e.positive.MatchString(ci) {
+			sel.Remove()
+		}
+	})
+}
+
+// 各个标签的初始得分。
+var tagScores = map[string]float64{
+	"article":    10,
+	"main":       8,
+	"div":        5,
+	"section":    3,
+	"pre":        3,
+	"td":         3,
+	"blockquote": 3,
+	"form":       -3,
+	"ol":         -3,
+	"ul":         -3,
+	"dl":         -3,
+	"li":         -3,
+	"th":         -5,
+	"h1":         -5,
+	"h2":         -5,
+	"h3":         -5,
+}
+
+// 依据元素的class和id获得其得分的加成。
+func (e *contentExtractor) classWeight(sel *goquery.Selection) float64 {
+	ci := classAndId(sel)
+	var weight float64
+	if e.positive.MatchString(ci) {
+		weight += 25
+	}
+	if e.negative.MatchString(ci) {
+		weight -= 25
+	}
+	return weight
+}
+
+// 获得元素中的链接文本占全部文本的比例。
+func linkDensity(sel *goquery.Selection) float64 {
+	textLength := len([]rune(normalizeSpace(sel.Text())))
+	if textLength == 0 {
+		return 0
+	}
+	var linkLength int
+	sel.Find("a").Each(func(index int, a *goquery.Selection) {
+		linkLength += len([]rune(normalizeSpace(a.Text())))
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+// 合并文本中的连续空白。
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// 为段落的祖先元素评分，并获得得分最高的元素作为正文容器，然后从中提取正文文本。
+func (e *contentExtractor) extractText(doc *goquery.Document) string {
+	scores := make(map[*html.Node]float64)
+	candidates := make([]*goquery.Selection, 0)
+	initScore := func(sel *goquery.Selection) {
+		node := sel.Get(0)
+		if _, ok := scores[node]; ok {
+			return
+		}
+		scores[node] = tagScores[goquery.NodeName(sel)] + e.classWeight(sel)
+		candidates = append(candidates, sel)
+	}
+	doc.Find("p, pre, td, blockquote, li").Each(func(index int, sel *goquery.Selection) {
+		text := normalizeSpace(sel.Text())
+		textLength := textWeight(text)
+		if textLength < e.args.MinParagraphLength {
+			return
+		}
+		// 逗号（包括中文逗号）越多，越可能是正文。
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，"))
+		score += math.Min(float64(textLength)/100, 3)
+		parent := sel.Parent()
+		if parent.Length() == 0 {
+			return
+		}
+		initScore(parent)
+		scores[parent.Get(0)] += score
+		grandparent := parent.Parent()
+		if grandparent.Length() > 0 {
+			initScore(grandparent)
+			scores[grandparent.Get(0)] += score / 2
+		}
+	})
+	var top *goquery.Selection
+	var topScore float64
+	for _, candidate := range candidates {
+		node := candidate.Get(0)
+		scores[node] *= 1 - linkDensity(candidate)
+		if top == nil || scores[node] > topScore {
+			top = candidate
+			topScore = scores[node]
+		}
+	}
+	if top == nil {
+		top = doc.Find("body")
+	}
+	// 将得分足够高的兄弟元素并入正文。
+	blocks := make([]*goquery.Selection, 0)
+	threshold := math.Max(10, topScore*e.args.SiblingThreshold)
+	top.Parent().Children().Each(func(index int, sibling *goquery.Selection) {
+		if sibling.Get(0) == top.Get(0) {
+			blocks = append(blocks, sibling)
+			return
+		}
+		if score, ok := scores[sibling.Get(0)]; ok && score >= threshold {
+			blocks = append(blocks, sibling)
+		}
+	})
+	if len(blocks) == 0 {
+		blocks = append(blocks, top)
+	}
+	paragraphs := make([]string, 0)
+	for _, block := range blocks {
+		paragraphs = append(paragraphs, e.blockParagraphs(block)...)
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// 获得正文容器中的段落文本。没有段落元素的容器以其全部文本作为一个段落。
+// 倾向于非正文的段落（如作者栏和图片说明）会被忽略。
+func (e *contentExtractor) blockParagraphs(block *goquery.Selection) []string {
+	paragraphs := make([]string, 0)
+	block.Find("p, pre, blockquote, li, h2, h3, h4").Each(func(index int, sel *goquery.Selection) {
+		// 嵌套的段落元素只取最外层的。
+		if sel.ParentsUntilSelection(block).Filter("p, pre, blockquote, li").Length() > 0 {
+			return
+		}
+		if e.negative.MatchString(classAndId(sel)) {
+			return
+		}
+		text := normalizeSpace(sel.Text())
+		if text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	})
+	if len(paragraphs) == 0 {
+		if text := normalizeSpace(block.Text()); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return paragraphs
+}
+
+// 标题中常见的站点名称分隔符。
+var titleSeparators = regexp.MustCompile(`\s*[|_»]\s*|\s+[-–—]\s+`)
+
+// 提取标题。优先使用og:title，其次是去掉站点名称后的title元素，最后是第一个h1元素。
+func extractTitle(doc *goquery.Document) string {
+	if title, exists := doc.Find(`meta[property="og:title"]`).Attr("content"); exists &&
+		strings.TrimSpace(title) != "" {
+		return normalizeSpace(title)
+	}
+	title := normalizeSpace(doc.Find("title").First().Text())
+	if title != "" {
+		parts := titleSeparators.Split(title, -1)
+		// 取最长的部分，以去掉站点名称。
+		longest := parts[0]
+		for _, part := range parts[1:] {
+			if len([]rune(part)) > len([]rune(longest)) {
+				longest = part
+			}
+		}
+		if len(strings.Fields(longest)) >= 2 || len([]rune(longest)) >= 6 {
+			return longest
+		}
+		return title
+	}
+	return normalizeSpace(doc.Find("h1").First().Text())
+}
+
+// 提取作者。
+func extractByline(doc *goquery.Document) string {
+	for _, selector := range []string{
+		`meta[name="author"]`, `meta[property="article:author"]`,
+	} {
+		if author, exists := doc.Find(selector).Attr("content"); exists &&
+			strings.TrimSpace(author) != "" {
+			return normalizeSpace(author)
+		}
+	}
+	for _, selector := range []string{
+		`[itemprop="author"]`, `[rel="author"]`, `.byline`, `.author`,
+	} {
+		if author := normalizeSpace(doc.Find(selector).First().Text()); author != "" {
+			return author
+		}
+	}
+	return ""
+}
+
+// 被认可的发布时间的格式。
+var publishedLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123,
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	time.RFC822,
+	time.RFC822Z,
+}
+
+// 将能被识别的时间转换为RFC3339格式，不能识别的时间原样返回。
+func normalizeTime(raw string) string {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range publishedLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return raw
+}
+
+// 提取发布时间。
+func extractPublished(doc *goquery.Document) string {
+	var raw string
+	for _, selector := range []string{
+		`meta[property="article:published_time"]`, `meta[itemprop="datePublished"]`,
+		`meta[name="pubdate"]`, `meta[name="publishdate"]`, `meta[name="date"]`,
+		`meta[name="dc.date"]`, `meta[name="DC.date.issued"]`,
+	} {
+		if value, exists := doc.Find(selector).Attr("content"); exists && strings.TrimSpace(value) != "" {
+			raw = value
+			break
+		}
+	}
+	if raw == "" {
+		if value, exists := doc.Find("time[datetime]").First().Attr("datetime"); exists {
+			raw = value
+		}
+	}
+	return normalizeTime(raw)
+}
+
+// 提取声明的语言。
+func extractLanguage(doc *goquery.Document, headerLanguage string) string {
+	if lang, exists := doc.Find("html").Attr("lang"); exists && strings.TrimSpace(lang) != "" {
+		return strings.TrimSpace(lang)
+	}
+	doc.Find("meta[http-equiv]").EachWithBreak(func(index int, sel *goquery.Selection) bool {
+		equiv, _ := sel.Attr("http-equiv")
+		if strings.EqualFold(strings.TrimSpace(equiv), "content-language") {
+			if lang, exists := sel.Attr("content"); exists {
+				headerLanguage = lang
+				return false
+			}
+		}
+		return true
+	})
+	if index := strings.Index(headerLanguage, ","); index >= 0 {
+		headerLanguage = headerLanguage[:index]
+	}
+	return strings.TrimSpace(headerLanguage)
+}
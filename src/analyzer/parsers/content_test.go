@@ -0,0 +1,117 @@
+package parsers
+
+import (
+	"base"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 正文提取的期望结果。
+type expectedContent struct {
+	File           string   `json:"file"`
+	Title          string   `json:"title"`
+	Byline         string   `json:"byline"`
+	Published      string   `json:"published"`
+	Language       string   `json:"language"`
+	TextStartsWith string   `json:"textStartsWith"`
+	TextExcludes   []string `json:"textExcludes"`
+}
+
+// 加载测试网页并把它包装为HTTP响应。
+func loadFixture(t *testing.T, file string) *http.Response {
+	f, err := os.Open(filepath.Join("testdata", "content", file))
+	if err != nil {
+		t.Fatalf("Can not open the fixture: %s", err)
+	}
+	httpReq, err := http.NewRequest("GET", "http://example.com/"+file, nil)
+	if err != nil {
+		t.Fatalf("Can not create the request: %s", err)
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	return &http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       f,
+		Request:    httpReq,
+	}
+}
+
+func TestContentParser(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "content", "expected.json"))
+	if err != nil {
+		t.Fatalf("Can not read the expectations: %s", err)
+	}
+	var cases []expectedContent
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("Invalid expectations: %s", err)
+	}
+	parser, err := NewContentParser(DefaultContentArgs())
+	if err != nil {
+		t.Fatalf("Can not create the content parser: %s", err)
+	}
+	for _, c := range cases {
+		t.Run(c.File, func(t *testing.T) {
+			dataList, errs := parser(loadFixture(t, c.File), 0)
+			if len(errs) > 0 {
+				t.Fatalf("Unexpected errors: %v", errs)
+			}
+			var item base.Item
+			for _, d := range dataList {
+				if i, ok := d.(*base.Item); ok {
+					item = *i
+				}
+			}
+			if item == nil {
+				t.Fatalf("No item is generated (%d data)", len(dataList))
+			}
+			fields := map[string]string{
+				"content.title":     c.Title,
+				"content.byline":    c.Byline,
+				"content.published": c.Published,
+				"content.language":  c.Language,
+			}
+			for name, expected := range fields {
+				if actual, _ := item[name].(string); actual != expected {
+					t.Errorf("Unexpected %s: %q (expected %q)", name, actual, expected)
+				}
+			}
+			text, _ := item["content.text"].(string)
+			if !strings.HasPrefix(text, c.TextStartsWith) {
+				t.Errorf("The text should start with %q, but it is %q", c.TextStartsWith, text)
+			}
+			for _, excluded := range c.TextExcludes {
+				if strings.Contains(text, excluded) {
+					t.Errorf("The text should not contain %q", excluded)
+				}
+			}
+			if item.Type() != "content" {
+				t.Errorf("Unexpected item type: %q", item.Type())
+			}
+		})
+	}
+}
+
+func TestTextWeight(t *testing.T) {
+	cases := []struct {
+		text   string
+		weight int
+	}{
+		{"", 0},
+		{"abc def", 7},
+		{"地铁", 2 * CJK_CHAR_WEIGHT},
+		{"3号线", 1 + 2*CJK_CHAR_WEIGHT},
+		{"カタカナ", 4 * CJK_CHAR_WEIGHT},
+		{"한국어", 3 * CJK_CHAR_WEIGHT},
+	}
+	for _, c := range cases {
+		if weight := textWeight(c.text); weight != c.weight {
+			t.Errorf("Unexpected weight of %q: %d (expected %d)", c.text, weight, c.weight)
+		}
+	}
+}
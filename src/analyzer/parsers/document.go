@@ -0,0 +1,180 @@
+package parsers
+
+import (
+	"base"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 不会被生成请求的网址方案。
+var ignoredSchemes = map[string]bool{
+	"javascript": true,
+	"mailto":     true,
+	"tel":        true,
+	"data":       true,
+	"about":      true,
+	"ftp":        true,
+}
+
+// 判断HTTP响应的内容是否为HTML。未声明内容类型的响应也会被视为HTML。
+func IsHtml(httpResp *http.Response) bool {
+	contentType := strings.ToLower(httpResp.Header.Get("Content-Type"))
+	return contentType == "" || strings.Contains(contentType, "html")
+}
+
+// 加载HTML文档，并获得文档中的相对网址所依据的基础网址。
+// 若HTTP响应的状态码不是200或者其内容不是HTML，则结果值中的文档为nil且错误值也为nil。
+func LoadDocument(httpResp *http.Response) (*goquery.Document, *url.URL, error) {
+	if httpResp.Body != nil {
+		defer httpResp.Body.Close()
+	}
+	if httpResp.StatusCode != 200 || !IsHtml(httpResp) || httpResp.Body == nil {
+		return nil, nil, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(httpResp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, BaseUrl(doc, httpResp.Request.URL), nil
+}
+
+// 获得文档中的相对网址所依据的基础网址。
+// 若文档中存在有效的<base href>，则以其为准，否则使用请求的网址。
+func BaseUrl(doc *goquery.Document, reqUrl *url.URL) *url.URL {
+	href, exists := doc.Find("base[href]").First().Attr("href")
+	if !exists {
+		return reqUrl
+	}
+	baseUrl, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return reqUrl
+	}
+	return reqUrl.ResolveReference(baseUrl)
+}
+
+// 依据基础网址解析链接地址。网址中的片段部分会被去除。
+// 对于空的、仅含片段的以及使用不被支持的方案（如javascript、mailto）的链接地址，结果值均为nil。
+func ResolveHref(baseUrl *url.URL, href string) (*url.URL, error) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return nil, nil
+	}
+	if index := strings.Index(href, ":"); index > 0 {
+		scheme := strings.ToLower(href[:index])
+		if ignoredSchemes[scheme] {
+			return nil, nil
+		}
+	}
+	hrefUrl, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	if !hrefUrl.IsAbs() {
+		hrefUrl = baseUrl.ResolveReference(hrefUrl)
+	}
+	if hrefUrl.Scheme != "http" && hrefUrl.Scheme != "https" {
+		return nil, nil
+	}
+	hrefUrl.Fragment = ""
+	return hrefUrl, nil
+}
+
+// 判断rel属性值中是否含有指定的链接类型。
+func HasRel(rel string, linkType string) bool {
+	for _, field := range strings.Fields(strings.ToLower(rel)) {
+		if field == linkType {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析结果的收集器。同一网址只会被生成一次请求。
+type Collector struct {
+	reqUrl    *url.URL        // 被解析的网页的网址。
+	parentUrl string          // 被解析的网页的网址的字符串形式。
+	respDepth uint32          // 响应的深度。
+	dataList  []base.Data     // 数据列表。
+	errs      []error         // 错误列表。
+	requested map[string]bool // 已生成的请求的键。
+}
+
+// 创建解析结果的收集器。
+func NewCollector(httpResp *http.Response, respDepth uint32) *Collector {
+	return &Collector{
+		reqUrl:    httpResp.Request.URL,
+		parentUrl: httpResp.Request.URL.String(),
+		respDepth: respDepth,
+		dataList:  make([]base.Data, 0),
+		errs:      make([]error, 0),
+		requested: make(map[string]bool),
+	}
+}
+
+// 为网址生成GET请求。请求的深度比响应的深度大1。
+func (c *Collector) AddRequest(reqUrl *url.URL) {
+	urlStr := reqUrl.String()
+	if c.requested[urlStr] {
+		return
+	}
+	httpReq, err := http.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		c.AddError(err)
+		return
+	}
+	c.requested[urlStr] = true
+	c.dataList = append(c.dataList, base.NewRequest(httpReq, c.respDepth+1))
+}
+
+// 添加已生成的请求，如带有请求体的POST请求或与响应深度相同的翻页请求。请求的深度保持不变。
+func (c *Collector) AddCustomRequest(req *base.Request) {
+	key := req.Key()
+	if c.requested[key] {
+		return
+	}
+	c.requested[key] = true
+	c.dataList = append(c.dataList, req)
+}
+
+// 添加条目。条目中会被加入被解析的网页的网址。
+// 若条目未标记类型，且其字段名称都带有相同的前缀（如"link.url"和"link.text"中的"link"），则该前缀会被用作条目的类型。
+func (c *Collector) AddItem(imap map[string]interface{}) {
+	if _, ok := imap[base.ITEM_TYPE_FIELD]; !ok {
+		if itemType := commonPrefix(imap); itemType != "" {
+			imap[base.ITEM_TYPE_FIELD] = itemType
+		}
+	}
+	imap["parent_url"] = c.parentUrl
+	item := base.Item(imap)
+	c.dataList = append(c.dataList, &item)
+}
+
+// 获得各个字段名称中第一个点号之前的公共前缀。没有公共前缀时结果值为空字符串。
+func commonPrefix(imap map[string]interface{}) string {
+	prefix := ""
+	for name := range imap {
+		if name == "parent_url" {
+			continue
+		}
+		i := strings.Index(name, ".")
+		if i <= 0 || (prefix != "" && name[:i] != prefix) {
+			return ""
+		}
+		prefix = name[:i]
+	}
+	return prefix
+}
+
+// 添加错误。
+func (c *Collector) AddError(err error) {
+	if err != nil {
+		c.errs = append(c.errs, err)
+	}
+}
+
+// 获得解析结果。
+func (c *Collector) Result() ([]base.Data, []error) {
+	return c.dataList, c.errs
+}
@@ -0,0 +1,354 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// 订阅源的格式。
+const (
+	FEED_RSS  = "rss"  // RSS 2.0（以及0.9x）。
+	FEED_ATOM = "atom" // Atom 1.0。
+	FEED_RDF  = "rdf"  // RSS 1.0（RDF）。
+)
+
+// 订阅源的最大字节数。
+const FEED_MAX_SIZE = 10 * 1024 * 1024
+
+// 订阅源解析函数的参数容器的描述模板。
+var feedArgsTemplate string = "{ followEntries: %v, emitItems: %v, maxEntries: %d }"
+
+// 订阅源解析函数的参数容器。
+type FeedArgs struct {
+	FollowEntries bool // 是否为条目的链接生成请求。
+	EmitItems     bool // 是否为每个条目生成条目。
+	MaxEntries    int  // 每个订阅源最多处理的条目数量。为0表示不限制。
+}
+
+// 获得默认的订阅源解析函数的参数容器。
+func DefaultFeedArgs() FeedArgs {
+	return FeedArgs{FollowEntries: true, EmitItems: true}
+}
+
+func (args *FeedArgs) Check() error {
+	if !args.FollowEntries && !args.EmitItems {
+		return errors.New("The feed parser would produce nothing!\n")
+	}
+	if args.MaxEntries < 0 {
+		return errors.New("The max entry number can not be negative!\n")
+	}
+	return nil
+}
+
+func (args *FeedArgs) String() string {
+	return fmt.Sprintf(feedArgsTemplate, args.FollowEntries, args.EmitItems, args.MaxEntries)
+}
+
+// 订阅源中的条目。
+type FeedEntry struct {
+	Title     string // 标题。
+	Link      string // 链接（未解析的原始值）。
+	Published string // 发布时间。能被识别的时间会被转换为RFC3339格式。
+	Author    string // 作者。
+	Summary   string // 摘要（纯文本）。
+}
+
+// 订阅源。
+type Feed struct {
+	Format  string      // 格式。
+	Title   string      // 标题。
+	Entries []FeedEntry // 条目的列表。
+}
+
+// 用于解码订阅源的结构。RSS 2.0、RSS 1.0和Atom 1.0共用此结构，元素只按本地名称匹配。
+type xmlFeed struct {
+	XMLName xml.Name
+	Title   string `xml:"title"`
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []xmlItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []xmlItem  `xml:"item"`  // RSS 1.0的条目与channel元素同级。
+	Entries []xmlEntry `xml:"entry"` // Atom的条目。
+}
+
+// RSS条目。
+type xmlItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Guid        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"date"` // dc:date
+	Author      string `xml:"author"`
+	Creator     string `xml:"creator"` // dc:creator
+	Description string `xml:"description"`
+	Encoded     string `xml:"encoded"` // content:encoded
+}
+
+// Atom条目。
+type xmlEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Summary string `xml:"summary"`
+	Content string `xml:"content"`
+}
+
+// 判断文档是否为订阅源，即其根元素是否为rss、rdf:RDF或feed。
+func IsFeed(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch strings.ToLower(start.Name.Local) {
+			case "rss", "rdf", "feed":
+				return true
+			}
+			return false
+		}
+	}
+}
+
+// 解析订阅源。不是订阅源的XML文档会导致一个错误。
+func ParseFeed(reader io.Reader) (*Feed, error) {
+	decoder := xml.NewDecoder(io.LimitReader(reader, FEED_MAX_SIZE))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	var doc xmlFeed
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	feed := &Feed{Entries: make([]FeedEntry, 0)}
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss":
+		feed.Format = FEED_RSS
+		feed.Title = doc.Channel.Title
+		for _, item := range doc.Channel.Items {
+			feed.Entries = append(feed.Entries, item.entry())
+		}
+	case "rdf":
+		feed.Format = FEED_RDF
+		feed.Title = doc.Channel.Title
+		for _, item := range doc.Items {
+			feed.Entries = append(feed.Entries, item.entry())
+		}
+	case "feed":
+		feed.Format = FEED_ATOM
+		feed.Title = doc.Title
+		for _, entry := range doc.Entries {
+			feed.Entries = append(feed.Entries, entry.entry())
+		}
+	default:
+		return nil, errors.New(
+			fmt.Sprintf("Unsupported feed root element '%s'!", doc.XMLName.Local))
+	}
+	feed.Title = normalizeSpace(feed.Title)
+	return feed, nil
+}
+
+// 将RSS条目转换为订阅源条目。
+func (item *xmlItem) entry() FeedEntry {
+	link := item.Link
+	if strings.TrimSpace(link) == "" {
+		link = item.Guid
+	}
+	return FeedEntry{
+		Title:     normalizeSpace(item.Title),
+		Link:      strings.TrimSpace(link),
+		Published: normalizeTime(firstNonEmpty(item.PubDate, item.Date)),
+		Author:    normalizeSpace(firstNonEmpty(item.Author, item.Creator)),
+		Summary:   htmlText(firstNonEmpty(item.Description, item.Encoded)),
+	}
+}
+
+// 将Atom条目转换为订阅源条目。优先使用rel为alternate（或未设定rel）的链接。
+func (entry *xmlEntry) entry() FeedEntry {
+	var link string
+	for _, l := range entry.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			link = l.Href
+			break
+		}
+	}
+	if link == "" && len(entry.Links) > 0 {
+		link = entry.Links[0].Href
+	}
+	var author string
+	if len(entry.Authors) > 0 {
+		author = entry.Authors[0].Name
+	}
+	return FeedEntry{
+		Title:     normalizeSpace(entry.Title),
+		Link:      strings.TrimSpace(link),
+		Published: normalizeTime(firstNonEmpty(entry.Published, entry.Updated)),
+		Author:    normalizeSpace(author),
+		Summary:   htmlText(firstNonEmpty(entry.Summary, entry.Content)),
+	}
+}
+
+// 获得第一个非空的字符串。
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// 获得HTML片段中的纯文本。
+func htmlText(fragment string) string {
+	if !strings.Contains(fragment, "<") {
+		return normalizeSpace(fragment)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return normalizeSpace(fragment)
+	}
+	return normalizeSpace(doc.Text())
+}
+
+// 创建订阅源解析函数。
+// 该函数会把RSS 2.0、RSS 1.0（RDF）和Atom订阅源中的条目转换为条目，并为条目的链接生成请求。
+// 生成的条目中包含如下字段：parent_url、feed.format、feed.source（订阅源的标题）、
+// feed.title、feed.link、feed.published、feed.author、feed.summary。
+func NewFeedParser(args FeedArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		if httpResp.Body == nil {
+			return nil, nil
+		}
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			return nil, nil
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, FEED_MAX_SIZE))
+		if err != nil {
+			return nil, []error{err}
+		}
+		// 不是订阅源的响应（如HTML网页和站点地图）会被忽略。
+		if !IsFeed(body) {
+			return nil, nil
+		}
+		reqUrl := httpResp.Request.URL
+		feed, err := ParseFeed(bytes.NewReader(body))
+		if err != nil {
+			errMsg := fmt.Sprintf("Invalid feed: %s (reqUrl=%s)", err, reqUrl)
+			return nil, []error{errors.New(errMsg)}
+		}
+		c := NewCollector(httpResp, respDepth)
+		entries := feed.Entries
+		if args.MaxEntries > 0 && len(entries) > args.MaxEntries {
+			entries = entries[:args.MaxEntries]
+		}
+		for _, entry := range entries {
+			var link string
+			if entry.Link != "" {
+				entryUrl, err := ResolveHref(reqUrl, entry.Link)
+				if err != nil {
+					c.AddError(err)
+				} else if entryUrl != nil {
+					link = entryUrl.String()
+					if args.FollowEntries {
+						c.AddRequest(entryUrl)
+					}
+				}
+			}
+			if args.EmitItems {
+				c.AddItem(map[string]interface{}{
+					"feed.format":    feed.Format,
+					"feed.source":    feed.Title,
+					"feed.title":     entry.Title,
+					"feed.link":      link,
+					"feed.published": entry.Published,
+					"feed.author":    entry.Author,
+					"feed.summary":   entry.Summary,
+				})
+			}
+		}
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 订阅源的内容类型。
+var feedTypes = []string{
+	"application/rss+xml", "application/atom+xml", "application/rdf+xml",
+	"application/xml", "text/xml",
+}
+
+// 创建订阅源发现函数。
+// 该函数会从HTML网页中rel="alternate"且type为订阅源类型的link标签中发现订阅源。
+// 生成的条目中包含如下字段：parent_url、feed.url、feed.type、feed.title。
+func NewFeedDiscoveryParser(args ResourceArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		doc.Find("link[href]").Each(func(index int, sel *goquery.Selection) {
+			rel, _ := sel.Attr("rel")
+			if !HasRel(rel, "alternate") {
+				return
+			}
+			typ, _ := sel.Attr("type")
+			typ = strings.ToLower(strings.TrimSpace(typ))
+			if !containsString(feedTypes, typ) {
+				return
+			}
+			href, _ := sel.Attr("href")
+			feedUrl, err := ResolveHref(baseUrl, href)
+			if err != nil {
+				c.AddError(err)
+				return
+			}
+			if feedUrl == nil {
+				return
+			}
+			if args.Follow {
+				c.AddRequest(feedUrl)
+			}
+			if args.EmitItems {
+				title, _ := sel.Attr("title")
+				c.AddItem(map[string]interface{}{
+					"feed.url":   feedUrl.String(),
+					"feed.type":  typ,
+					"feed.title": normalizeSpace(title),
+				})
+			}
+		})
+		return c.Result()
+	}
+	return parser, nil
+}
@@ -0,0 +1,186 @@
+package parsers
+
+import (
+	"base"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+  <title> Example   News </title>
+  <item>
+    <title>First story</title>
+    <link>/news/1</link>
+    <pubDate>Tue, 10 Jun 2003 04:00:00 +0000</pubDate>
+    <author>alice@example.com</author>
+    <description>&lt;p&gt;Hello &lt;b&gt;world&lt;/b&gt;&lt;/p&gt;</description>
+  </item>
+  <item>
+    <title>Second story</title>
+    <guid>http://example.com/news/2</guid>
+    <dc:date>2003-06-11</dc:date>
+    <dc:creator>Bob</dc:creator>
+  </item>
+</channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Blog</title>
+  <entry>
+    <title>Atom entry</title>
+    <link rel="self" href="http://example.com/entries/1.xml"/>
+    <link rel="alternate" href="http://example.com/entries/1"/>
+    <updated>2003-12-13T18:30:02Z</updated>
+    <author><name>Carol</name></author>
+    <content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+  </entry>
+  <entry>
+    <title>Only self link</title>
+    <link rel="self" href="/entries/2.xml"/>
+    <published>2003-12-14T10:00:00+08:00</published>
+    <summary>Plain summary</summary>
+  </entry>
+</feed>`
+
+const rdfFeed = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+  <channel><title>RDF Channel</title></channel>
+  <item>
+    <title>RDF item</title>
+    <link>http://example.com/rdf/1</link>
+  </item>
+</rdf:RDF>`
+
+func TestParseFeed(t *testing.T) {
+	cases := []struct {
+		body    string
+		format  string
+		title   string
+		entries []FeedEntry
+	}{
+		{rssFeed, FEED_RSS, "Example News", []FeedEntry{
+			{Title: "First story", Link: "/news/1", Published: "2003-06-10T04:00:00Z",
+				Author: "alice@example.com", Summary: "Hello world"},
+			{Title: "Second story", Link: "http://example.com/news/2", Published: "2003-06-11T00:00:00Z",
+				Author: "Bob"},
+		}},
+		{atomFeed, FEED_ATOM, "Example Blog", []FeedEntry{
+			{Title: "Atom entry", Link: "http://example.com/entries/1", Published: "2003-12-13T18:30:02Z",
+				Author: "Carol", Summary: "Body"},
+			{Title: "Only self link", Link: "/entries/2.xml", Published: "2003-12-14T10:00:00+08:00",
+				Summary: "Plain summary"},
+		}},
+		{rdfFeed, FEED_RDF, "RDF Channel", []FeedEntry{
+			{Title: "RDF item", Link: "http://example.com/rdf/1"},
+		}},
+	}
+	for _, c := range cases {
+		if !IsFeed([]byte(c.body)) {
+			t.Errorf("The document should be a %s feed", c.format)
+		}
+		feed, err := ParseFeed(strings.NewReader(c.body))
+		if err != nil {
+			t.Errorf("Can not parse the %s feed: %s", c.format, err)
+			continue
+		}
+		if feed.Format != c.format || feed.Title != c.title {
+			t.Errorf("Unexpected feed: %s, %q", feed.Format, feed.Title)
+		}
+		if !reflect.DeepEqual(feed.Entries, c.entries) {
+			t.Errorf("Unexpected %s entries:\n%v\nexpected:\n%v", c.format, feed.Entries, c.entries)
+		}
+	}
+	for _, body := range []string{`<urlset><url><loc>http://example.com/</loc></url></urlset>`, `<html></html>`, `not xml`} {
+		if IsFeed([]byte(body)) {
+			t.Errorf("The document should not be a feed: %s", body)
+		}
+	}
+	if _, err := ParseFeed(strings.NewReader(`<urlset></urlset>`)); err == nil {
+		t.Errorf("A non-feed document should be rejected")
+	}
+}
+
+func TestFeedParser(t *testing.T) {
+	parser, err := NewFeedParser(DefaultFeedArgs())
+	if err != nil {
+		t.Fatalf("Can not create the parser: %s", err)
+	}
+	httpResp := htmlResponse(t, "http://example.com/feed.xml", rssFeed)
+	httpResp.Header.Set("Content-Type", "application/rss+xml")
+	dataList, errs := parser(httpResp, 1)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	depths := requestDepths(dataList)
+	expectedDepths := map[string]uint32{"http://example.com/news/1": 2, "http://example.com/news/2": 2}
+	if !reflect.DeepEqual(depths, expectedDepths) {
+		t.Errorf("Unexpected requests: %v", depths)
+	}
+	items := make([]base.Item, 0)
+	for _, data := range dataList {
+		if item, ok := data.(*base.Item); ok {
+			items = append(items, *item)
+		}
+	}
+	if len(items) != 2 {
+		t.Fatalf("Unexpected items: %v", items)
+	}
+	first := items[0]
+	if first["feed.format"] != FEED_RSS || first["feed.source"] != "Example News" ||
+		first["feed.link"] != "http://example.com/news/1" || first["feed.summary"] != "Hello world" ||
+		first["parent_url"] != "http://example.com/feed.xml" {
+		t.Errorf("Unexpected item: %v", first)
+	}
+
+	// 限制条目数量，且只生成条目。
+	parser, _ = NewFeedParser(FeedArgs{EmitItems: true, MaxEntries: 1})
+	dataList, _ = parser(htmlResponse(t, "http://example.com/atom.xml", atomFeed), 0)
+	if len(dataList) != 1 || len(requestDepths(dataList)) != 0 {
+		t.Errorf("Unexpected result: %v", dataList)
+	}
+	// 不是订阅源的响应会被忽略。
+	dataList, errs = parser(htmlResponse(t, "http://example.com/", "<html><body>Hi</body></html>"), 0)
+	if len(dataList) != 0 || len(errs) != 0 {
+		t.Errorf("A non-feed response should be ignored: %v, %v", dataList, errs)
+	}
+	for _, args := range []FeedArgs{{}, {EmitItems: true, MaxEntries: -1}} {
+		if _, err := NewFeedParser(args); err == nil {
+			t.Errorf("The args should be invalid: %s", args.String())
+		}
+	}
+}
+
+func TestFeedDiscoveryParser(t *testing.T) {
+	page := `<html><head>
+<link rel="alternate" type="application/rss+xml" title=" News  RSS " href="/feed.xml">
+<link rel="Alternate Home" type="Application/Atom+XML" href="http://example.com/atom.xml">
+<link rel="alternate" hreflang="en" href="/en/">
+<link rel="stylesheet" type="text/css" href="/style.css">
+</head><body><a href="/other">Other</a></body></html>`
+	parser, err := NewFeedDiscoveryParser(ResourceArgs{Follow: true, EmitItems: true})
+	if err != nil {
+		t.Fatalf("Can not create the parser: %s", err)
+	}
+	dataList, errs := parser(htmlResponse(t, "http://example.com/index.html", page), 0)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	depths := requestDepths(dataList)
+	expectedDepths := map[string]uint32{"http://example.com/feed.xml": 1, "http://example.com/atom.xml": 1}
+	if !reflect.DeepEqual(depths, expectedDepths) {
+		t.Errorf("Unexpected requests: %v", depths)
+	}
+	items := make([]base.Item, 0)
+	for _, data := range dataList {
+		if item, ok := data.(*base.Item); ok {
+			items = append(items, *item)
+		}
+	}
+	if len(items) != 2 || items[0]["feed.title"] != "News RSS" || items[1]["feed.type"] != "application/atom+xml" {
+		t.Errorf("Unexpected items: %v", items)
+	}
+}
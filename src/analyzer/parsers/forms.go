@@ -0,0 +1,303 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 不参与表单提交的输入控件的类型。
+var ignoredInputTypes = map[string]bool{
+	"submit": true, "button": true, "image": true, "reset": true,
+	"file": true, "password": true,
+}
+
+// 表单解析函数的参数容器的描述模板。
+var formArgsTemplate string = "{ emitItems: %v, submit: %v, actionPattern: %s," +
+	" valueSets: %v, useOptions: %v, maxValuesPerField: %d, maxCombinations: %d }"
+
+// 表单解析函数的参数容器。
+type FormArgs struct {
+	EmitItems bool // 是否为每个表单生成描述其结构的条目。
+	Submit    bool // 是否为GET表单生成提交请求。POST表单只会被发现，不会被提交。
+	// 需要提交的表单的动作网址的正则表达式。为空表示提交所有GET表单。
+	ActionPattern string
+	// 字段名称与待提交的值的列表的映射，如{"q": ["golang", "爬虫"]}。
+	ValueSets map[string][]string
+	// 是否使用表单自身提供的选项（select的option、单选框和复选框的值）作为未在ValueSets中出现的字段的值。
+	// 为false时这些字段只取其默认值。
+	UseOptions bool
+	// 每个字段最多使用的值的数量。为0表示不限制。
+	MaxValuesPerField int
+	// 每个表单最多生成的请求的数量（即值组合的数量）。必须大于0。
+	MaxCombinations int
+}
+
+// 获得默认的表单解析函数的参数容器。默认只发现表单，不提交。
+func DefaultFormArgs() FormArgs {
+	return FormArgs{
+		EmitItems:         true,
+		MaxValuesPerField: 10,
+		MaxCombinations:   50,
+	}
+}
+
+func (args *FormArgs) Check() error {
+	if !args.EmitItems && !args.Submit {
+		return errors.New("The form parser would produce nothing!\n")
+	}
+	if args.MaxValuesPerField < 0 {
+		return errors.New("The max value number per field can not be negative!\n")
+	}
+	if args.MaxCombinations <= 0 {
+		return errors.New("The max combination number must be positive!\n")
+	}
+	if args.ActionPattern != "" {
+		if _, err := regexp.Compile(args.ActionPattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (args *FormArgs) String() string {
+	return fmt.Sprintf(formArgsTemplate,
+		args.EmitItems, args.Submit, args.ActionPattern, args.ValueSets,
+		args.UseOptions, args.MaxValuesPerField, args.MaxCombinations)
+}
+
+// 表单中的字段。
+type FormField struct {
+	Name    string   // 名称。
+	Type    string   // 类型，如text、hidden、select、radio、checkbox和textarea。
+	Default []string // 默认值。未被选中的复选框没有默认值。
+	Options []string // 可选的值。
+}
+
+// 表单。
+type Form struct {
+	Action *url.URL    // 解析后的动作网址。
+	Method string      // 大写的提交方法。
+	Fields []FormField // 字段的列表，按其在表单中首次出现的顺序排列。
+}
+
+// 从表单元素中提取表单。参数pageUrl用于解析空的动作网址。
+// 动作网址无效或使用不被支持的方案时结果值为nil。
+func ExtractForm(sel *goquery.Selection, baseUrl *url.URL, pageUrl *url.URL) (*Form, error) {
+	method, _ := sel.Attr("method")
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = "GET"
+	}
+	action, _ := sel.Attr("action")
+	var actionUrl *url.URL
+	if strings.TrimSpace(action) == "" {
+		u := *pageUrl
+		u.Fragment = ""
+		actionUrl = &u
+	} else {
+		var err error
+		if actionUrl, err = ResolveHref(baseUrl, action); err != nil {
+			return nil, err
+		}
+		if actionUrl == nil {
+			return nil, nil
+		}
+	}
+	form := &Form{Action: actionUrl, Method: method, Fields: make([]FormField, 0)}
+	positions := make(map[string]int)
+	field := func(name string, typ string) *FormField {
+		if pos, ok := positions[name]; ok {
+			return &form.Fields[pos]
+		}
+		positions[name] = len(form.Fields)
+		form.Fields = append(form.Fields, FormField{Name: name, Type: typ})
+		return &form.Fields[len(form.Fields)-1]
+	}
+	sel.Find("input[name], select[name], textarea[name]").Each(func(index int, control *goquery.Selection) {
+		if _, disabled := control.Attr("disabled"); disabled {
+			return
+		}
+		name, _ := control.Attr("name")
+		if name == "" {
+			return
+		}
+		switch goquery.NodeName(control) {
+		case "select":
+			f := field(name, "select")
+			control.Find("option").Each(func(index int, option *goquery.Selection) {
+				value, exists := option.Attr("value")
+				if !exists {
+					value = normalizeSpace(option.Text())
+				}
+				f.Options = append(f.Options, value)
+				if _, selected := option.Attr("selected"); selected {
+					f.Default = append(f.Default, value)
+				}
+			})
+			// 没有被选中的选项时，浏览器会提交第一个选项。
+			if len(f.Default) == 0 && len(f.Options) > 0 {
+				f.Default = []string{f.Options[0]}
+			}
+		case "textarea":
+			f := field(name, "textarea")
+			f.Default = []string{control.Text()}
+		default:
+			typ, _ := control.Attr("type")
+			typ = strings.ToLower(strings.TrimSpace(typ))
+			if typ == "" {
+				typ = "text"
+			}
+			if ignoredInputTypes[typ] {
+				return
+			}
+			value, exists := control.Attr("value")
+			f := field(name, typ)
+			if typ == "radio" || typ == "checkbox" {
+				if !exists {
+					value = "on"
+				}
+				f.Options = append(f.Options, value)
+				if _, checked := control.Attr("checked"); checked {
+					f.Default = append(f.Default, value)
+				}
+				return
+			}
+			f.Default = append(f.Default, value)
+		}
+	})
+	return form, nil
+}
+
+// 创建表单解析函数。
+// 该函数会发现网页中的表单，并按照参数中的策略为GET表单生成提交请求。
+// 生成的条目中包含如下字段：parent_url、form.action、form.method、form.fields（字段名称的列表）。
+func NewFormParser(args FormArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	var actionRegexp *regexp.Regexp
+	if args.ActionPattern != "" {
+		actionRegexp = regexp.MustCompile(args.ActionPattern)
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		doc.Find("form").Each(func(index int, sel *goquery.Selection) {
+			form, err := ExtractForm(sel, baseUrl, httpResp.Request.URL)
+			if err != nil {
+				c.AddError(err)
+				return
+			}
+			if form == nil {
+				return
+			}
+			if args.EmitItems {
+				names := make([]string, 0, len(form.Fields))
+				for _, f := range form.Fields {
+					names = append(names, f.Name)
+				}
+				c.AddItem(map[string]interface{}{
+					"form.action": form.Action.String(),
+					"form.method": form.Method,
+					"form.fields": names,
+				})
+			}
+			if !args.Submit || form.Method != "GET" {
+				return
+			}
+			if actionRegexp != nil && !actionRegexp.MatchString(form.Action.String()) {
+				return
+			}
+			for _, submitUrl := range args.submissions(form) {
+				c.AddRequest(submitUrl)
+			}
+		})
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 获得字段的候选值的列表。列表中的每个元素都是该字段被提交的一组值（复选框可能有多个值）。
+func (args *FormArgs) candidates(f FormField) [][]string {
+	candidates := make([][]string, 0)
+	if values, ok := args.ValueSets[f.Name]; ok {
+		for _, value := range values {
+			candidates = append(candidates, []string{value})
+		}
+	} else if args.UseOptions && len(f.Options) > 0 {
+		for _, option := range f.Options {
+			candidates = append(candidates, []string{option})
+		}
+		// 复选框还可以不被选中。
+		if f.Type == "checkbox" {
+			candidates = append(candidates, nil)
+		}
+	} else {
+		candidates = append(candidates, f.Default)
+	}
+	if args.MaxValuesPerField > 0 && len(candidates) > args.MaxValuesPerField {
+		candidates = candidates[:args.MaxValuesPerField]
+	}
+	return candidates
+}
+
+// 生成表单的提交网址。值的组合被逐一枚举，数量不超过MaxCombinations。
+// 枚举时ValueSets中的字段变化得最快，以便在组合数量受限时优先覆盖所提供的值。
+func (args *FormArgs) submissions(form *Form) []*url.URL {
+	candidates := make([][][]string, len(form.Fields))
+	supplied := make([]int, 0)
+	others := make([]int, 0)
+	for i, f := range form.Fields {
+		candidates[i] = args.candidates(f)
+		if len(candidates[i]) == 0 {
+			candidates[i] = [][]string{nil}
+		}
+		if _, ok := args.ValueSets[f.Name]; ok {
+			supplied = append(supplied, i)
+		} else {
+			others = append(others, i)
+		}
+	}
+	order := append(supplied, others...)
+	urls := make([]*url.URL, 0)
+	counters := make([]int, len(form.Fields))
+	for len(urls) < args.MaxCombinations {
+		query := url.Values{}
+		for i, f := range form.Fields {
+			for _, value := range candidates[i][counters[i]] {
+				query.Add(f.Name, value)
+			}
+		}
+		submitUrl := *form.Action
+		// 提交GET表单时，动作网址中原有的查询字符串会被替换。
+		submitUrl.RawQuery = query.Encode()
+		urls = append(urls, &submitUrl)
+		// 以类似里程表的方式进位。
+		carried := true
+		for _, i := range order {
+			counters[i]++
+			if counters[i] < len(candidates[i]) {
+				carried = false
+				break
+			}
+			counters[i] = 0
+		}
+		if carried {
+			break
+		}
+	}
+	return urls
+}
@@ -0,0 +1,173 @@
+package parsers
+
+import (
+	"base"
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+const searchPage = `<html><body>
+<form action="/search?old=1">
+  <input name="q">
+  <select name="cat">
+    <option value="all">All</option>
+    <option value="books" selected>Books</option>
+    <option>Music</option>
+  </select>
+  <input type="checkbox" name="new" value="1">
+  <input type="hidden" name="token" value="abc">
+  <input type="password" name="secret">
+  <input type="text" name="off" value="x" disabled>
+  <input type="submit" name="go" value="Go">
+</form>
+<form method="post" action="/login">
+  <input name="user">
+</form>
+</body></html>`
+
+// 获得解析结果中各个请求的网址，按生成的顺序排列。
+func requestUrls(dataList []base.Data) []string {
+	urls := make([]string, 0)
+	for _, data := range dataList {
+		if req, ok := data.(*base.Request); ok {
+			urls = append(urls, req.HttpReq().URL.String())
+		}
+	}
+	return urls
+}
+
+func TestFormParserItems(t *testing.T) {
+	parser, err := NewFormParser(DefaultFormArgs())
+	if err != nil {
+		t.Fatalf("Can not create the parser: %s", err)
+	}
+	dataList, errs := parser(htmlResponse(t, "http://example.com/index.html", searchPage), 0)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if urls := requestUrls(dataList); len(urls) != 0 {
+		t.Errorf("The forms should not be submitted by default: %v", urls)
+	}
+	expected := []map[string]interface{}{
+		{"form.action": "http://example.com/search?old=1", "form.method": "GET",
+			"form.fields": []string{"q", "cat", "new", "token"}},
+		{"form.action": "http://example.com/login", "form.method": "POST",
+			"form.fields": []string{"user"}},
+	}
+	items := make([]map[string]interface{}, 0)
+	for _, data := range dataList {
+		if item, ok := data.(*base.Item); ok {
+			imap := map[string]interface{}(*item)
+			items = append(items, map[string]interface{}{
+				"form.action": imap["form.action"],
+				"form.method": imap["form.method"],
+				"form.fields": imap["form.fields"],
+			})
+		}
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items:\n%v\nexpected:\n%v", items, expected)
+	}
+}
+
+func TestFormSubmissionLimits(t *testing.T) {
+	args := FormArgs{
+		Submit:            true,
+		ValueSets:         map[string][]string{"q": {"golang", "crawler", "go"}},
+		UseOptions:        true,
+		MaxValuesPerField: 2,
+		MaxCombinations:   100,
+	}
+	parser, err := NewFormParser(args)
+	if err != nil {
+		t.Fatalf("Can not create the parser: %s", err)
+	}
+	dataList, _ := parser(htmlResponse(t, "http://example.com/index.html", searchPage), 0)
+	// 每个字段最多取两个值，所提供的值变化得最快；POST表单不被提交。
+	expected := []string{
+		"http://example.com/search?cat=all&new=1&q=golang&token=abc",
+		"http://example.com/search?cat=all&new=1&q=crawler&token=abc",
+		"http://example.com/search?cat=books&new=1&q=golang&token=abc",
+		"http://example.com/search?cat=books&new=1&q=crawler&token=abc",
+		"http://example.com/search?cat=all&q=golang&token=abc",
+		"http://example.com/search?cat=all&q=crawler&token=abc",
+		"http://example.com/search?cat=books&q=golang&token=abc",
+		"http://example.com/search?cat=books&q=crawler&token=abc",
+	}
+	if urls := requestUrls(dataList); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Unexpected submissions:\n%v\nexpected:\n%v", urls, expected)
+	}
+	if len(dataList) != len(expected) {
+		t.Errorf("No items should be emitted: %d", len(dataList))
+	}
+
+	// 组合数量受限时，优先覆盖所提供的值。
+	args.MaxCombinations = 3
+	parser, _ = NewFormParser(args)
+	dataList, _ = parser(htmlResponse(t, "http://example.com/index.html", searchPage), 0)
+	if urls := requestUrls(dataList); !reflect.DeepEqual(urls, expected[:3]) {
+		t.Errorf("Unexpected submissions: %v", urls)
+	}
+
+	// 不使用表单自身的选项时，其他字段只取默认值。
+	args = FormArgs{Submit: true, ValueSets: map[string][]string{"q": {"golang"}}, MaxCombinations: 10}
+	parser, _ = NewFormParser(args)
+	dataList, _ = parser(htmlResponse(t, "http://example.com/index.html", searchPage), 0)
+	if urls := requestUrls(dataList); !reflect.DeepEqual(urls, []string{"http://example.com/search?cat=books&q=golang&token=abc"}) {
+		t.Errorf("Unexpected submissions: %v", urls)
+	}
+
+	// 动作网址不匹配的表单不被提交。
+	args.ActionPattern = "/find"
+	parser, _ = NewFormParser(args)
+	dataList, _ = parser(htmlResponse(t, "http://example.com/index.html", searchPage), 0)
+	if urls := requestUrls(dataList); len(urls) != 0 {
+		t.Errorf("Unexpected submissions: %v", urls)
+	}
+}
+
+func TestFormCombinationExplosion(t *testing.T) {
+	// 三个各有十个选项的字段共有一千种组合，生成的请求数量应被限制。
+	var options bytes.Buffer
+	for i := 0; i < 10; i++ {
+		options.WriteString("<option>" + strconv.Itoa(i) + "</option>")
+	}
+	page := "<html><body><form action=\"/list\">"
+	for _, name := range []string{"a", "b", "c"} {
+		page += "<select name=\"" + name + "\">" + options.String() + "</select>"
+	}
+	page += "</form></body></html>"
+	args := FormArgs{Submit: true, UseOptions: true, MaxCombinations: 50}
+	parser, _ := NewFormParser(args)
+	dataList, _ := parser(htmlResponse(t, "http://example.com/", page), 0)
+	urls := requestUrls(dataList)
+	if len(urls) != 50 {
+		t.Fatalf("Unexpected submission count: %d", len(urls))
+	}
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if seen[u] {
+			t.Errorf("Duplicate submission: %s", u)
+		}
+		seen[u] = true
+	}
+	if urls[49] != "http://example.com/list?a=9&b=4&c=0" {
+		t.Errorf("Unexpected last submission: %s", urls[49])
+	}
+}
+
+func TestFormArgsCheck(t *testing.T) {
+	invalid := []FormArgs{
+		{MaxCombinations: 10},
+		{EmitItems: true},
+		{EmitItems: true, MaxCombinations: 10, MaxValuesPerField: -1},
+		{Submit: true, MaxCombinations: 10, ActionPattern: "("},
+	}
+	for _, args := range invalid {
+		if _, err := NewFormParser(args); err == nil {
+			t.Errorf("The args should be invalid: %s", args.String())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 各个标签中存放链接地址的属性。
+var linkAttrs = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"iframe": "src",
+	"frame":  "src",
+}
+
+// 指向网页资源而非网页的link标签的链接类型。这些链接由资源解析函数处理。
+var assetRels = []string{
+	"stylesheet", "icon", "apple-touch-icon", "manifest",
+	"preload", "prefetch", "modulepreload", "dns-prefetch", "preconnect",
+}
+
+// 链接解析函数的参数容器的描述模板。
+var linkArgsTemplate string = "{ tags: %v, followNofollow: %v, robots: %v, emitItems: %v, pagination: %s }"
+
+// 链接解析函数的参数容器。
+type LinkArgs struct {
+	Tags           []string // 需要从中提取链接的标签，可选a、area、link、iframe和frame。
+	FollowNofollow bool     // 是否为带有rel="nofollow"的链接生成请求。Robots不为nil时不起作用。
+	EmitItems      bool     // 是否为每个链接生成条目。
+	// 网页级爬取指令过滤器。不为nil时由它决定是否为带有rel="nofollow"的链接生成请求，
+	// 即只在忽略爬取指令时生成，以免网页级和链接级的nofollow被分别设定。
+	Robots analyzer.RobotsFilter
+	// 翻页解析函数的参数。不为nil时，不为按此参数识别出的下一页链接生成请求。
+	// 下一页由翻页解析函数以与当前响应相同的深度请求，若在此生成更深的请求，调度器会把后到的同深度请求当作重复请求忽略。
+	Pagination *PaginationArgs
+}
+
+// 获得默认的链接解析函数的参数容器。
+func DefaultLinkArgs() LinkArgs {
+	return LinkArgs{
+		Tags:      []string{"a", "area", "link", "iframe", "frame"},
+		EmitItems: true,
+	}
+}
+
+func (args *LinkArgs) Check() error {
+	if len(args.Tags) == 0 {
+		return errors.New("The link tag list can not be empty!\n")
+	}
+	for _, tag := range args.Tags {
+		if _, ok := linkAttrs[strings.ToLower(tag)]; !ok {
+			return errors.New(fmt.Sprintf("Unsupported link tag '%s'!\n", tag))
+		}
+	}
+	if args.Pagination != nil {
+		return args.Pagination.Check()
+	}
+	return nil
+}
+
+func (args *LinkArgs) String() string {
+	pagination := "none"
+	if args.Pagination != nil {
+		pagination = args.Pagination.String()
+	}
+	return fmt.Sprintf(linkArgsTemplate,
+		args.Tags, args.FollowNofollow, args.Robots != nil, args.EmitItems, pagination)
+}
+
+// 创建链接解析函数。
+// 该函数会为各个标签中的链接生成请求，带有rel="nofollow"的链接除外（除非参数中另有设定）。
+// 生成的条目中包含如下字段：parent_url、link.url、link.tag、link.text、link.rel、link.nofollow。
+func NewLinkParser(args LinkArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	var p *paginator
+	if args.Pagination != nil {
+		p = newPaginator(*args.Pagination)
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		collectLinks(doc, baseUrl, args, p, c)
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 从文档中提取链接，并把生成的请求和条目放入收集器。
+// 其他的响应解析函数可以借此在解析文档的同时发现链接。
+func CollectLinks(doc *goquery.Document, baseUrl *url.URL, args LinkArgs, c *Collector) {
+	var p *paginator
+	if args.Pagination != nil {
+		p = newPaginator(*args.Pagination)
+	}
+	collectLinks(doc, baseUrl, args, p, c)
+}
+
+// 从文档中提取链接。翻页探测器不为nil时，不为其识别出的下一页链接生成请求。
+func collectLinks(doc *goquery.Document, baseUrl *url.URL, args LinkArgs, p *paginator, c *Collector) {
+	selectors := make([]string, 0, len(args.Tags))
+	for _, tag := range args.Tags {
+		tag = strings.ToLower(tag)
+		if attr, ok := linkAttrs[tag]; ok {
+			selectors = append(selectors, fmt.Sprintf("%s[%s]", tag, attr))
+		}
+	}
+	if len(selectors) == 0 {
+		return
+	}
+	followNofollow := args.FollowNofollow
+	if args.Robots != nil {
+		followNofollow = args.Robots.Ignored()
+	}
+	nextPage := ""
+	if p != nil {
+		// 无法解析的链接地址会在下面提取链接时被报告。
+		if nextUrl, _ := p.detect(doc, baseUrl, c.reqUrl); nextUrl != nil {
+			nextPage = normalizeUrl(nextUrl)
+		}
+	}
+	doc.Find(strings.Join(selectors, ", ")).Each(func(index int, sel *goquery.Selection) {
+		tag := goquery.NodeName(sel)
+		rel, _ := sel.Attr("rel")
+		if tag == "link" && isAssetRel(rel) {
+			return
+		}
+		href, _ := sel.Attr(linkAttrs[tag])
+		linkUrl, err := ResolveHref(baseUrl, href)
+		if err != nil {
+			c.AddError(err)
+			return
+		}
+		if linkUrl == nil {
+			return
+		}
+		nofollow := HasRel(rel, "nofollow")
+		if (!nofollow || followNofollow) && (nextPage == "" || normalizeUrl(linkUrl) != nextPage) {
+			c.AddRequest(linkUrl)
+		}
+		if args.EmitItems {
+			c.AddItem(map[string]interface{}{
+				"link.url":      linkUrl.String(),
+				"link.tag":      tag,
+				"link.text":     strings.TrimSpace(sel.Text()),
+				"link.rel":      rel,
+				"link.nofollow": nofollow,
+			})
+		}
+	})
+}
+
+// 判断link标签的链接类型是否指向网页资源。
+func isAssetRel(rel string) bool {
+	for _, assetRel := range assetRels {
+		if HasRel(rel, assetRel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package parsers
+
+import (
+	"analyzer"
+	"testing"
+)
+
+func TestLinkNofollowFollowsRobotsFilter(t *testing.T) {
+	page := `<html><body>
+<a href="/a">A</a>
+<a href="/ads" rel="sponsored nofollow">Ad</a>
+</body></html>`
+	for _, ignore := range []bool{false, true} {
+		robotsFilter, err := analyzer.NewRobotsFilter(analyzer.RobotsArgs{Ignore: ignore})
+		if err != nil {
+			t.Fatalf("Can not create the robots filter: %s", err)
+		}
+		args := DefaultLinkArgs()
+		// 设置了过滤器时，FollowNofollow不再起作用。
+		args.FollowNofollow = !ignore
+		args.Robots = robotsFilter
+		parser, err := NewLinkParser(args)
+		if err != nil {
+			t.Fatalf("Can not create the link parser: %s", err)
+		}
+		dataList, _ := parser(htmlResponse(t, "http://example.com/", page), 0)
+		depths := requestDepths(dataList)
+		expected := 1
+		if ignore {
+			expected = 2
+		}
+		if _, ok := depths["http://example.com/ads"]; ok != ignore || len(depths) != expected {
+			t.Errorf("Unexpected requests (ignore=%v): %v", ignore, depths)
+		}
+	}
+}
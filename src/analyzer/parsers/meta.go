@@ -0,0 +1,184 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 刷新解析函数的参数容器的描述模板。
+var refreshArgsTemplate string = "{ maxDelay: %d, emitItems: %v }"
+
+// 刷新解析函数的参数容器。
+type RefreshArgs struct {
+	MaxDelay  uint32 // 被跟随的刷新的最大延迟秒数。延迟更长的刷新通常只是定时刷新本页，会被忽略。
+	EmitItems bool   // 是否为刷新生成条目。
+}
+
+// 获得默认的刷新解析函数的参数容器。
+func DefaultRefreshArgs() RefreshArgs {
+	return RefreshArgs{MaxDelay: 30}
+}
+
+func (args *RefreshArgs) Check() error {
+	return nil
+}
+
+func (args *RefreshArgs) String() string {
+	return fmt.Sprintf(refreshArgsTemplate, args.MaxDelay, args.EmitItems)
+}
+
+// 创建刷新解析函数。
+// 该函数会为<meta http-equiv="refresh">以及Refresh响应头中的目标网址生成请求。
+// 生成的条目中包含如下字段：parent_url、refresh.url、refresh.delay。
+func NewRefreshParser(args RefreshArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		header := httpResp.Header.Get("Refresh")
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			if header == "" || httpResp.StatusCode != 200 {
+				return nil, nil
+			}
+			baseUrl = httpResp.Request.URL
+		}
+		contents := make([]string, 0)
+		if header != "" {
+			contents = append(contents, header)
+		}
+		if doc != nil {
+			doc.Find("meta[http-equiv]").Each(func(index int, sel *goquery.Selection) {
+				equiv, _ := sel.Attr("http-equiv")
+				if strings.ToLower(strings.TrimSpace(equiv)) != "refresh" {
+					return
+				}
+				if content, exists := sel.Attr("content"); exists {
+					contents = append(contents, content)
+				}
+			})
+		}
+		c := NewCollector(httpResp, respDepth)
+		for _, content := range contents {
+			delay, href, err := ParseRefresh(content)
+			if err != nil {
+				c.AddError(err)
+				continue
+			}
+			if href == "" || delay > args.MaxDelay {
+				continue
+			}
+			refreshUrl, err := ResolveHref(baseUrl, href)
+			if err != nil {
+				c.AddError(err)
+				continue
+			}
+			if refreshUrl == nil {
+				continue
+			}
+			c.AddRequest(refreshUrl)
+			if args.EmitItems {
+				c.AddItem(map[string]interface{}{
+					"refresh.url":   refreshUrl.String(),
+					"refresh.delay": delay,
+				})
+			}
+		}
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 解析刷新指令，如“5; url=http://example.com/”。
+// 结果值依次为延迟秒数和目标链接地址。目标链接地址为空意味着刷新本页。
+func ParseRefresh(content string) (uint32, string, error) {
+	content = strings.TrimSpace(content)
+	index := strings.IndexAny(content, ";,")
+	delayStr := content
+	var rest string
+	if index >= 0 {
+		delayStr = content[:index]
+		rest = strings.TrimSpace(content[index+1:])
+	}
+	// 延迟秒数可以带有小数部分，但只有整数部分有意义。
+	if dot := strings.Index(delayStr, "."); dot >= 0 {
+		delayStr = delayStr[:dot]
+	}
+	delayStr = strings.TrimSpace(delayStr)
+	var delay uint32
+	if delayStr != "" {
+		d, err := strconv.ParseUint(delayStr, 10, 32)
+		if err != nil {
+			return 0, "", errors.New(fmt.Sprintf("Invalid refresh delay '%s'!", content))
+		}
+		delay = uint32(d)
+	}
+	if len(rest) >= 3 && strings.ToLower(rest[:3]) == "url" {
+		afterUrl := strings.TrimSpace(rest[3:])
+		if strings.HasPrefix(afterUrl, "=") {
+			rest = strings.TrimSpace(afterUrl[1:])
+		}
+	}
+	rest = strings.Trim(rest, `'"`)
+	return delay, rest, nil
+}
+
+// 创建规范网址解析函数。
+// 该函数会获取<link rel="canonical">中声明的规范网址，并在其与请求的网址不同时为其生成请求。
+// 生成的条目中包含如下字段：parent_url、canonical.url、canonical.self（规范网址是否就是请求的网址）。
+func NewCanonicalParser(args ResourceArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		href, exists := "", false
+		doc.Find("link[rel][href]").EachWithBreak(func(index int, sel *goquery.Selection) bool {
+			rel, _ := sel.Attr("rel")
+			if HasRel(rel, "canonical") {
+				href, exists = sel.Attr("href")
+				return false
+			}
+			return true
+		})
+		if !exists {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		canonicalUrl, err := ResolveHref(baseUrl, href)
+		if err != nil {
+			c.AddError(err)
+			return c.Result()
+		}
+		if canonicalUrl == nil {
+			return nil, nil
+		}
+		self := canonicalUrl.String() == httpResp.Request.URL.String()
+		if args.Follow && !self {
+			c.AddRequest(canonicalUrl)
+		}
+		if args.EmitItems {
+			c.AddItem(map[string]interface{}{
+				"canonical.url":  canonicalUrl.String(),
+				"canonical.self": self,
+			})
+		}
+		return c.Result()
+	}
+	return parser, nil
+}
@@ -0,0 +1,246 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 默认的“下一页”链接文本的正则表达式。
+var DEFAULT_NEXT_PATTERN = `(?i)^(next|next\s*page|older(\s+posts)?|下一页|下页|后页|下一頁|次へ|次のページ|다음)?\s*[>›»→]*$`
+
+// 默认的最大页码。
+var DEFAULT_MAX_PAGE = 100
+
+// 默认的页码参数的名称。
+var DEFAULT_PAGE_PARAMS = []string{"page", "p", "pg", "pn", "pageno", "page_no", "pagenum", "paged"}
+
+// 路径中的页码，如/page/2/或/list_2.html。
+var pathPagePattern = regexp.MustCompile(`(?i)(?:/page/(\d+)/?|_(\d+)\.s?html?)$`)
+
+// 翻页解析函数的参数容器的描述模板。
+var paginationArgsTemplate string = "{ selectors: %v, nextPattern: %s, pageParams: %v," +
+	" numeric: %v, maxPage: %d }"
+
+// 翻页解析函数的参数容器。
+type PaginationArgs struct {
+	// 指向下一页的元素的CSS选择器，如"a.next"。优先于其他识别方式。
+	Selectors []string
+	// “下一页”链接的文本（以及aria-label和title属性）的正则表达式。为空表示不按文本识别。
+	NextPattern string
+	// 页码参数的名称。用于识别当前页码以及数字页码链接。
+	PageParams []string
+	// 是否识别数字页码链接，即文本为当前页码加1的链接。
+	Numeric bool
+	// 最大页码。页码大于此值的下一页不会被跟随。为0表示不限制。
+	MaxPage int
+}
+
+// 获得默认的翻页解析函数的参数容器。
+func DefaultPaginationArgs() PaginationArgs {
+	return PaginationArgs{
+		NextPattern: DEFAULT_NEXT_PATTERN,
+		PageParams:  DEFAULT_PAGE_PARAMS,
+		Numeric:     true,
+		MaxPage:     DEFAULT_MAX_PAGE,
+	}
+}
+
+func (args *PaginationArgs) Check() error {
+	if len(args.Selectors) == 0 && args.NextPattern == "" && !args.Numeric {
+		return errors.New("The pagination parser would detect nothing!\n")
+	}
+	if args.NextPattern != "" {
+		if _, err := regexp.Compile(args.NextPattern); err != nil {
+			return err
+		}
+	}
+	if args.MaxPage < 0 {
+		return errors.New("The max page can not be negative!\n")
+	}
+	return nil
+}
+
+func (args *PaginationArgs) String() string {
+	return fmt.Sprintf(paginationArgsTemplate,
+		args.Selectors, args.NextPattern, args.PageParams, args.Numeric, args.MaxPage)
+}
+
+// 翻页探测器。
+type paginator struct {
+	args        PaginationArgs
+	nextRegexp  *regexp.Regexp
+	followed    map[string]bool // 已跟随的下一页以及已翻过的页的规范化网址。
+	followedMux sync.Mutex      // 针对已跟随的网址的互斥锁。
+}
+
+// 创建翻页解析函数。
+// 该函数会依次按照选择器、rel="next"、“下一页”链接文本以及数字页码链接识别下一页，
+// 并生成与当前响应深度相同的请求，以免翻页消耗爬取深度。
+// 下一页与当前页相同、页码不增或已被跟随过时，都会被视为循环而不被跟随。
+// 已跟随的网址在函数的整个生命周期内有效，因此每次爬取都应创建新的函数。
+func NewPaginationParser(args PaginationArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	p := newPaginator(args)
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		reqUrl := httpResp.Request.URL
+		nextUrl, err := p.detect(doc, baseUrl, reqUrl)
+		if err != nil {
+			c.AddError(err)
+		}
+		if nextUrl == nil || !p.follow(reqUrl, nextUrl) {
+			return c.Result()
+		}
+		httpReq, err := http.NewRequest("GET", nextUrl.String(), nil)
+		if err != nil {
+			c.AddError(err)
+			return c.Result()
+		}
+		c.AddCustomRequest(base.NewRequest(httpReq, respDepth))
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 创建翻页探测器。参数应已通过检查。
+func newPaginator(args PaginationArgs) *paginator {
+	p := &paginator{args: args, followed: make(map[string]bool)}
+	if args.NextPattern != "" {
+		p.nextRegexp = regexp.MustCompile(args.NextPattern)
+	}
+	return p
+}
+
+// 识别下一页的网址。未识别到时结果值为nil。
+func (p *paginator) detect(doc *goquery.Document, baseUrl *url.URL, reqUrl *url.URL) (*url.URL, error) {
+	candidates := make([]*goquery.Selection, 0)
+	for _, selector := range p.args.Selectors {
+		candidates = append(candidates, doc.Find(selector).First())
+	}
+	doc.Find("link[href], a[href]").EachWithBreak(func(index int, sel *goquery.Selection) bool {
+		rel, _ := sel.Attr("rel")
+		if HasRel(rel, "next") {
+			candidates = append(candidates, sel)
+			return false
+		}
+		return true
+	})
+	if p.nextRegexp != nil {
+		doc.Find("a[href]").EachWithBreak(func(index int, sel *goquery.Selection) bool {
+			if p.isNextAnchor(sel) {
+				candidates = append(candidates, sel)
+				return false
+			}
+			return true
+		})
+	}
+	if p.args.Numeric {
+		next := strconv.Itoa(p.pageNumber(reqUrl) + 1)
+		doc.Find("a[href]").EachWithBreak(func(index int, sel *goquery.Selection) bool {
+			if strings.TrimSpace(sel.Text()) == next {
+				candidates = append(candidates, sel)
+				return false
+			}
+			return true
+		})
+	}
+	for _, sel := range candidates {
+		if sel.Length() == 0 {
+			continue
+		}
+		href, _ := sel.Attr("href")
+		nextUrl, err := ResolveHref(baseUrl, href)
+		if err != nil {
+			return nil, err
+		}
+		if nextUrl != nil && normalizeUrl(nextUrl) != normalizeUrl(reqUrl) {
+			return nextUrl, nil
+		}
+	}
+	return nil, nil
+}
+
+// 判断链接是否为“下一页”链接。文本为空的链接（如图标）会依据其aria-label和title属性判断。
+func (p *paginator) isNextAnchor(sel *goquery.Selection) bool {
+	text := normalizeSpace(sel.Text())
+	if text != "" {
+		return p.nextRegexp.MatchString(text)
+	}
+	for _, attr := range []string{"aria-label", "title"} {
+		if value, exists := sel.Attr(attr); exists && strings.TrimSpace(value) != "" {
+			return p.nextRegexp.MatchString(normalizeSpace(value))
+		}
+	}
+	return false
+}
+
+// 获得网址中的页码。没有页码的网址被视为第1页。
+func (p *paginator) pageNumber(pageUrl *url.URL) int {
+	query := pageUrl.Query()
+	for _, param := range p.args.PageParams {
+		for key, values := range query {
+			if strings.EqualFold(key, param) && len(values) > 0 {
+				if n, err := strconv.Atoi(values[0]); err == nil {
+					return n
+				}
+			}
+		}
+	}
+	if match := pathPagePattern.FindStringSubmatch(pageUrl.Path); match != nil {
+		if n, err := strconv.Atoi(match[1] + match[2]); err == nil {
+			return n
+		}
+	}
+	return 1
+}
+
+// 判断是否应跟随下一页，并记录被跟随的网址。
+func (p *paginator) follow(reqUrl *url.URL, nextUrl *url.URL) bool {
+	current := p.pageNumber(reqUrl)
+	next := p.pageNumber(nextUrl)
+	// 下一页带有页码时，页码必须递增。不带页码的下一页（如基于游标的网址）只依靠已跟随的网址判断循环。
+	if next <= current && next != 1 {
+		return false
+	}
+	if p.args.MaxPage > 0 && next > p.args.MaxPage {
+		return false
+	}
+	key := normalizeUrl(nextUrl)
+	p.followedMux.Lock()
+	defer p.followedMux.Unlock()
+	// 当前页也被记录下来，以免翻页链回到第一页（如游标循环）时再次请求它。
+	p.followed[normalizeUrl(reqUrl)] = true
+	if p.followed[key] {
+		return false
+	}
+	p.followed[key] = true
+	return true
+}
+
+// 获得规范化的网址：主机名小写，查询参数排序，去除片段。
+func normalizeUrl(u *url.URL) string {
+	normalized := *u
+	normalized.Host = strings.ToLower(normalized.Host)
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	normalized.RawQuery = normalized.Query().Encode()
+	normalized.Fragment = ""
+	return normalized.String()
+}
@@ -0,0 +1,182 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 把网页包装为HTTP响应。
+func htmlResponse(t *testing.T, reqUrl string, html string) *http.Response {
+	httpReq, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		t.Fatalf("Can not create the request: %s", err)
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	return &http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(html)),
+		Request:    httpReq,
+	}
+}
+
+// 获得解析结果中各个请求的网址及其深度。
+func requestDepths(dataList []base.Data) map[string]uint32 {
+	depths := make(map[string]uint32)
+	for _, data := range dataList {
+		if req, ok := data.(*base.Request); ok {
+			depths[req.HttpReq().URL.String()] = req.Depth()
+		}
+	}
+	return depths
+}
+
+func TestLinkParserSkipsNextPage(t *testing.T) {
+	page := `<html><body>
+<a href="/list?page=2">Next</a>
+<a href="/article/1">Article</a>
+</body></html>`
+	paginationArgs := DefaultPaginationArgs()
+	linkArgs := DefaultLinkArgs()
+	linkArgs.Pagination = &paginationArgs
+	linkParser, err := NewLinkParser(linkArgs)
+	if err != nil {
+		t.Fatalf("Can not create the link parser: %s", err)
+	}
+	paginationParser, err := NewPaginationParser(paginationArgs)
+	if err != nil {
+		t.Fatalf("Can not create the pagination parser: %s", err)
+	}
+	dataList, _ := linkParser(htmlResponse(t, "http://example.com/list", page), 3)
+	depths := requestDepths(dataList)
+	if _, ok := depths["http://example.com/list?page=2"]; ok || depths["http://example.com/article/1"] != 4 {
+		t.Errorf("The link parser should leave the next page to the pagination parser: %v", depths)
+	}
+	dataList, _ = paginationParser(htmlResponse(t, "http://example.com/list", page), 3)
+	if depths := requestDepths(dataList); len(depths) != 1 || depths["http://example.com/list?page=2"] != 3 {
+		t.Errorf("The next page should keep the depth: %v", depths)
+	}
+	// 未设定翻页参数时，下一页与其他链接一样被请求。
+	linkParser, _ = NewLinkParser(DefaultLinkArgs())
+	dataList, _ = linkParser(htmlResponse(t, "http://example.com/list", page), 3)
+	if depths := requestDepths(dataList); depths["http://example.com/list?page=2"] != 4 {
+		t.Errorf("Unexpected requests: %v", depths)
+	}
+}
+
+func TestPaginationDefaultMaxPage(t *testing.T) {
+	parser, _ := NewPaginationParser(DefaultPaginationArgs())
+	last := "http://example.com/list?page=" + strconv.Itoa(DEFAULT_MAX_PAGE)
+	page := `<html><body><a href="/list?page=` + strconv.Itoa(DEFAULT_MAX_PAGE+1) + `">Next</a></body></html>`
+	if dataList, _ := parser(htmlResponse(t, last, page), 0); len(requestDepths(dataList)) != 0 {
+		t.Errorf("A page beyond the default max page should not be followed")
+	}
+}
+
+// 用翻页解析函数解析网页，获得下一页的网址。没有下一页时返回空字符串。
+func nextPage(t *testing.T, parser analyzer.ParseResponse, reqUrl string, page string) string {
+	dataList, errs := parser(htmlResponse(t, reqUrl, page), 2)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	depths := requestDepths(dataList)
+	if len(depths) > 1 {
+		t.Errorf("Unexpected requests: %v", depths)
+	}
+	for next, depth := range depths {
+		if depth != 2 {
+			t.Errorf("The next page should keep the depth: %d", depth)
+		}
+		return next
+	}
+	return ""
+}
+
+func TestPaginationDetection(t *testing.T) {
+	cases := []struct {
+		reqUrl   string
+		page     string
+		expected string
+	}{
+		{"http://example.com/list", `<head><link rel="next" href="/list?page=2"></head>`,
+			"http://example.com/list?page=2"},
+		{"http://example.com/list", `<a href="/about">About</a><a rel="prev next" href="?page=2">2</a>`,
+			"http://example.com/list?page=2"},
+		{"http://example.com/list", `<a href="/a">Article</a><a href="/list?page=2">Next &raquo;</a>`,
+			"http://example.com/list?page=2"},
+		{"http://example.com/news/", `<a href="/news/page/2/">下一页</a>`,
+			"http://example.com/news/page/2/"},
+		{"http://example.com/list", `<a href="/list?p=2" aria-label="Next page"><i class="icon"></i></a>`,
+			"http://example.com/list?p=2"},
+		// 数字页码链接。
+		{"http://example.com/list_3.html", `<a href="/list_2.html">2</a><a href="/list_4.html">4</a>`,
+			"http://example.com/list_4.html"},
+		{"http://example.com/list", `<a href="/a">Article</a><a href="/b">More</a>`, ""},
+	}
+	for _, c := range cases {
+		parser, _ := NewPaginationParser(DefaultPaginationArgs())
+		if next := nextPage(t, parser, c.reqUrl, "<html><body>"+c.page+"</body></html>"); next != c.expected {
+			t.Errorf("Unexpected next page of %s: %q (expected %q)", c.page, next, c.expected)
+		}
+	}
+	// 选择器优先于其他识别方式。
+	args := DefaultPaginationArgs()
+	args.Selectors = []string{"div.pager a.forward"}
+	parser, _ := NewPaginationParser(args)
+	page := `<html><body><a href="/list?page=9">Next</a><div class="pager"><a class="forward" href="/list?page=2">→</a></div></body></html>`
+	if next := nextPage(t, parser, "http://example.com/list", page); next != "http://example.com/list?page=2" {
+		t.Errorf("Unexpected next page: %s", next)
+	}
+}
+
+func TestPaginationLoops(t *testing.T) {
+	parser, _ := NewPaginationParser(DefaultPaginationArgs())
+	// 指向当前页（忽略主机名大小写、参数顺序和片段）的链接不是下一页。
+	page := `<html><body><a href="http://EXAMPLE.com/list?b=1&page=2#top">Next</a></body></html>`
+	if next := nextPage(t, parser, "http://example.com/list?page=2&b=1", page); next != "" {
+		t.Errorf("A link to the current page should not be followed: %s", next)
+	}
+	// 页码不增的下一页不被跟随。
+	page = `<html><body><a href="/list?page=2">Next</a></body></html>`
+	if next := nextPage(t, parser, "http://example.com/list?page=3", page); next != "" {
+		t.Errorf("A previous page should not be followed: %s", next)
+	}
+	// 已跟随过的下一页不再被跟随。
+	page = `<html><body><a href="/list?page=5">Next</a></body></html>`
+	if next := nextPage(t, parser, "http://example.com/list?page=4", page); next != "http://example.com/list?page=5" {
+		t.Errorf("Unexpected next page: %s", next)
+	}
+	if next := nextPage(t, parser, "http://example.com/list?page=4&utm=x", page); next != "" {
+		t.Errorf("A followed page should not be followed again: %s", next)
+	}
+
+	// 基于游标的翻页链回到第一页时停止。
+	parser, _ = NewPaginationParser(DefaultPaginationArgs())
+	pages := map[string]string{
+		"http://example.com/feed":           "/feed?cursor=b2",
+		"http://example.com/feed?cursor=b2": "/feed?cursor=c3",
+		"http://example.com/feed?cursor=c3": "/feed",
+	}
+	current := "http://example.com/feed"
+	followed := make([]string, 0)
+	for i := 0; i < len(pages)+1; i++ {
+		page := `<html><body><a href="` + pages[current] + `">Next</a></body></html>`
+		next := nextPage(t, parser, current, page)
+		if next == "" {
+			break
+		}
+		followed = append(followed, next)
+		current = next
+	}
+	expected := []string{"http://example.com/feed?cursor=b2", "http://example.com/feed?cursor=c3"}
+	if !reflect.DeepEqual(followed, expected) {
+		t.Errorf("Unexpected pages: %v", followed)
+	}
+}
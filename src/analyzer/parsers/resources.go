@@ -0,0 +1,178 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// 资源解析函数的参数容器的描述模板。
+var resourceArgsTemplate string = "{ follow: %v, emitItems: %v }"
+
+// 资源解析函数的参数容器。
+type ResourceArgs struct {
+	Follow    bool // 是否为资源的网址生成请求。
+	EmitItems bool // 是否为每个资源生成条目。
+}
+
+// 获得默认的资源解析函数的参数容器。默认只生成条目，不下载资源本身。
+func DefaultResourceArgs() ResourceArgs {
+	return ResourceArgs{EmitItems: true}
+}
+
+func (args *ResourceArgs) Check() error {
+	if !args.Follow && !args.EmitItems {
+		return errors.New("The resource parser would produce nothing!\n")
+	}
+	return nil
+}
+
+func (args *ResourceArgs) String() string {
+	return fmt.Sprintf(resourceArgsTemplate, args.Follow, args.EmitItems)
+}
+
+// 资源解析函数处理单个元素的函数类型。结果值为该元素所引用的资源的链接地址。
+type extractResource func(sel *goquery.Selection) []string
+
+// 资源解析函数生成条目字段的函数类型。
+type resourceFields func(sel *goquery.Selection, resUrl string) map[string]interface{}
+
+// 创建图片解析函数。
+// 该函数会从img标签的src和srcset属性中提取图片的网址。
+// 生成的条目中包含如下字段：parent_url、image.url、image.alt。
+func NewImageParser(args ResourceArgs) (analyzer.ParseResponse, error) {
+	extract := func(sel *goquery.Selection) []string {
+		hrefs := make([]string, 0)
+		if src, exists := sel.Attr("src"); exists {
+			hrefs = append(hrefs, src)
+		}
+		if srcset, exists := sel.Attr("srcset"); exists {
+			hrefs = append(hrefs, ParseSrcset(srcset)...)
+		}
+		return hrefs
+	}
+	fields := func(sel *goquery.Selection, resUrl string) map[string]interface{} {
+		alt, _ := sel.Attr("alt")
+		return map[string]interface{}{
+			"image.url": resUrl,
+			"image.alt": strings.TrimSpace(alt),
+		}
+	}
+	return newResourceParser(args, "img", extract, fields)
+}
+
+// 创建脚本和样式表解析函数。
+// 该函数会从script标签的src属性以及rel="stylesheet"的link标签的href属性中提取网址。
+// 生成的条目中包含如下字段：parent_url、asset.url、asset.type（值为script或stylesheet）。
+func NewAssetParser(args ResourceArgs) (analyzer.ParseResponse, error) {
+	extract := func(sel *goquery.Selection) []string {
+		if goquery.NodeName(sel) == "script" {
+			src, _ := sel.Attr("src")
+			return []string{src}
+		}
+		rel, _ := sel.Attr("rel")
+		if !HasRel(rel, "stylesheet") {
+			return nil
+		}
+		href, _ := sel.Attr("href")
+		return []string{href}
+	}
+	fields := func(sel *goquery.Selection, resUrl string) map[string]interface{} {
+		assetType := "stylesheet"
+		if goquery.NodeName(sel) == "script" {
+			assetType = "script"
+		}
+		return map[string]interface{}{
+			"asset.url":  resUrl,
+			"asset.type": assetType,
+		}
+	}
+	return newResourceParser(args, "script[src], link[href]", extract, fields)
+}
+
+// 创建资源解析函数。
+func newResourceParser(
+	args ResourceArgs,
+	selector string,
+	extract extractResource,
+	fields resourceFields) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		emitted := make(map[string]bool)
+		doc.Find(selector).Each(func(index int, sel *goquery.Selection) {
+			for _, href := range extract(sel) {
+				resUrl, err := ResolveHref(baseUrl, href)
+				if err != nil {
+					c.AddError(err)
+					continue
+				}
+				if resUrl == nil {
+					continue
+				}
+				if args.Follow {
+					c.AddRequest(resUrl)
+				}
+				resUrlStr := resUrl.String()
+				if args.EmitItems && !emitted[resUrlStr] {
+					emitted[resUrlStr] = true
+					c.AddItem(fields(sel, resUrlStr))
+				}
+			}
+		})
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 解析srcset属性值，获得其中所有候选图片的链接地址。
+func ParseSrcset(srcset string) []string {
+	hrefs := make([]string, 0)
+	runes := []rune(srcset)
+	for i := 0; i < len(runes); {
+		// 跳过候选项之间的空白和逗号。
+		for i < len(runes) && (unicode.IsSpace(runes[i]) || runes[i] == ',') {
+			i++
+		}
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		href := string(runes[start:i])
+		// 以逗号结尾的链接地址意味着该候选项没有描述符。
+		if strings.HasSuffix(href, ",") {
+			href = strings.TrimRight(href, ",")
+		} else {
+			// 跳过描述符，直到括号之外的逗号为止。
+			depth := 0
+			for i < len(runes) {
+				if runes[i] == '(' {
+					depth++
+				} else if runes[i] == ')' && depth > 0 {
+					depth--
+				} else if runes[i] == ',' && depth == 0 {
+					break
+				}
+				i++
+			}
+		}
+		if href != "" {
+			hrefs = append(hrefs, href)
+		}
+	}
+	return hrefs
+}
@@ -0,0 +1,367 @@
+package parsers
+
+import (
+	"analyzer"
+	"base"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 结构化数据的格式。
+const (
+	FORMAT_JSON_LD   = "json-ld"
+	FORMAT_MICRODATA = "microdata"
+	FORMAT_RDFA      = "rdfa"
+	FORMAT_OPENGRAPH = "opengraph"
+	FORMAT_TWITTER   = "twitter"
+)
+
+// 所有被支持的结构化数据的格式。
+var allFormats = []string{
+	FORMAT_JSON_LD, FORMAT_MICRODATA, FORMAT_RDFA, FORMAT_OPENGRAPH, FORMAT_TWITTER,
+}
+
+// 结构化数据解析函数的参数容器的描述模板。
+var structuredArgsTemplate string = "{ formats: %v, links: %v }"
+
+// 结构化数据解析函数的参数容器。
+type StructuredArgs struct {
+	Formats []string  // 需要提取的结构化数据的格式。为空表示提取所有格式。
+	Links   *LinkArgs // 链接发现的参数。不为nil时会在提取结构化数据的同时发现链接。
+}
+
+// 获得默认的结构化数据解析函数的参数容器。
+func DefaultStructuredArgs() StructuredArgs {
+	linkArgs := DefaultLinkArgs()
+	linkArgs.EmitItems = false
+	return StructuredArgs{Links: &linkArgs}
+}
+
+func (args *StructuredArgs) Check() error {
+	for _, format := range args.Formats {
+		if !containsString(allFormats, format) {
+			return errors.New(fmt.Sprintf("Unsupported structured data format '%s'!\n", format))
+		}
+	}
+	if args.Links != nil {
+		return args.Links.Check()
+	}
+	return nil
+}
+
+func (args *StructuredArgs) String() string {
+	var links string
+	if args.Links != nil {
+		links = args.Links.String()
+	}
+	return fmt.Sprintf(structuredArgsTemplate, args.Formats, links)
+}
+
+// 判断字符串切片中是否包含指定的字符串。
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 创建结构化数据解析函数。
+// 每份结构化数据都会生成一个条目，条目中包含如下字段：
+// parent_url；structured.format，即数据格式；structured.type，即去掉词汇表前缀的类型名称，如Product；
+// structured.data，即属性字典，其中多值属性的值为切片，嵌套条目的值为带有@type键的字典。
+// 对于OpenGraph和Twitter卡片，属性名称中的og:或twitter:前缀会被去掉，类型分别取自og:type和twitter:card。
+func NewStructuredParser(args StructuredArgs) (analyzer.ParseResponse, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	formats := args.Formats
+	if len(formats) == 0 {
+		formats = allFormats
+	}
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		doc, baseUrl, err := LoadDocument(httpResp)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		c := NewCollector(httpResp, respDepth)
+		for _, format := range formats {
+			switch format {
+			case FORMAT_JSON_LD:
+				extractJsonLd(doc, c)
+			case FORMAT_MICRODATA:
+				extractMicrodata(doc, baseUrl, c)
+			case FORMAT_RDFA:
+				extractRdfa(doc, baseUrl, c)
+			case FORMAT_OPENGRAPH:
+				extractMetaProperties(doc, "og:", "type", FORMAT_OPENGRAPH, c)
+			case FORMAT_TWITTER:
+				extractMetaProperties(doc, "twitter:", "card", FORMAT_TWITTER, c)
+			}
+		}
+		if args.Links != nil {
+			CollectLinks(doc, baseUrl, *args.Links, c)
+		}
+		return c.Result()
+	}
+	return parser, nil
+}
+
+// 添加结构化数据条目。
+func addStructuredItem(c *Collector, format string, typ string, data map[string]interface{}) {
+	c.AddItem(map[string]interface{}{
+		"structured.format": format,
+		"structured.type":   typ,
+		"structured.data":   data,
+	})
+}
+
+// 去掉类型名称中的词汇表前缀，如http://schema.org/Product和schema:Product均会变为Product。
+func normalizeType(typ string) string {
+	typ = strings.TrimSpace(typ)
+	if index := strings.LastIndexAny(typ, "/#:"); index >= 0 {
+		return typ[index+1:]
+	}
+	return typ
+}
+
+// 向属性字典中添加属性值。同名属性的多个值会被合并为切片。
+func addProperty(data map[string]interface{}, name string, value interface{}) {
+	existing, ok := data[name]
+	if !ok {
+		data[name] = value
+		return
+	}
+	if values, ok := existing.([]interface{}); ok {
+		data[name] = append(values, value)
+	} else {
+		data[name] = []interface{}{existing, value}
+	}
+}
+
+// 提取JSON-LD数据。顶层的数组和@graph中的每个节点都会生成一个条目。
+func extractJsonLd(doc *goquery.Document, c *Collector) {
+	doc.Find(`script[type="application/ld+json"]`).Each(func(index int, sel *goquery.Selection) {
+		var value interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(sel.Text())), &value); err != nil {
+			c.AddError(errors.New(fmt.Sprintf("Invalid JSON-LD block [%d]: %s", index, err)))
+			return
+		}
+		for _, node := range jsonLdNodes(value) {
+			delete(node, "@context")
+			addStructuredItem(c, FORMAT_JSON_LD, jsonLdType(node), node)
+		}
+	})
+}
+
+// 获得JSON-LD数据中的所有顶层节点。
+func jsonLdNodes(value interface{}) []map[string]interface{} {
+	nodes := make([]map[string]interface{}, 0)
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			nodes = append(nodes, jsonLdNodes(element)...)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			nodes = append(nodes, jsonLdNodes(graph)...)
+		} else {
+			nodes = append(nodes, v)
+		}
+	}
+	return nodes
+}
+
+// 获得JSON-LD节点的类型。多个类型之间以逗号分隔。
+func jsonLdType(node map[string]interface{}) string {
+	switch t := node["@type"].(type) {
+	case string:
+		return normalizeType(t)
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, normalizeType(s))
+			}
+		}
+		return strings.Join(types, ",")
+	}
+	return ""
+}
+
+// 提取微数据。每个顶层的itemscope元素都会生成一个条目。
+func extractMicrodata(doc *goquery.Document, baseUrl *url.URL, c *Collector) {
+	doc.Find("[itemscope]").Not("[itemprop]").Each(func(index int, sel *goquery.Selection) {
+		typ, data := microdataItem(sel, baseUrl)
+		addStructuredItem(c, FORMAT_MICRODATA, typ, data)
+	})
+}
+
+// 获得微数据条目的类型和属性字典。
+func microdataItem(scope *goquery.Selection, baseUrl *url.URL) (string, map[string]interface{}) {
+	itemType, _ := scope.Attr("itemtype")
+	var typ string
+	if fields := strings.Fields(itemType); len(fields) > 0 {
+		typ = normalizeType(fields[0])
+	}
+	data := make(map[string]interface{})
+	scopeNode := scope.Get(0)
+	scope.Find("[itemprop]").Each(func(index int, prop *goquery.Selection) {
+		owner := prop.Parent().Closest("[itemscope]")
+		if owner.Length() == 0 || owner.Get(0) != scopeNode {
+			return
+		}
+		var value interface{}
+		if _, nested := prop.Attr("itemscope"); nested {
+			nestedType, nestedData := microdataItem(prop, baseUrl)
+			nestedData["@type"] = nestedType
+			value = nestedData
+		} else {
+			value = microdataValue(prop, baseUrl)
+		}
+		names, _ := prop.Attr("itemprop")
+		for _, name := range strings.Fields(names) {
+			addProperty(data, name, value)
+		}
+	})
+	return typ, data
+}
+
+// 各个标签中存放微数据属性值的属性。
+var microdataValueAttrs = map[string]string{
+	"meta":   "content",
+	"audio":  "src",
+	"embed":  "src",
+	"iframe": "src",
+	"img":    "src",
+	"source": "src",
+	"track":  "src",
+	"video":  "src",
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"object": "data",
+	"data":   "value",
+	"meter":  "value",
+	"time":   "datetime",
+}
+
+// 获得微数据属性的值。网址类的值会被转换为绝对网址。
+func microdataValue(prop *goquery.Selection, baseUrl *url.URL) string {
+	tag := goquery.NodeName(prop)
+	attr, ok := microdataValueAttrs[tag]
+	if !ok {
+		return strings.Join(strings.Fields(prop.Text()), " ")
+	}
+	value, exists := prop.Attr(attr)
+	if !exists {
+		if tag == "time" {
+			return strings.TrimSpace(prop.Text())
+		}
+		return ""
+	}
+	value = strings.TrimSpace(value)
+	if attr == "src" || attr == "href" || attr == "data" {
+		if u, err := ResolveHref(baseUrl, value); err == nil && u != nil {
+			return u.String()
+		}
+	}
+	return value
+}
+
+// 提取RDFa Lite数据。每个不在其他typeof元素之内的typeof元素都会生成一个条目。
+func extractRdfa(doc *goquery.Document, baseUrl *url.URL, c *Collector) {
+	doc.Find("[typeof]").Each(func(index int, sel *goquery.Selection) {
+		if sel.Parent().Closest("[typeof]").Length() > 0 {
+			return
+		}
+		typ, data := rdfaItem(sel, baseUrl)
+		addStructuredItem(c, FORMAT_RDFA, typ, data)
+	})
+}
+
+// 获得RDFa条目的类型和属性字典。
+func rdfaItem(scope *goquery.Selection, baseUrl *url.URL) (string, map[string]interface{}) {
+	typeOf, _ := scope.Attr("typeof")
+	var typ string
+	if fields := strings.Fields(typeOf); len(fields) > 0 {
+		typ = normalizeType(fields[0])
+	}
+	data := make(map[string]interface{})
+	if resource, exists := scope.Attr("resource"); exists {
+		data["@id"] = resource
+	}
+	scopeNode := scope.Get(0)
+	scope.Find("[property]").Each(func(index int, prop *goquery.Selection) {
+		owner := prop.Parent().Closest("[typeof]")
+		if owner.Length() == 0 || owner.Get(0) != scopeNode {
+			return
+		}
+		var value interface{}
+		if _, nested := prop.Attr("typeof"); nested {
+			nestedType, nestedData := rdfaItem(prop, baseUrl)
+			nestedData["@type"] = nestedType
+			value = nestedData
+		} else {
+			value = rdfaValue(prop, baseUrl)
+		}
+		names, _ := prop.Attr("property")
+		for _, name := range strings.Fields(names) {
+			addProperty(data, normalizeType(name), value)
+		}
+	})
+	return typ, data
+}
+
+// 获得RDFa属性的值。
+func rdfaValue(prop *goquery.Selection, baseUrl *url.URL) string {
+	if content, exists := prop.Attr("content"); exists {
+		return strings.TrimSpace(content)
+	}
+	for _, attr := range []string{"resource", "href", "src"} {
+		if value, exists := prop.Attr(attr); exists {
+			if u, err := ResolveHref(baseUrl, value); err == nil && u != nil {
+				return u.String()
+			}
+			return strings.TrimSpace(value)
+		}
+	}
+	return strings.Join(strings.Fields(prop.Text()), " ")
+}
+
+// 提取以指定前缀开头的meta属性，如OpenGraph和Twitter卡片。所有属性只生成一个条目。
+// OpenGraph使用property属性，Twitter卡片通常使用name属性，这里两者均被接受。
+func extractMetaProperties(
+	doc *goquery.Document,
+	prefix string,
+	typeName string,
+	format string,
+	c *Collector) {
+	data := make(map[string]interface{})
+	doc.Find("meta[content]").Each(func(index int, sel *goquery.Selection) {
+		name, exists := sel.Attr("property")
+		if !exists || !strings.HasPrefix(strings.ToLower(name), prefix) {
+			name, _ = sel.Attr("name")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !strings.HasPrefix(name, prefix) {
+			return
+		}
+		content, _ := sel.Attr("content")
+		addProperty(data, name[len(prefix):], strings.TrimSpace(content))
+	})
+	if len(data) == 0 {
+		return
+	}
+	typ, _ := data[typeName].(string)
+	addStructuredItem(c, format, typ, data)
+}
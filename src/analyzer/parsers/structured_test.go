@@ -0,0 +1,167 @@
+package parsers
+
+import (
+	"base"
+	"reflect"
+	"testing"
+)
+
+const productPage = `<html><head>
+<base href="http://shop.example.com/">
+<meta property="og:type" content="product">
+<meta property="og:title" content=" Red Shoes ">
+<meta property="og:image" content="http://shop.example.com/1.jpg">
+<meta property="og:image" content="http://shop.example.com/2.jpg">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:site" content="@shop">
+<meta name="description" content="Not structured">
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@type": "Product", "name": "Red Shoes",
+ "offers": {"@type": "Offer", "price": "59.00"}}
+</script>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+  {"@type": ["schema:Organization", "http://schema.org/Brand"], "name": "ACME"},
+  {"@type": "WebPage", "name": "Shoes"}
+]}
+</script>
+<script type="application/ld+json">{"broken": </script>
+</head><body>
+<div itemscope itemtype="http://schema.org/Product">
+  <span itemprop="name">Red   Shoes</span>
+  <img itemprop="image" src="img/1.jpg">
+  <a itemprop="url" href="/p/1">Detail</a>
+  <span itemprop="color">red</span><span itemprop="color">crimson</span>
+  <div itemprop="offers" itemscope itemtype="http://schema.org/Offer">
+    <meta itemprop="price" content="59.00">
+    <time itemprop="validFrom" datetime="2020-01-01">Jan 1</time>
+  </div>
+</div>
+<div vocab="http://schema.org/" typeof="Person" resource="#alice">
+  <span property="name">Alice</span>
+  <a property="url" href="/alice">Home</a>
+  <div property="worksFor" typeof="Organization">
+    <span property="schema:name">ACME</span>
+  </div>
+</div>
+<a href="/next">Next</a>
+</body></html>`
+
+// 按照数据格式获得解析结果中的结构化数据条目。
+func structuredItems(dataList []base.Data) map[string][]base.Item {
+	items := make(map[string][]base.Item)
+	for _, data := range dataList {
+		if item, ok := data.(*base.Item); ok {
+			format, _ := (*item)["structured.format"].(string)
+			items[format] = append(items[format], *item)
+		}
+	}
+	return items
+}
+
+func TestStructuredParser(t *testing.T) {
+	parser, err := NewStructuredParser(DefaultStructuredArgs())
+	if err != nil {
+		t.Fatalf("Can not create the parser: %s", err)
+	}
+	dataList, errs := parser(htmlResponse(t, "http://shop.example.com/shoes", productPage), 0)
+	if len(errs) != 1 {
+		t.Errorf("The broken JSON-LD block should be reported: %v", errs)
+	}
+	items := structuredItems(dataList)
+
+	jsonLd := items[FORMAT_JSON_LD]
+	if len(jsonLd) != 3 {
+		t.Fatalf("Unexpected JSON-LD items: %v", jsonLd)
+	}
+	expectedTypes := []string{"Product", "Organization,Brand", "WebPage"}
+	for i, item := range jsonLd {
+		if item["structured.type"] != expectedTypes[i] {
+			t.Errorf("Unexpected JSON-LD type: %v", item["structured.type"])
+		}
+		if item["parent_url"] != "http://shop.example.com/shoes" {
+			t.Errorf("Unexpected parent url: %v", item["parent_url"])
+		}
+	}
+	product := jsonLd[0]["structured.data"].(map[string]interface{})
+	if _, ok := product["@context"]; ok || product["name"] != "Red Shoes" {
+		t.Errorf("Unexpected JSON-LD data: %v", product)
+	}
+
+	microdata := items[FORMAT_MICRODATA]
+	if len(microdata) != 1 || microdata[0]["structured.type"] != "Product" {
+		t.Fatalf("Unexpected microdata items: %v", microdata)
+	}
+	expectedMicrodata := map[string]interface{}{
+		"name":  "Red Shoes",
+		"image": "http://shop.example.com/img/1.jpg",
+		"url":   "http://shop.example.com/p/1",
+		"color": []interface{}{"red", "crimson"},
+		"offers": map[string]interface{}{
+			"@type":     "Offer",
+			"price":     "59.00",
+			"validFrom": "2020-01-01",
+		},
+	}
+	if data := microdata[0]["structured.data"]; !reflect.DeepEqual(data, expectedMicrodata) {
+		t.Errorf("Unexpected microdata:\n%v\nexpected:\n%v", data, expectedMicrodata)
+	}
+
+	rdfa := items[FORMAT_RDFA]
+	if len(rdfa) != 1 || rdfa[0]["structured.type"] != "Person" {
+		t.Fatalf("Unexpected RDFa items: %v", rdfa)
+	}
+	expectedRdfa := map[string]interface{}{
+		"@id":  "#alice",
+		"name": "Alice",
+		"url":  "http://shop.example.com/alice",
+		"worksFor": map[string]interface{}{
+			"@type": "Organization",
+			"name":  "ACME",
+		},
+	}
+	if data := rdfa[0]["structured.data"]; !reflect.DeepEqual(data, expectedRdfa) {
+		t.Errorf("Unexpected RDFa data:\n%v\nexpected:\n%v", data, expectedRdfa)
+	}
+
+	og := items[FORMAT_OPENGRAPH]
+	expectedOg := map[string]interface{}{
+		"type":  "product",
+		"title": "Red Shoes",
+		"image": []interface{}{"http://shop.example.com/1.jpg", "http://shop.example.com/2.jpg"},
+	}
+	if len(og) != 1 || og[0]["structured.type"] != "product" || !reflect.DeepEqual(og[0]["structured.data"], expectedOg) {
+		t.Errorf("Unexpected OpenGraph items: %v", og)
+	}
+	twitter := items[FORMAT_TWITTER]
+	expectedTwitter := map[string]interface{}{"card": "summary", "site": "@shop"}
+	if len(twitter) != 1 || twitter[0]["structured.type"] != "summary" || !reflect.DeepEqual(twitter[0]["structured.data"], expectedTwitter) {
+		t.Errorf("Unexpected Twitter card items: %v", twitter)
+	}
+
+	// 链接发现与结构化数据的提取同时进行，但不生成链接条目。
+	if depths := requestDepths(dataList); depths["http://shop.example.com/next"] != 1 {
+		t.Errorf("Unexpected requests: %v", depths)
+	}
+	if len(items) != 5 {
+		t.Errorf("Unexpected item formats: %v", items)
+	}
+}
+
+func TestStructuredParserFormats(t *testing.T) {
+	parser, err := NewStructuredParser(StructuredArgs{Formats: []string{FORMAT_OPENGRAPH}})
+	if err != nil {
+		t.Fatalf("Can not create the parser: %s", err)
+	}
+	dataList, errs := parser(htmlResponse(t, "http://shop.example.com/shoes", productPage), 0)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	items := structuredItems(dataList)
+	if len(items) != 1 || len(items[FORMAT_OPENGRAPH]) != 1 || len(requestDepths(dataList)) != 0 {
+		t.Errorf("Only the OpenGraph data should be extracted: %v", dataList)
+	}
+	if _, err := NewStructuredParser(StructuredArgs{Formats: []string{"rdf"}}); err == nil {
+		t.Errorf("An unsupported format should be rejected")
+	}
+}
@@ -0,0 +1,221 @@
+package analyzer
+
+import (
+	"base"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 响应解析函数的路由规则。
+// 各个匹配条件之间是“与”的关系，同一条件中的多个值之间是“或”的关系，未设定的条件总是被满足。
+type Route struct {
+	Name      string        // 路由规则的名称。
+	Parser    ParseResponse // 响应解析函数。
+	UrlGlob   string        // 网址的通配符模式。*匹配任意字符序列，?匹配单个字符。以/开头的模式只与网址的路径匹配。
+	UrlRegexp string        // 网址的正则表达式。
+	Hosts     []string      // 主机名的列表。以*.开头的主机名匹配其所有子域名以及其本身。
+	MimeTypes []string      // 内容类型的列表，如text/html、text/*或application/json。
+}
+
+// 编译后的路由规则。
+type compiledRoute struct {
+	route      Route          // 路由规则。
+	urlGlob    *regexp.Regexp // 编译后的网址通配符模式。
+	globOnPath bool           // 通配符模式是否只与网址的路径匹配。
+	urlRegexp  *regexp.Regexp // 编译后的网址正则表达式。
+}
+
+// 响应解析函数路由器的接口类型。
+type ParserRouter interface {
+	// 添加路由规则。
+	Add(route Route) error
+	// 获得与HTTP响应相匹配的所有响应解析函数。参数body用于在未声明内容类型时探测内容类型。
+	Match(httpResp *http.Response, body []byte) []ParseResponse
+	// 作为响应解析函数使用的路由方法。
+	// 该方法会把HTTP响应分派给所有相匹配的响应解析函数。没有任何响应解析函数与之匹配的HTTP响应只会被计入未匹配的数量。
+	// 响应解析函数发生运行时恐慌时，错误中带有路由规则的名称。
+	Parse(httpResp *http.Response, respDepth uint32) ([]base.Data, []error)
+	// 获得已路由的和未找到匹配的HTTP响应的计数值。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建响应解析函数路由器。
+func NewParserRouter() ParserRouter {
+	return &myParserRouter{routes: make([]*compiledRoute, 0)}
+}
+
+// 响应解析函数路由器的实现类型。
+type myParserRouter struct {
+	routes    []*compiledRoute // 路由规则的列表。
+	rwmutex   sync.RWMutex     // 针对路由规则的读写锁。
+	routed    uint64           // 已路由的HTTP响应的数量。
+	unmatched uint64           // 未找到匹配的HTTP响应的数量。
+}
+
+func (router *myParserRouter) Add(route Route) error {
+	if route.Parser == nil {
+		return errors.New(fmt.Sprintf("The parser of route '%s' is invalid!", route.Name))
+	}
+	cr := &compiledRoute{route: route}
+	if route.UrlGlob != "" {
+		cr.urlGlob = compileGlob(route.UrlGlob)
+		cr.globOnPath = strings.HasPrefix(route.UrlGlob, "/")
+	}
+	if route.UrlRegexp != "" {
+		re, err := regexp.Compile(route.UrlRegexp)
+		if err != nil {
+			return err
+		}
+		cr.urlRegexp = re
+	}
+	router.rwmutex.Lock()
+	defer router.rwmutex.Unlock()
+	router.routes = append(router.routes, cr)
+	return nil
+}
+
+// 将通配符模式编译为正则表达式。
+func compileGlob(glob string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(glob)
+	quoted = strings.Replace(quoted, `\*`, `.*`, -1)
+	quoted = strings.Replace(quoted, `\?`, `.`, -1)
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+func (router *myParserRouter) Match(httpResp *http.Response, body []byte) []ParseResponse {
+	matched, _ := router.match(httpResp, body)
+	return matched
+}
+
+// 获得与HTTP响应相匹配的所有响应解析函数及其路由规则的名称。
+// 未命名的路由规则以其添加的次序命名，如"#2"。
+func (router *myParserRouter) match(httpResp *http.Response, body []byte) ([]ParseResponse, []string) {
+	mimeType := mediaType(httpResp, body)
+	router.rwmutex.RLock()
+	defer router.rwmutex.RUnlock()
+	matched := make([]ParseResponse, 0)
+	names := make([]string, 0)
+	for i, cr := range router.routes {
+		if cr.match(httpResp, mimeType) {
+			matched = append(matched, cr.route.Parser)
+			name := cr.route.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			names = append(names, name)
+		}
+	}
+	return matched, names
+}
+
+// 获得HTTP响应的内容类型。未声明内容类型时根据响应体探测。
+func mediaType(httpResp *http.Response, body []byte) string {
+	contentType := httpResp.Header.Get("Content-Type")
+	if contentType == "" && len(body) > 0 {
+		contentType = http.DetectContentType(body)
+	}
+	mimeType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(mimeType)
+}
+
+// 判断路由规则是否与HTTP响应相匹配。
+func (cr *compiledRoute) match(httpResp *http.Response, mimeType string) bool {
+	reqUrl := httpResp.Request.URL
+	if cr.urlGlob != nil {
+		target := reqUrl.String()
+		if cr.globOnPath {
+			target = reqUrl.Path
+		}
+		if !cr.urlGlob.MatchString(target) {
+			return false
+		}
+	}
+	if cr.urlRegexp != nil && !cr.urlRegexp.MatchString(reqUrl.String()) {
+		return false
+	}
+	if len(cr.route.Hosts) > 0 && !matchHost(cr.route.Hosts, reqUrl.Hostname()) {
+		return false
+	}
+	if len(cr.route.MimeTypes) > 0 && !matchMimeType(cr.route.MimeTypes, mimeType) {
+		return false
+	}
+	return true
+}
+
+// 判断主机名是否与列表中的任一主机名相匹配。
+func matchHost(hosts []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if strings.HasPrefix(h, "*.") {
+			if host == h[2:] || strings.HasSuffix(host, h[1:]) {
+				return true
+			}
+		} else if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断内容类型是否与列表中的任一内容类型相匹配。
+func matchMimeType(mimeTypes []string, mimeType string) bool {
+	if mimeType == "" {
+		return false
+	}
+	for _, m := range mimeTypes {
+		m = strings.ToLower(m)
+		if m == mimeType || m == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(m, "/*") && strings.HasPrefix(mimeType, m[:len(m)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (router *myParserRouter) Parse(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	body, err := readBody(httpResp)
+	if err != nil {
+		return nil, []error{err}
+	}
+	respParsers, names := router.match(httpResp, body)
+	// 没有匹配的响应（如图片或未被关注的内容类型）很常见，不应被视为错误。
+	if len(respParsers) == 0 {
+		atomic.AddUint64(&router.unmatched, 1)
+		return nil, nil
+	}
+	atomic.AddUint64(&router.routed, 1)
+	// 路由器本身作为响应解析函数执行时已受到时限的约束，此处只隔离运行时恐慌。
+	return runParsers(respParsers, names, httpResp, body, respDepth, 0)
+}
+
+func (router *myParserRouter) Count() []uint64 {
+	counts := make([]uint64, 2)
+	counts[0] = atomic.LoadUint64(&router.routed)
+	counts[1] = atomic.LoadUint64(&router.unmatched)
+	return counts
+}
+
+// 摘要信息模板。
+var routerSummaryTemplate = "routeNumber: %d, routed: %d, unmatched: %d"
+
+func (router *myParserRouter) Summary() string {
+	router.rwmutex.RLock()
+	routeNumber := len(router.routes)
+	router.rwmutex.RUnlock()
+	counts := router.Count()
+	return fmt.Sprintf(routerSummaryTemplate, routeNumber, counts[0], counts[1])
+}
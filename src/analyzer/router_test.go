@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"base"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 生成只记录被调用的路由规则名称的响应解析函数。
+func recordingParser(name string, called *[]string) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		*called = append(*called, name)
+		return nil, nil
+	}
+}
+
+func TestRouterMatching(t *testing.T) {
+	var called []string
+	router := NewParserRouter()
+	routes := []Route{
+		{Name: "html", MimeTypes: []string{"text/html"}},
+		{Name: "text", MimeTypes: []string{"text/*"}},
+		{Name: "articles", UrlGlob: "/article/*"},
+		{Name: "blog", Hosts: []string{"*.blog.example.com"}},
+		{Name: "json", UrlRegexp: `\.json$`, MimeTypes: []string{"application/json"}},
+	}
+	for _, route := range routes {
+		route.Parser = recordingParser(route.Name, &called)
+		if err := router.Add(route); err != nil {
+			t.Fatalf("Can not add the route %s: %s", route.Name, err)
+		}
+	}
+	cases := []struct {
+		url         string
+		contentType string
+		body        string
+		expected    []string
+	}{
+		{"http://example.com/article/1", "text/html; charset=utf-8", "", []string{"articles", "html", "text"}},
+		{"http://example.com/notes.txt", "text/plain", "", []string{"text"}},
+		{"http://a.blog.example.com/", "image/png", "", []string{"blog"}},
+		{"http://blog.example.com/x.json", "application/json", "{}", []string{"blog", "json"}},
+		// 未声明内容类型时根据响应体探测。
+		{"http://example.com/", "", "<!DOCTYPE html><html></html>", []string{"html", "text"}},
+	}
+	for _, c := range cases {
+		called = nil
+		_, errs := router.Parse(newTestResponse(t, c.url, c.contentType, c.body), 0)
+		sort.Strings(called)
+		if len(errs) != 0 || !reflect.DeepEqual(called, c.expected) {
+			t.Errorf("Unexpected routes for %s: %v, errs=%v", c.url, called, errs)
+		}
+	}
+	if err := router.Add(Route{Name: "invalid"}); err == nil {
+		t.Errorf("A route without parser should be rejected")
+	}
+	if err := router.Add(Route{Name: "invalid", Parser: recordingParser("", &called), UrlRegexp: "("}); err == nil {
+		t.Errorf("A route with an invalid regexp should be rejected")
+	}
+}
+
+func TestRouterUnmatched(t *testing.T) {
+	var called []string
+	router := NewParserRouter()
+	router.Add(Route{Name: "html", Parser: recordingParser("html", &called), MimeTypes: []string{"text/html"}})
+	dataList, errs := router.Parse(newTestResponse(t, "http://example.com/a.png", "image/png", ""), 0)
+	if len(dataList) != 0 || len(errs) != 0 || len(called) != 0 {
+		t.Errorf("An unmatched response should be ignored: %v, %v", dataList, errs)
+	}
+	router.Parse(newTestResponse(t, "http://example.com/", "text/html", ""), 0)
+	if counts := router.Count(); !reflect.DeepEqual(counts, []uint64{1, 1}) {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+func TestRouterParserPanic(t *testing.T) {
+	router := NewParserRouter()
+	var called []string
+	router.Add(Route{Name: "first", Parser: recordingParser("first", &called)})
+	router.Add(Route{Name: "broken", Parser: func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		panic("boom")
+	}})
+	router.Add(Route{Parser: recordingParser("last", &called)})
+	_, errs := router.Parse(newTestResponse(t, "http://example.com/", "text/html", ""), 0)
+	if len(errs) != 1 || !reflect.DeepEqual(called, []string{"first", "last"}) {
+		t.Fatalf("A panic should not affect the other parsers: called=%v, errs=%v", called, errs)
+	}
+	analyzerErr, ok := errs[0].(base.AnalyzerError)
+	if !ok || analyzerErr.Route() != "broken" || analyzerErr.ParserIndex() != -1 ||
+		!strings.Contains(analyzerErr.Error(), "route=broken") {
+		t.Errorf("The error should carry the route name: %v", errs[0])
+	}
+}
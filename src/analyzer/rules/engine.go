@@ -0,0 +1,109 @@
+package rules
+
+import (
+	"analyzer/parsers"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 选择器引擎中的节点的接口类型。
+type node interface {
+	// 获得节点的文本内容。
+	text() string
+	// 获得节点的属性值。
+	attr(name string) (string, bool)
+	// 获得节点的HTML（或XML）内容。
+	html() (string, error)
+}
+
+// 选择器的接口类型。
+type selector interface {
+	// 查找节点下所有与选择器相匹配的节点。
+	find(n node) []node
+}
+
+// 选择器引擎的接口类型。
+type engine interface {
+	// 解析HTTP响应，获得文档的根节点以及文档中的相对网址所依据的基础网址。
+	// 若HTTP响应不适合被该引擎解析，则结果值中的根节点为nil且错误值也为nil。
+	parse(httpResp *http.Response) (node, *url.URL, error)
+	// 编译选择器。
+	compile(expr string) (selector, error)
+}
+
+// 已注册的选择器引擎。
+var engines = map[string]engine{
+	"css":   &cssEngine{},
+	"xpath": &xpathEngine{},
+}
+
+// 获得选择器引擎。名称为空时使用css引擎。
+func getEngine(name string) (engine, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = "css"
+	}
+	e, ok := engines[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unsupported selector engine '%s'!", name))
+	}
+	return e, nil
+}
+
+// 基于CSS选择器的引擎。
+type cssEngine struct{}
+
+func (e *cssEngine) parse(httpResp *http.Response) (node, *url.URL, error) {
+	doc, baseUrl, err := parsers.LoadDocument(httpResp)
+	if err != nil || doc == nil {
+		return nil, nil, err
+	}
+	return &cssNode{sel: doc.Selection}, baseUrl, nil
+}
+
+func (e *cssEngine) compile(expr string) (selector, error) {
+	sel, err := cascadia.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &cssSelector{sel: sel}, nil
+}
+
+// CSS选择器引擎中的节点。
+type cssNode struct {
+	sel *goquery.Selection
+}
+
+func (n *cssNode) text() string {
+	return n.sel.Text()
+}
+
+func (n *cssNode) attr(name string) (string, bool) {
+	return n.sel.Attr(name)
+}
+
+func (n *cssNode) html() (string, error) {
+	return n.sel.Html()
+}
+
+// CSS选择器。
+type cssSelector struct {
+	sel cascadia.Selector
+}
+
+func (s *cssSelector) find(n node) []node {
+	cn, ok := n.(*cssNode)
+	if !ok {
+		return nil
+	}
+	nodes := make([]node, 0)
+	cn.sel.FindMatcher(s.sel).Each(func(index int, sel *goquery.Selection) {
+		nodes = append(nodes, &cssNode{sel: sel})
+	})
+	return nodes
+}
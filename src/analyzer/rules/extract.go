@@ -0,0 +1,137 @@
+package rules
+
+import (
+	"analyzer/parsers"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 字段值的类型转换函数。
+type convert func(raw string, baseUrl *url.URL) (interface{}, error)
+
+// 各种字段类型对应的类型转换函数。
+var converters = map[string]convert{
+	"": func(raw string, baseUrl *url.URL) (interface{}, error) {
+		return raw, nil
+	},
+	"string": func(raw string, baseUrl *url.URL) (interface{}, error) {
+		return raw, nil
+	},
+	"int": func(raw string, baseUrl *url.URL) (interface{}, error) {
+		// 允许数字中出现千位分隔符。
+		return strconv.ParseInt(strings.Replace(raw, ",", "", -1), 10, 64)
+	},
+	"float": func(raw string, baseUrl *url.URL) (interface{}, error) {
+		return strconv.ParseFloat(strings.Replace(raw, ",", "", -1), 64)
+	},
+	"bool": func(raw string, baseUrl *url.URL) (interface{}, error) {
+		return strconv.ParseBool(raw)
+	},
+	"url": func(raw string, baseUrl *url.URL) (interface{}, error) {
+		u, err := parsers.ResolveHref(baseUrl, raw)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return nil, errors.New(fmt.Sprintf("Unsupported url '%s'!", raw))
+		}
+		return u.String(), nil
+	},
+}
+
+// 从容器中提取条目。
+// 若缺少必需字段，则第二个结果值为false。提取过程中的错误会被加入收集器。
+func (rule *Rule) extractItem(
+	container node,
+	baseUrl *url.URL,
+	c *parsers.Collector) (map[string]interface{}, bool) {
+	imap := make(map[string]interface{})
+	for _, field := range rule.Fields {
+		value, err := field.extract(container, baseUrl)
+		if err != nil {
+			c.AddError(errors.New(
+				fmt.Sprintf("Field '%s' of rule '%s': %s", field.Name, rule.Name, err)))
+		}
+		if value == nil {
+			value = field.Default
+		}
+		if value == nil {
+			if field.Required {
+				return nil, false
+			}
+			continue
+		}
+		imap[field.Name] = value
+	}
+	return imap, true
+}
+
+// 从容器中提取字段值。字段缺失时结果值为nil。
+func (field *Field) extract(container node, baseUrl *url.URL) (interface{}, error) {
+	nodes := []node{container}
+	if field.selector != nil {
+		nodes = field.selector.find(container)
+	}
+	values := make([]interface{}, 0, len(nodes))
+	for _, n := range nodes {
+		raw, ok, err := field.extractRaw(n)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		value, err := converters[strings.ToLower(field.Type)](raw, baseUrl)
+		if err != nil {
+			return nil, err
+		}
+		if !field.Multiple {
+			return value, nil
+		}
+		values = append(values, value)
+	}
+	if !field.Multiple || len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
+
+// 从节点中提取未经类型转换的字段值。若节点中没有所需的值，则第二个结果值为false。
+func (field *Field) extractRaw(n node) (string, bool, error) {
+	var raw string
+	switch field.Extract {
+	case "attr":
+		value, exists := n.attr(field.Attr)
+		if !exists {
+			return "", false, nil
+		}
+		raw = value
+	case "html":
+		value, err := n.html()
+		if err != nil {
+			return "", false, err
+		}
+		raw = value
+	default:
+		raw = strings.Join(strings.Fields(n.text()), " ")
+	}
+	raw = strings.TrimSpace(raw)
+	if field.regexp != nil {
+		matches := field.regexp.FindStringSubmatch(raw)
+		if matches == nil {
+			return "", false, nil
+		}
+		if len(matches) > 1 {
+			raw = matches[1]
+		} else {
+			raw = matches[0]
+		}
+	}
+	if raw == "" {
+		return "", false, nil
+	}
+	return raw, true, nil
+}
@@ -0,0 +1,226 @@
+package rules
+
+import (
+	"analyzer"
+	"analyzer/parsers"
+	"base"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// 提取规则集。其JSON格式形如：
+//
+//	{
+//	  "rules": [{
+//	    "name": "product",
+//	    "url": "^http://shop\\.example\\.com/list",
+//	    "list": "div.product",
+//	    "fields": [
+//	      {"name": "title", "selector": "h2", "required": true},
+//	      {"name": "price", "selector": ".price", "regex": "([\\d.,]+)", "type": "float"},
+//	      {"name": "link", "selector": "a", "extract": "attr", "attr": "href", "type": "url"}
+//	    ],
+//	    "follow": [{"selector": "a.next"}]
+//	  }]
+//	}
+type RuleSet struct {
+	Rules []*Rule `json:"rules"` // 提取规则的列表。
+}
+
+// 提取规则。每条规则对应一个响应解析函数。
+type Rule struct {
+	Name   string    `json:"name"`   // 规则的名称。会被放入条目的rule字段中。
+	Url    string    `json:"url"`    // 适用的网址的正则表达式。为空表示适用于所有网址。
+	Engine string    `json:"engine"` // 选择器引擎的名称，可选css（默认）和xpath。
+	List   string    `json:"list"`   // 列表容器的选择器。每个容器生成一个条目；为空表示整个文档只生成一个条目。
+	Fields []*Field  `json:"fields"` // 条目字段的列表。
+	Follow []*Follow `json:"follow"` // 需要跟随的链接的列表。
+
+	urlRegexp *regexp.Regexp // 编译后的网址正则表达式。
+	engine    engine         // 选择器引擎。
+	list      selector       // 编译后的列表容器的选择器。
+}
+
+// 条目字段的提取规则。
+type Field struct {
+	Name     string      `json:"name"`     // 字段名称。
+	Selector string      `json:"selector"` // 选择器。为空表示容器（或文档）本身。
+	Extract  string      `json:"extract"`  // 提取方式，可选text（默认）、attr和html。
+	Attr     string      `json:"attr"`     // 提取方式为attr时的属性名称。
+	Regex    string      `json:"regex"`    // 对提取结果进行后处理的正则表达式。若其中有分组则取第一个分组，否则取整个匹配。
+	Type     string      `json:"type"`     // 字段类型，可选string（默认）、int、float、bool和url。
+	Multiple bool        `json:"multiple"` // 是否提取所有匹配的节点。若为true则字段值为切片。
+	Required bool        `json:"required"` // 是否为必需字段。缺少必需字段的条目会被丢弃。
+	Default  interface{} `json:"default"`  // 字段缺失时的默认值。
+
+	selector selector       // 编译后的选择器。
+	regexp   *regexp.Regexp // 编译后的后处理正则表达式。
+}
+
+// 链接的跟随规则。
+type Follow struct {
+	Selector string `json:"selector"` // 选择器。
+	Attr     string `json:"attr"`     // 链接地址所在的属性名称。默认为href。
+
+	selector selector // 编译后的选择器。
+}
+
+// 从文件中加载规则集，并将其编译为响应解析函数的序列。
+func LoadFile(path string) ([]analyzer.ParseResponse, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(data)
+}
+
+// 将JSON格式的规则集编译为响应解析函数的序列。
+func Compile(data []byte) ([]analyzer.ParseResponse, error) {
+	var ruleSet RuleSet
+	if err := json.Unmarshal(data, &ruleSet); err != nil {
+		return nil, err
+	}
+	respParsers := make([]analyzer.ParseResponse, 0, len(ruleSet.Rules))
+	for i, rule := range ruleSet.Rules {
+		if rule == nil {
+			return nil, errors.New(fmt.Sprintf("The rule [%d] is invalid!", i))
+		}
+		parser, err := rule.Parser()
+		if err != nil {
+			return nil, err
+		}
+		respParsers = append(respParsers, parser)
+	}
+	return respParsers, nil
+}
+
+// 编译规则，获得相应的响应解析函数。
+func (rule *Rule) Parser() (analyzer.ParseResponse, error) {
+	if err := rule.compile(); err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid rule '%s': %s", rule.Name, err))
+	}
+	return rule.parse, nil
+}
+
+// 编译规则中的正则表达式和选择器。
+func (rule *Rule) compile() error {
+	var err error
+	if rule.Url != "" {
+		if rule.urlRegexp, err = regexp.Compile(rule.Url); err != nil {
+			return err
+		}
+	}
+	if rule.engine, err = getEngine(rule.Engine); err != nil {
+		return err
+	}
+	if rule.List != "" {
+		if rule.list, err = rule.engine.compile(rule.List); err != nil {
+			return err
+		}
+	}
+	if len(rule.Fields) == 0 && len(rule.Follow) == 0 {
+		return errors.New("The rule has neither fields nor follows!")
+	}
+	for i, field := range rule.Fields {
+		if field == nil || field.Name == "" {
+			return errors.New(fmt.Sprintf("The field [%d] is invalid!", i))
+		}
+		if err := field.compile(rule.engine); err != nil {
+			return errors.New(fmt.Sprintf("Invalid field '%s': %s", field.Name, err))
+		}
+	}
+	for i, follow := range rule.Follow {
+		if follow == nil || follow.Selector == "" {
+			return errors.New(fmt.Sprintf("The follow [%d] is invalid!", i))
+		}
+		if follow.selector, err = rule.engine.compile(follow.Selector); err != nil {
+			return err
+		}
+		if follow.Attr == "" {
+			follow.Attr = "href"
+		}
+	}
+	return nil
+}
+
+// 编译字段中的正则表达式和选择器。
+func (field *Field) compile(e engine) error {
+	var err error
+	if field.Selector != "" {
+		if field.selector, err = e.compile(field.Selector); err != nil {
+			return err
+		}
+	}
+	if field.Regex != "" {
+		if field.regexp, err = regexp.Compile(field.Regex); err != nil {
+			return err
+		}
+	}
+	switch field.Extract {
+	case "", "text", "html":
+	case "attr":
+		if field.Attr == "" {
+			return errors.New("The attribute name is missing!")
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unsupported extract method '%s'!", field.Extract))
+	}
+	if _, ok := converters[strings.ToLower(field.Type)]; !ok {
+		return errors.New(fmt.Sprintf("Unsupported field type '%s'!", field.Type))
+	}
+	return nil
+}
+
+// 规则对应的响应解析函数。
+func (rule *Rule) parse(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	reqUrl := httpResp.Request.URL
+	if rule.urlRegexp != nil && !rule.urlRegexp.MatchString(reqUrl.String()) {
+		return nil, nil
+	}
+	root, baseUrl, err := rule.engine.parse(httpResp)
+	if err != nil {
+		return nil, []error{err}
+	}
+	if root == nil {
+		return nil, nil
+	}
+	c := parsers.NewCollector(httpResp, respDepth)
+	if len(rule.Fields) > 0 {
+		containers := []node{root}
+		if rule.list != nil {
+			containers = rule.list.find(root)
+		}
+		for _, container := range containers {
+			imap, ok := rule.extractItem(container, baseUrl, c)
+			if !ok {
+				continue
+			}
+			if rule.Name != "" {
+				imap["rule"] = rule.Name
+			}
+			c.AddItem(imap)
+		}
+	}
+	for _, follow := range rule.Follow {
+		for _, n := range follow.selector.find(root) {
+			href, exists := n.attr(follow.Attr)
+			if !exists {
+				continue
+			}
+			followUrl, err := parsers.ResolveHref(baseUrl, href)
+			if err != nil {
+				c.AddError(err)
+				continue
+			}
+			if followUrl != nil {
+				c.AddRequest(followUrl)
+			}
+		}
+	}
+	return c.Result()
+}
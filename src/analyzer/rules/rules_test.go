@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"base"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 把响应体包装为HTTP响应。
+func newTestResponse(t *testing.T, reqUrl string, contentType string, body string) *http.Response {
+	httpReq, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		t.Fatalf("Can not create the request: %s", err)
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	return &http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    httpReq,
+	}
+}
+
+// 编译只有一条规则的规则集，并用它解析响应。
+func parseWithRule(t *testing.T, ruleJson string, httpResp *http.Response) ([]base.Item, []string, []error) {
+	respParsers, err := Compile([]byte(`{"rules": [` + ruleJson + `]}`))
+	if err != nil {
+		t.Fatalf("Can not compile the rule: %s", err)
+	}
+	if len(respParsers) != 1 {
+		t.Fatalf("Unexpected parser count: %d", len(respParsers))
+	}
+	dataList, errs := respParsers[0](httpResp, 0)
+	items := make([]base.Item, 0)
+	urls := make([]string, 0)
+	for _, data := range dataList {
+		switch d := data.(type) {
+		case *base.Item:
+			items = append(items, *d)
+		case *base.Request:
+			urls = append(urls, d.HttpReq().URL.String())
+		}
+	}
+	return items, urls, errs
+}
+
+const shopPage = `<html><head><base href="http://shop.example.com/"></head><body>
+<div class="product">
+  <h2> Red   Shoes </h2>
+  <span class="price">Price: 1,299.50</span>
+  <span class="stock">12 left</span>
+  <a href="p/1"><b>Detail</b></a>
+  <span class="tag">new</span><span class="tag">sale</span>
+</div>
+<div class="product">
+  <h2>Blue Hat</h2>
+  <span class="price">unknown</span>
+  <a href="/p/2">Detail</a>
+</div>
+<div class="product">
+  <span class="price">9.00</span>
+</div>
+<a class="next" href="list?page=2">Next</a>
+<a class="next">No href</a>
+</body></html>`
+
+func TestCssRule(t *testing.T) {
+	rule := `{
+  "name": "product",
+  "url": "^http://shop\\.example\\.com/list",
+  "list": "div.product",
+  "fields": [
+    {"name": "title", "selector": "h2", "required": true},
+    {"name": "price", "selector": ".price", "regex": "([\\d.,]+)", "type": "float"},
+    {"name": "stock", "selector": ".stock", "regex": "\\d+", "type": "int", "default": 0},
+    {"name": "link", "selector": "a", "extract": "attr", "attr": "href", "type": "url"},
+    {"name": "label", "selector": "a", "extract": "html"},
+    {"name": "tags", "selector": ".tag", "multiple": true}
+  ],
+  "follow": [{"selector": "a.next"}]
+}`
+	httpResp := newTestResponse(t, "http://shop.example.com/list", "text/html; charset=utf-8", shopPage)
+	items, urls, errs := parseWithRule(t, rule, httpResp)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	expected := []base.Item{
+		{
+			"rule":       "product",
+			"parent_url": "http://shop.example.com/list",
+			"title":      "Red Shoes",
+			"price":      1299.5,
+			"stock":      int64(12),
+			"link":       "http://shop.example.com/p/1",
+			"label":      "<b>Detail</b>",
+			"tags":       []interface{}{"new", "sale"},
+		},
+		{
+			"rule":       "product",
+			"parent_url": "http://shop.example.com/list",
+			"title":      "Blue Hat",
+			"stock":      float64(0),
+			"link":       "http://shop.example.com/p/2",
+			"label":      "Detail",
+		},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items:\n%v\nexpected:\n%v", items, expected)
+	}
+	if !reflect.DeepEqual(urls, []string{"http://shop.example.com/list?page=2"}) {
+		t.Errorf("Unexpected follow requests: %v", urls)
+	}
+}
+
+func TestRuleUrlMismatch(t *testing.T) {
+	rule := `{"url": "^http://shop\\.example\\.com/list", "fields": [{"name": "title", "selector": "h2"}]}`
+	httpResp := newTestResponse(t, "http://shop.example.com/about", "text/html", shopPage)
+	items, urls, errs := parseWithRule(t, rule, httpResp)
+	if len(items) != 0 || len(urls) != 0 || len(errs) != 0 {
+		t.Errorf("A rule should ignore the responses of other urls: %v, %v, %v", items, urls, errs)
+	}
+}
+
+func TestRuleConversionError(t *testing.T) {
+	rule := `{"name": "product", "fields": [{"name": "count", "selector": "h2", "type": "int"}]}`
+	httpResp := newTestResponse(t, "http://shop.example.com/list", "text/html", shopPage)
+	items, _, errs := parseWithRule(t, rule, httpResp)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "Field 'count' of rule 'product'") {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	// 转换失败的字段被视为缺失。
+	if len(items) != 1 {
+		t.Fatalf("Unexpected items: %v", items)
+	}
+	if _, ok := items[0]["count"]; ok {
+		t.Errorf("Unexpected item: %v", items[0])
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	invalid := []string{
+		`{"rules": [null]}`,
+		`{"rules": [{"name": "empty"}]}`,
+		`{"rules": [{"url": "(", "fields": [{"name": "a"}]}]}`,
+		`{"rules": [{"engine": "jquery", "fields": [{"name": "a"}]}]}`,
+		`{"rules": [{"list": "div[", "fields": [{"name": "a"}]}]}`,
+		`{"rules": [{"fields": [{"selector": "h2"}]}]}`,
+		`{"rules": [{"fields": [{"name": "a", "selector": ":bogus"}]}]}`,
+		`{"rules": [{"fields": [{"name": "a", "regex": "["}]}]}`,
+		`{"rules": [{"fields": [{"name": "a", "extract": "attr"}]}]}`,
+		`{"rules": [{"fields": [{"name": "a", "extract": "json"}]}]}`,
+		`{"rules": [{"fields": [{"name": "a", "type": "date"}]}]}`,
+		`{"rules": [{"follow": [{"attr": "href"}]}]}`,
+		`{"rules": `,
+	}
+	for _, data := range invalid {
+		if _, err := Compile([]byte(data)); err == nil {
+			t.Errorf("The rule set should be invalid: %s", data)
+		}
+	}
+	respParsers, err := Compile([]byte(`{"rules": [
+  {"fields": [{"name": "title", "selector": "h1", "type": "STRING"}]},
+  {"engine": "CSS", "follow": [{"selector": "a"}]}
+]}`))
+	if err != nil || len(respParsers) != 2 {
+		t.Errorf("Unexpected result: %d, %v", len(respParsers), err)
+	}
+}
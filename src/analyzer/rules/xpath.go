@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"analyzer/parsers"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 基于XPath表达式的引擎。
+// 内容类型中含有xml（XHTML除外）的响应会被作为XML文档解析，其他的响应会被作为HTML文档解析。
+type xpathEngine struct{}
+
+func (e *xpathEngine) parse(httpResp *http.Response) (node, *url.URL, error) {
+	if !isXml(httpResp) {
+		doc, baseUrl, err := parsers.LoadDocument(httpResp)
+		if err != nil || doc == nil || len(doc.Nodes) == 0 {
+			return nil, nil, err
+		}
+		return &htmlNode{n: doc.Nodes[0]}, baseUrl, nil
+	}
+	if httpResp.Body == nil {
+		return nil, nil, nil
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != 200 {
+		return nil, nil, nil
+	}
+	doc, err := xmlquery.Parse(httpResp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &xmlNode{n: doc}, httpResp.Request.URL, nil
+}
+
+func (e *xpathEngine) compile(expr string) (selector, error) {
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &xpathSelector{expr: compiled}, nil
+}
+
+// 判断HTTP响应的内容是否为XML。
+func isXml(httpResp *http.Response) bool {
+	contentType := strings.ToLower(httpResp.Header.Get("Content-Type"))
+	return strings.Contains(contentType, "xml") && !strings.Contains(contentType, "xhtml")
+}
+
+// XPath选择器。
+type xpathSelector struct {
+	expr *xpath.Expr
+}
+
+func (s *xpathSelector) find(n node) []node {
+	nodes := make([]node, 0)
+	switch tn := n.(type) {
+	case *htmlNode:
+		for _, found := range htmlquery.QuerySelectorAll(tn.n, s.expr) {
+			nodes = append(nodes, &htmlNode{n: found})
+		}
+	case *xmlNode:
+		for _, found := range xmlquery.QuerySelectorAll(tn.n, s.expr) {
+			nodes = append(nodes, &xmlNode{n: found})
+		}
+	}
+	return nodes
+}
+
+// HTML文档中的节点。
+type htmlNode struct {
+	n *html.Node
+}
+
+func (n *htmlNode) text() string {
+	return htmlquery.InnerText(n.n)
+}
+
+func (n *htmlNode) attr(name string) (string, bool) {
+	for _, attr := range n.n.Attr {
+		if attr.Key == name {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func (n *htmlNode) html() (string, error) {
+	return htmlquery.OutputHTML(n.n, false), nil
+}
+
+// XML文档中的节点。
+type xmlNode struct {
+	n *xmlquery.Node
+}
+
+func (n *xmlNode) text() string {
+	return n.n.InnerText()
+}
+
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, attr := range n.n.Attr {
+		qualified := attr.Name.Local
+		if attr.Name.Space != "" {
+			qualified = attr.Name.Space + ":" + attr.Name.Local
+		}
+		if attr.Name.Local == name || qualified == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *xmlNode) html() (string, error) {
+	return n.n.OutputXML(false), nil
+}
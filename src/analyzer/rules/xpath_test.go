@@ -0,0 +1,145 @@
+package rules
+
+import (
+	"base"
+	"reflect"
+	"testing"
+)
+
+func TestXpathRuleHtml(t *testing.T) {
+	rule := `{
+  "name": "product",
+  "engine": "xpath",
+  "list": "//div[@class='product']",
+  "fields": [
+    {"name": "title", "selector": ".//h2", "required": true},
+    {"name": "price", "selector": ".//span[@class='price']", "regex": "([\\d.,]+)", "type": "float"},
+    {"name": "link", "selector": ".//a", "extract": "attr", "attr": "href", "type": "url"},
+    {"name": "label", "selector": ".//a", "extract": "html"},
+    {"name": "tags", "selector": ".//span[@class='tag']", "multiple": true}
+  ],
+  "follow": [{"selector": "//a[@class='next']"}]
+}`
+	httpResp := newTestResponse(t, "http://shop.example.com/list", "text/html; charset=utf-8", shopPage)
+	items, urls, errs := parseWithRule(t, rule, httpResp)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	// 与CSS选择器的规则得到相同的条目。
+	expected := []base.Item{
+		{
+			"rule":       "product",
+			"parent_url": "http://shop.example.com/list",
+			"title":      "Red Shoes",
+			"price":      1299.5,
+			"link":       "http://shop.example.com/p/1",
+			"label":      "<b>Detail</b>",
+			"tags":       []interface{}{"new", "sale"},
+		},
+		{
+			"rule":       "product",
+			"parent_url": "http://shop.example.com/list",
+			"title":      "Blue Hat",
+			"link":       "http://shop.example.com/p/2",
+			"label":      "Detail",
+		},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items:\n%v\nexpected:\n%v", items, expected)
+	}
+	if !reflect.DeepEqual(urls, []string{"http://shop.example.com/list?page=2"}) {
+		t.Errorf("Unexpected follow requests: %v", urls)
+	}
+}
+
+func TestXpathRuleXml(t *testing.T) {
+	catalog := `<?xml version="1.0" encoding="UTF-8"?>
+<catalog xmlns:xlink="http://www.w3.org/1999/xlink">
+  <book id="b1" xlink:href="/books/1">
+    <title>Go in Action</title>
+    <summary><p>Idiomatic Go</p></summary>
+    <price currency="USD">39.99</price>
+    <available>true</available>
+  </book>
+  <book id="b2" xlink:href="http://other.example.com/books/2">
+    <title>The Go Programming Language</title>
+    <price currency="EUR">42</price>
+  </book>
+  <next href="/catalog.xml?page=2"/>
+</catalog>`
+	rule := `{
+  "name": "book",
+  "engine": "xpath",
+  "list": "/catalog/book",
+  "fields": [
+    {"name": "id", "extract": "attr", "attr": "id"},
+    {"name": "title", "selector": "title"},
+    {"name": "price", "selector": "price", "type": "float"},
+    {"name": "currency", "selector": "price", "extract": "attr", "attr": "currency"},
+    {"name": "available", "selector": "available", "type": "bool", "default": false},
+    {"name": "link", "extract": "attr", "attr": "xlink:href", "type": "url"},
+    {"name": "summary", "selector": "summary", "extract": "html"}
+  ],
+  "follow": [{"selector": "//next"}]
+}`
+	httpResp := newTestResponse(t, "http://shop.example.com/catalog.xml", "application/xml", catalog)
+	items, urls, errs := parseWithRule(t, rule, httpResp)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	expected := []base.Item{
+		{
+			"rule":       "book",
+			"parent_url": "http://shop.example.com/catalog.xml",
+			"id":         "b1",
+			"title":      "Go in Action",
+			"price":      39.99,
+			"currency":   "USD",
+			"available":  true,
+			"link":       "http://shop.example.com/books/1",
+			"summary":    "<p>Idiomatic Go</p>",
+		},
+		{
+			"rule":       "book",
+			"parent_url": "http://shop.example.com/catalog.xml",
+			"id":         "b2",
+			"title":      "The Go Programming Language",
+			"price":      float64(42),
+			"currency":   "EUR",
+			"available":  false,
+			"link":       "http://other.example.com/books/2",
+		},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items:\n%v\nexpected:\n%v", items, expected)
+	}
+	if !reflect.DeepEqual(urls, []string{"http://shop.example.com/catalog.xml?page=2"}) {
+		t.Errorf("Unexpected follow requests: %v", urls)
+	}
+}
+
+func TestXpathRuleXhtml(t *testing.T) {
+	// XHTML文档应被作为HTML文档解析。
+	rule := `{"engine": "xpath", "fields": [{"name": "title", "selector": "//h2"}]}`
+	httpResp := newTestResponse(t, "http://shop.example.com/list", "application/xhtml+xml", shopPage)
+	items, _, errs := parseWithRule(t, rule, httpResp)
+	if len(errs) != 0 || len(items) != 1 || items[0]["title"] != "Red Shoes" {
+		t.Errorf("Unexpected result: %v, %v", items, errs)
+	}
+}
+
+func TestXpathCompileErrors(t *testing.T) {
+	invalid := []string{
+		`{"rules": [{"engine": "xpath", "list": "//div[", "fields": [{"name": "a"}]}]}`,
+		`{"rules": [{"engine": "xpath", "fields": [{"name": "a", "selector": "count(("}]}]}`,
+		`{"rules": [{"engine": "xpath", "follow": [{"selector": "//a["}]}]}`,
+	}
+	for _, data := range invalid {
+		if _, err := Compile([]byte(data)); err == nil {
+			t.Errorf("The rule set should be invalid: %s", data)
+		}
+	}
+	if _, err := Compile([]byte(`{"rules": [{"engine": "XPath", "follow": [{"selector": "//a"}]}]}`)); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 计算指纹时使用的词组（shingle）的长度。
+const SIMHASH_SHINGLE_SIZE = 3
+
+// 指纹索引分段的数量。汉明距离不超过该值减一的两个指纹至少有一段完全相同。
+const FINGERPRINT_BANDS = 4
+
+// 计算文本的SimHash指纹。
+// 文本会先被切分为词（中日韩文字以单字为词），再以连续的若干个词组成的词组为特征计算指纹。
+func SimHash(text string) uint64 {
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return 0
+	}
+	size := SIMHASH_SHINGLE_SIZE
+	if len(tokens) < size {
+		size = len(tokens)
+	}
+	var weights [64]int
+	for i := 0; i+size <= len(tokens); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(tokens[i:i+size], " ")))
+		sum := h.Sum64()
+		for bit := uint(0); bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var fingerprint uint64
+	for bit := uint(0); bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// 将文本切分为小写的词。
+func tokenize(text string) []string {
+	tokens := make([]string, 0)
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// 获得两个指纹之间的汉明距离。
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// 指纹索引的接口类型。
+type FingerprintIndex interface {
+	// 查找与给定指纹的汉明距离不超过阈值的已有指纹。
+	// 若找到，则返回该指纹对应的网址和true；否则把给定指纹加入索引并返回false。
+	// 查找与加入是一个原子操作。
+	CheckAndAdd(fingerprint uint64, url string, threshold int) (string, bool)
+	// 获得已索引的指纹的数量。
+	Count() uint64
+}
+
+// 创建指纹索引。
+func NewFingerprintIndex() FingerprintIndex {
+	index := &myFingerprintIndex{entries: make([]fingerprintEntry, 0)}
+	for i := range index.bands {
+		index.bands[i] = make(map[uint16][]int)
+	}
+	return index
+}
+
+// 指纹索引中的条目。
+type fingerprintEntry struct {
+	fingerprint uint64 // 指纹。
+	url         string // 网址。
+}
+
+// 指纹索引的实现类型。
+// 指纹被分为若干段，每一段都有一个从段值到条目下标的映射。
+// 阈值小于段数时只需比较至少有一段相同的指纹，否则需要比较全部指纹。
+type myFingerprintIndex struct {
+	entries []fingerprintEntry                  // 条目的列表。
+	bands   [FINGERPRINT_BANDS]map[uint16][]int // 各段的映射。
+	mutex   sync.Mutex                          // 互斥锁。
+}
+
+// 获得指纹的第i段。
+func band(fingerprint uint64, i int) uint16 {
+	return uint16(fingerprint >> (uint(i) * 16))
+}
+
+func (index *myFingerprintIndex) CheckAndAdd(fingerprint uint64, url string, threshold int) (string, bool) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	if threshold < FINGERPRINT_BANDS {
+		for i := range index.bands {
+			for _, pos := range index.bands[i][band(fingerprint, i)] {
+				entry := index.entries[pos]
+				if HammingDistance(entry.fingerprint, fingerprint) <= threshold {
+					return entry.url, true
+				}
+			}
+		}
+	} else {
+		for _, entry := range index.entries {
+			if HammingDistance(entry.fingerprint, fingerprint) <= threshold {
+				return entry.url, true
+			}
+		}
+	}
+	pos := len(index.entries)
+	index.entries = append(index.entries, fingerprintEntry{fingerprint: fingerprint, url: url})
+	for i := range index.bands {
+		b := band(fingerprint, i)
+		index.bands[i][b] = append(index.bands[i][b], pos)
+	}
+	return "", false
+}
+
+func (index *myFingerprintIndex) Count() uint64 {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	return uint64(len(index.entries))
+}
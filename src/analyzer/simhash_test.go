@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 生成文本用的词汇表。
+var testVocabulary = strings.Fields(`the city council met on tuesday evening to discuss new public transport
+plan after three hours of debate members agreed extend tram line northern districts add night buses
+weekends and reduce fares for students retired residents mayor said that first construction works would
+start next spring whole project should be finished within four years several complained about noise
+traffic during but most speakers welcomed decision asked more bicycle lanes along`)
+
+// 用给定的种子生成由词汇表中的词组成的文本。
+func randomWords(seed int64, n int) []string {
+	r := rand.New(rand.NewSource(seed))
+	words := make([]string, n)
+	for i := range words {
+		words[i] = testVocabulary[r.Intn(len(testVocabulary))]
+	}
+	return words
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("Hello, World! 中文网页 abc123 ĉu?")
+	expected := []string{"hello", "world", "中", "文", "网", "页", "abc123", "ĉu"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Unexpected tokens: %q", tokens)
+	}
+}
+
+func TestSimHashDistance(t *testing.T) {
+	words := randomWords(1, 1000)
+	text := strings.Join(words, " ")
+	fingerprint := SimHash(text)
+	if fingerprint == 0 {
+		t.Fatalf("The fingerprint should not be empty")
+	}
+	// 大小写、标点和空白的差异不影响指纹。
+	if d := HammingDistance(fingerprint, SimHash(strings.ToUpper(strings.Join(words, " ,\n")))); d != 0 {
+		t.Errorf("Unexpected distance of the same words: %d", d)
+	}
+	// 带有会话ID等少量差异的网页与原网页的距离不超过默认阈值。
+	threshold := DefaultNearDupArgs().Threshold
+	if d := HammingDistance(fingerprint, SimHash(text+" session 8f3a9c")); d > threshold {
+		t.Errorf("The distance of a mirrored page is too large: %d", d)
+	}
+	words[len(words)/2] = "wednesday"
+	if d := HammingDistance(fingerprint, SimHash(strings.Join(words, " "))); d > threshold {
+		t.Errorf("The distance of an edited page is too large: %d", d)
+	}
+	other := strings.Join(randomWords(2, 1000), " ")
+	if d := HammingDistance(fingerprint, SimHash(other)); d <= 20 {
+		t.Errorf("The distance of different pages is too small: %d", d)
+	}
+	if SimHash("") != 0 || SimHash(" ,.!") != 0 {
+		t.Errorf("The fingerprint of an empty text should be 0")
+	}
+	// 词数少于词组长度的文本也有指纹。
+	if SimHash("hello world") == 0 || SimHash("hello world") == SimHash("world hello") {
+		t.Errorf("Unexpected fingerprints of short texts")
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	cases := []struct {
+		a, b     uint64
+		distance int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xFFFFFFFFFFFFFFFF, 0, 64},
+		{0xF0F0, 0x0F0F, 16},
+		{1 << 63, 1, 2},
+	}
+	for _, c := range cases {
+		if d := HammingDistance(c.a, c.b); d != c.distance {
+			t.Errorf("Unexpected distance between %x and %x: %d", c.a, c.b, d)
+		}
+	}
+}
+
+func TestFingerprintIndex(t *testing.T) {
+	// 各段各有一位不同的指纹，用于检验阈值小于段数时的分段查找。
+	var fingerprint uint64 = 0x0123456789ABCDEF
+	spread := func(n int) uint64 {
+		f := fingerprint
+		for i := 0; i < n; i++ {
+			f ^= 1 << (uint(i)*16 + 7)
+		}
+		return f
+	}
+	for threshold := 0; threshold <= FINGERPRINT_BANDS+1; threshold++ {
+		index := NewFingerprintIndex()
+		if _, dup := index.CheckAndAdd(fingerprint, "http://example.com/a", threshold); dup {
+			t.Errorf("The first fingerprint should not be a duplicate")
+		}
+		for distance := 0; distance <= FINGERPRINT_BANDS; distance++ {
+			url, dup := index.CheckAndAdd(spread(distance), fmt.Sprintf("http://example.com/%d", distance), threshold)
+			if expected := distance <= threshold; dup != expected {
+				t.Errorf("Unexpected result of distance %d (threshold=%d): %v", distance, threshold, dup)
+			} else if dup && url != "http://example.com/a" {
+				t.Errorf("Unexpected duplicate url: %s", url)
+			}
+			// 只保留第一个指纹，以免后加入的指纹影响后续的判断。
+			index = NewFingerprintIndex()
+			index.CheckAndAdd(fingerprint, "http://example.com/a", threshold)
+		}
+	}
+	index := NewFingerprintIndex()
+	index.CheckAndAdd(1, "http://example.com/1", 0)
+	index.CheckAndAdd(1, "http://example.com/2", 0)
+	index.CheckAndAdd(2, "http://example.com/3", 0)
+	if count := index.Count(); count != 2 {
+		t.Errorf("Unexpected fingerprint count: %d", count)
+	}
+}
@@ -0,0 +1,303 @@
+package analyzer
+
+import (
+	"base"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 站点地图中的网址条目。
+type SitemapUrl struct {
+	Loc        string    // 网址。
+	LastMod    time.Time // 最后修改时间。零值表示未提供。
+	ChangeFreq string    // 更新频率。
+	Priority   float64   // 站点地图中声明的优先级，取值范围为[0,1]。未提供时为0.5。
+}
+
+// 站点地图的解析结果。
+type Sitemap struct {
+	Urls     []SitemapUrl // urlset文件中的网页网址。
+	Sitemaps []SitemapUrl // sitemapindex文件中的子站点地图网址。
+}
+
+const (
+	// 站点地图（解压后）的最大字节数。
+	SITEMAP_MAX_SIZE = 50 * 1024 * 1024
+	// 单个站点地图中的最大网址数量。
+	SITEMAP_MAX_URLS = 50000
+	// 站点地图本身的请求的优先级。高于任何网页的优先级，以便尽早展开整个站点地图。
+	SITEMAP_REQUEST_PRIORITY = 10
+)
+
+// XML文档的根元素不是urlset或sitemapindex时产生的错误，如RSS等其他XML文档。
+var ErrNotSitemap = errors.New("The XML document is not a sitemap")
+
+// 各种更新频率对应的优先级加成。
+var changeFreqWeights = map[string]float64{
+	"always":  0.5,
+	"hourly":  0.4,
+	"daily":   0.3,
+	"weekly":  0.2,
+	"monthly": 0.1,
+	"yearly":  0.05,
+	"never":   0,
+}
+
+// 站点地图中被认可的最后修改时间的格式（W3C Datetime）。
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// 计算网址在请求缓存中的优先级。
+// 结果值由站点地图中的优先级、更新频率的加成以及最后修改时间的新近程度加成组成。
+func (su SitemapUrl) Score(now time.Time) float64 {
+	score := su.Priority
+	score += changeFreqWeights[su.ChangeFreq]
+	if !su.LastMod.IsZero() {
+		age := now.Sub(su.LastMod)
+		switch {
+		case age < 24*time.Hour:
+			score += 0.5
+		case age < 7*24*time.Hour:
+			score += 0.3
+		case age < 30*24*time.Hour:
+			score += 0.2
+		case age < 365*24*time.Hour:
+			score += 0.1
+		}
+	}
+	return score
+}
+
+// 站点地图XML文档的结构。根元素可以是urlset或sitemapindex。
+type xmlSitemap struct {
+	XMLName  xml.Name
+	Urls     []xmlSitemapEntry `xml:"url"`
+	Sitemaps []xmlSitemapEntry `xml:"sitemap"`
+}
+
+// 站点地图XML文档中的条目。
+type xmlSitemapEntry struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+// 解析站点地图。支持urlset文件、sitemapindex文件以及经过gzip压缩的文件。
+// 其他的XML文档会得到ErrNotSitemap。
+func ParseSitemap(reader io.Reader) (*Sitemap, error) {
+	bufReader := bufio.NewReader(reader)
+	magic, _ := bufReader.Peek(2)
+	var source io.Reader = bufReader
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzReader, err := gzip.NewReader(bufReader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+		source = gzReader
+	}
+	source = io.LimitReader(source, SITEMAP_MAX_SIZE)
+	var doc xmlSitemap
+	decoder := xml.NewDecoder(source)
+	decoder.Strict = false
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return nil, ErrNotSitemap
+	}
+	sitemap := &Sitemap{
+		Urls:     convertSitemapEntries(doc.Urls),
+		Sitemaps: convertSitemapEntries(doc.Sitemaps),
+	}
+	return sitemap, nil
+}
+
+// 转换站点地图XML文档中的条目。
+func convertSitemapEntries(entries []xmlSitemapEntry) []SitemapUrl {
+	if len(entries) > SITEMAP_MAX_URLS {
+		entries = entries[:SITEMAP_MAX_URLS]
+	}
+	result := make([]SitemapUrl, 0, len(entries))
+	for _, entry := range entries {
+		loc := strings.TrimSpace(entry.Loc)
+		if loc == "" {
+			continue
+		}
+		su := SitemapUrl{
+			Loc:        loc,
+			ChangeFreq: strings.ToLower(strings.TrimSpace(entry.ChangeFreq)),
+			Priority:   0.5,
+		}
+		if p, err := strconv.ParseFloat(strings.TrimSpace(entry.Priority), 64); err == nil &&
+			p >= 0 && p <= 1 {
+			su.Priority = p
+		}
+		lastMod := strings.TrimSpace(entry.LastMod)
+		for _, layout := range lastModLayouts {
+			if t, err := time.Parse(layout, lastMod); err == nil {
+				su.LastMod = t
+				break
+			}
+		}
+		result = append(result, su)
+	}
+	return result
+}
+
+// 从robots.txt中找出所有站点地图的网址。
+func ParseRobotsForSitemaps(reader io.Reader) ([]string, error) {
+	sitemaps := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		index := strings.Index(line, ":")
+		if index < 0 {
+			continue
+		}
+		if strings.ToLower(strings.TrimSpace(line[:index])) != "sitemap" {
+			continue
+		}
+		loc := strings.TrimSpace(line[index+1:])
+		if loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+	return sitemaps, scanner.Err()
+}
+
+// 判断HTTP响应是否可能是站点地图。
+func isSitemapResponse(httpResp *http.Response) bool {
+	path := strings.ToLower(httpResp.Request.URL.Path)
+	if strings.HasSuffix(path, ".xml") || strings.HasSuffix(path, ".xml.gz") ||
+		strings.Contains(path, "sitemap") {
+		return true
+	}
+	contentType := strings.ToLower(httpResp.Header.Get("Content-Type"))
+	return strings.Contains(contentType, "xml") || strings.Contains(contentType, "gzip")
+}
+
+// 响应解析函数。解析robots.txt和站点地图。
+// 对于robots.txt，会为其中声明的每个站点地图生成请求；
+// 对于站点地图索引文件，会为其中的每个子站点地图生成请求；
+// 对于urlset文件，会为其中的每个网址生成请求，并依据lastmod、priority和changefreq设定请求的优先级。
+// 其他的HTTP响应会被忽略。
+func ParseForSitemap(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	reqUrl := httpResp.Request.URL
+	isRobots := strings.ToLower(reqUrl.Path) == "/robots.txt"
+	if !isRobots && !isSitemapResponse(httpResp) {
+		return nil, nil
+	}
+	if httpResp.StatusCode != 200 {
+		// 站点地图不存在是很常见的情况，不应被视为错误。
+		if httpResp.StatusCode == 404 {
+			return nil, nil
+		}
+		err := errors.New(
+			fmt.Sprintf("Unsupported status code %d. (reqUrl=%s)", httpResp.StatusCode, reqUrl))
+		return nil, []error{err}
+	}
+	var httpRespBody io.ReadCloser = httpResp.Body
+	defer func() {
+		if httpRespBody != nil {
+			httpRespBody.Close()
+		}
+	}()
+	dataList := make([]base.Data, 0)
+	errs := make([]error, 0)
+	if isRobots {
+		locs, err := ParseRobotsForSitemaps(httpRespBody)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, loc := range locs {
+			req, err := genSitemapRequest(reqUrl, loc, respDepth, SITEMAP_REQUEST_PRIORITY)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			dataList = append(dataList, req)
+		}
+		return dataList, errs
+	}
+	// 非XML的内容不是站点地图。预先判断以免产生无谓的错误。
+	body, err := ioutil.ReadAll(io.LimitReader(httpRespBody, SITEMAP_MAX_SIZE))
+	if err != nil {
+		return nil, []error{err}
+	}
+	isGzip := len(body) > 0 && body[0] == 0x1f
+	if trimmed := bytes.TrimSpace(body); !isGzip && (len(trimmed) == 0 || trimmed[0] != '<') {
+		return nil, nil
+	}
+	// 站点地图的网址中可能有RSS等其他XML文档，它们同样被忽略。
+	sitemap, err := ParseSitemap(bytes.NewReader(body))
+	if err == ErrNotSitemap {
+		return nil, nil
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid sitemap: %s (reqUrl=%s)", err, reqUrl)
+		return nil, []error{errors.New(errMsg)}
+	}
+	for _, su := range sitemap.Sitemaps {
+		req, err := genSitemapRequest(reqUrl, su.Loc, respDepth, SITEMAP_REQUEST_PRIORITY)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		dataList = append(dataList, req)
+	}
+	now := time.Now()
+	for _, su := range sitemap.Urls {
+		req, err := genSitemapRequest(reqUrl, su.Loc, respDepth, su.Score(now))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		dataList = append(dataList, req)
+	}
+	return dataList, errs
+}
+
+// 生成针对站点地图中的网址的请求。
+func genSitemapRequest(
+	reqUrl *url.URL,
+	loc string,
+	respDepth uint32,
+	priority float64) (*base.Request, error) {
+	locUrl, err := url.Parse(loc)
+	if err != nil {
+		return nil, err
+	}
+	if !locUrl.IsAbs() {
+		locUrl = reqUrl.ResolveReference(locUrl)
+	}
+	httpReq, err := http.NewRequest("GET", locUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return base.NewRequestWithPriority(httpReq, respDepth, priority), nil
+}
@@ -0,0 +1,59 @@
+package analyzer
+
+import (
+	"base"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 把响应体包装为HTTP响应。
+func newTestResponse(t *testing.T, reqUrl string, contentType string, body string) *http.Response {
+	httpReq, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		t.Fatalf("Can not create the request: %s", err)
+	}
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    httpReq,
+	}
+}
+
+func TestParseForSitemap(t *testing.T) {
+	robots := "User-agent: *\nDisallow: /tmp\nSitemap: http://example.com/sitemap_index.xml # index\n"
+	dataList, errs := ParseForSitemap(newTestResponse(t, "http://example.com/robots.txt", "text/plain", robots), 0)
+	if len(errs) != 0 || len(dataList) != 1 {
+		t.Fatalf("Unexpected result of robots.txt: %v, %v", dataList, errs)
+	}
+	if req := dataList[0].(*base.Request); req.HttpReq().URL.String() != "http://example.com/sitemap_index.xml" {
+		t.Errorf("Unexpected sitemap request: %s", req.HttpReq().URL)
+	}
+	urlset := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://example.com/a</loc><priority>0.9</priority></url>
+  <url><loc>/b</loc></url>
+</urlset>`
+	dataList, errs = ParseForSitemap(newTestResponse(t, "http://example.com/sitemap.xml", "application/xml", urlset), 0)
+	if len(errs) != 0 || len(dataList) != 2 {
+		t.Fatalf("Unexpected result of the urlset: %v, %v", dataList, errs)
+	}
+	if req := dataList[1].(*base.Request); req.HttpReq().URL.String() != "http://example.com/b" {
+		t.Errorf("A relative location should be resolved: %s", req.HttpReq().URL)
+	}
+	// 其他的XML文档不是站点地图，也不应产生错误。
+	rss := `<?xml version="1.0"?><rss version="2.0"><channel><title>t</title></channel></rss>`
+	dataList, errs = ParseForSitemap(newTestResponse(t, "http://example.com/feed.xml", "application/rss+xml", rss), 0)
+	if len(dataList) != 0 || len(errs) != 0 {
+		t.Errorf("An RSS document should be ignored: %v, %v", dataList, errs)
+	}
+	if _, err := ParseSitemap(strings.NewReader(rss)); err != ErrNotSitemap {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package base
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // 参数容器的接口。
@@ -91,22 +92,48 @@ func (args *ChannelArgs) ErrorChanLen() uint {
 
 // 池基本参数容器的描述模板。
 var poolBaseArgsTemplate string = "{ pageDownloaderPoolSize: %d," +
-	" analyzerPoolSize: %d }"
+	" analyzerPoolSize: %d, parserTimeout: %s," +
+	" itemWorkerPoolSize: %d, itemQueueLen: %d }"
 
 // 池基本参数的容器。
 type PoolBaseArgs struct {
-	pageDownloaderPoolSize uint32 // 网页下载器池的尺寸。
-	analyzerPoolSize       uint32 // 分析器池的尺寸。
-	description            string // 描述。
+	pageDownloaderPoolSize uint32        // 网页下载器池的尺寸。
+	analyzerPoolSize       uint32        // 分析器池的尺寸。
+	parserTimeout          time.Duration // 单个响应解析函数的执行时限。为0表示使用分析器的默认时限。
+	itemWorkerPoolSize     uint32        // 条目处理管道中工作者的数量。为0表示使用默认数量。
+	itemQueueLen           uint32        // 条目处理管道中等待处理的条目队列的长度。为0表示使用默认长度。
+	description            string        // 描述。
 }
 
 // 创建池基本参数的容器。
 func NewPoolBaseArgs(
 	pageDownloaderPoolSize uint32,
 	analyzerPoolSize uint32) PoolBaseArgs {
+	return NewPoolBaseArgsWithTimeout(pageDownloaderPoolSize, analyzerPoolSize, 0)
+}
+
+// 创建带有响应解析函数执行时限的池基本参数的容器。
+func NewPoolBaseArgsWithTimeout(
+	pageDownloaderPoolSize uint32,
+	analyzerPoolSize uint32,
+	parserTimeout time.Duration) PoolBaseArgs {
+	return NewPoolBaseArgsWithItemWorkers(
+		pageDownloaderPoolSize, analyzerPoolSize, parserTimeout, 0, 0)
+}
+
+// 创建带有条目处理管道的工作者数量和队列长度的池基本参数的容器。
+func NewPoolBaseArgsWithItemWorkers(
+	pageDownloaderPoolSize uint32,
+	analyzerPoolSize uint32,
+	parserTimeout time.Duration,
+	itemWorkerPoolSize uint32,
+	itemQueueLen uint32) PoolBaseArgs {
 	return PoolBaseArgs{
 		pageDownloaderPoolSize: pageDownloaderPoolSize,
 		analyzerPoolSize:       analyzerPoolSize,
+		parserTimeout:          parserTimeout,
+		itemWorkerPoolSize:     itemWorkerPoolSize,
+		itemQueueLen:           itemQueueLen,
 	}
 }
 
@@ -117,6 +144,9 @@ func (args *PoolBaseArgs) Check() error {
 	if args.analyzerPoolSize == 0 {
 		return errors.New("The analyzer pool size can not be 0!\n")
 	}
+	if args.parserTimeout < 0 {
+		return errors.New("The parser timeout can not be negative!\n")
+	}
 	return nil
 }
 
@@ -125,7 +155,10 @@ func (args *PoolBaseArgs) String() string {
 		args.description =
 			fmt.Sprintf(poolBaseArgsTemplate,
 				args.pageDownloaderPoolSize,
-				args.analyzerPoolSize)
+				args.analyzerPoolSize,
+				args.parserTimeout,
+				args.itemWorkerPoolSize,
+				args.itemQueueLen)
 	}
 	return args.description
 }
@@ -139,3 +172,18 @@ func (args *PoolBaseArgs) PageDownloaderPoolSize() uint32 {
 func (args *PoolBaseArgs) AnalyzerPoolSize() uint32 {
 	return args.analyzerPoolSize
 }
+
+// 获得单个响应解析函数的执行时限。
+func (args *PoolBaseArgs) ParserTimeout() time.Duration {
+	return args.parserTimeout
+}
+
+// 获得条目处理管道中工作者的数量。
+func (args *PoolBaseArgs) ItemWorkerPoolSize() uint32 {
+	return args.itemWorkerPoolSize
+}
+
+// 获得条目处理管道中等待处理的条目队列的长度。
+func (args *PoolBaseArgs) ItemQueueLen() uint32 {
+	return args.itemQueueLen
+}
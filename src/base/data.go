@@ -1,13 +1,19 @@
 package base
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 )
 
 //请求
 type Request struct {
-	httpReq *http.Request //http请求
-	depth   uint32        //请求的深度
+	httpReq  *http.Request //http请求
+	depth    uint32        //请求的深度
+	priority float64       //请求的优先级，值越大越先被调度
 }
 
 //初始化Request结构
@@ -15,6 +21,25 @@ func NewRequest(httpReq *http.Request, depth uint32) *Request {
 	return &Request{httpReq: httpReq, depth: depth}
 }
 
+//初始化带有优先级的Request结构
+func NewRequestWithPriority(httpReq *http.Request, depth uint32, priority float64) *Request {
+	return &Request{httpReq: httpReq, depth: depth, priority: priority}
+}
+
+//初始化带有JSON请求体的Request结构，请求方法通常为POST
+func NewJsonRequest(method string, rawUrl string, payload interface{}, depth uint32) (*Request, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(method, rawUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return NewRequest(httpReq, depth), nil
+}
+
 //获取http请求
 func (req *Request) HttpReq() *http.Request {
 	return req.httpReq
@@ -25,6 +50,40 @@ func (req *Request) Depth() uint32 {
 	return req.depth
 }
 
+//获取请求优先级
+func (req *Request) Priority() float64 {
+	return req.priority
+}
+
+//获取请求体的副本，请求没有可重复读取的请求体时返回nil
+func (req *Request) Body() []byte {
+	if req.httpReq == nil || req.httpReq.GetBody == nil {
+		return nil
+	}
+	reader, err := req.httpReq.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+//获取用于判断请求是否重复的键
+//GET请求的键就是其url，其他请求的键还包含请求方法和请求体的摘要
+func (req *Request) Key() string {
+	urlStr := req.httpReq.URL.String()
+	method := req.httpReq.Method
+	if method == "" || method == "GET" {
+		return urlStr
+	}
+	digest := sha1.Sum(req.Body())
+	return method + " " + urlStr + " " + hex.EncodeToString(digest[:])
+}
+
 //响应
 type Response struct {
 	httpResp *http.Response
@@ -49,6 +108,18 @@ func (resp *Response) Depth() uint32 {
 //条目
 type Item map[string]interface{}
 
+//条目中存放条目类型（如"link"、"feed"）的字段的名称
+const ITEM_TYPE_FIELD = "item.type"
+
+//获得条目的类型。未标记类型时结果值为空字符串
+func (item *Item) Type() string {
+	if item == nil {
+		return ""
+	}
+	itemType, _ := (*item)[ITEM_TYPE_FIELD].(string)
+	return itemType
+}
+
 //数据接口
 type Data interface {
 	Valid() bool //数据是否有效
@@ -54,3 +54,92 @@ func (ce *myCrawlerError) genFullErrMsg() {
 	ce.fullErrMsg = fmt.Sprintf("%s\n", buffer.String())
 	return
 }
+
+//分析器错误的接口类型，携带出错的响应解析函数的序号（或路由规则的名称）和请求的网址
+type AnalyzerError interface {
+	CrawlerError
+	ParserIndex() int //获得响应解析函数的序号。由路由器分派的响应解析函数出错时为-1
+	Route() string    //获得路由规则的名称。不经路由器的响应解析函数出错时为空
+	ReqUrl() string   //获得请求的网址
+}
+
+type myAnalyzerError struct {
+	myCrawlerError
+	parserIndex int    //响应解析函数的序号
+	route       string //路由规则的名称
+	reqUrl      string //请求的网址
+}
+
+//初始化
+func NewAnalyzerError(parserIndex int, reqUrl string, errMsg string) AnalyzerError {
+	fullMsg := fmt.Sprintf("%s (parserIndex=%d, reqUrl=%s)", errMsg, parserIndex, reqUrl)
+	return &myAnalyzerError{
+		myCrawlerError: myCrawlerError{errType: ANALYZER_ERROR, errMsg: fullMsg},
+		parserIndex:    parserIndex,
+		reqUrl:         reqUrl,
+	}
+}
+
+//初始化由路由器分派的响应解析函数的分析器错误
+func NewRouteAnalyzerError(route string, reqUrl string, errMsg string) AnalyzerError {
+	fullMsg := fmt.Sprintf("%s (route=%s, reqUrl=%s)", errMsg, route, reqUrl)
+	return &myAnalyzerError{
+		myCrawlerError: myCrawlerError{errType: ANALYZER_ERROR, errMsg: fullMsg},
+		parserIndex:    -1,
+		route:          route,
+		reqUrl:         reqUrl,
+	}
+}
+
+//获得响应解析函数的序号
+func (ae *myAnalyzerError) ParserIndex() int {
+	return ae.parserIndex
+}
+
+//获得路由规则的名称
+func (ae *myAnalyzerError) Route() string {
+	return ae.route
+}
+
+//获得请求的网址
+func (ae *myAnalyzerError) ReqUrl() string {
+	return ae.reqUrl
+}
+
+//字段校验失败的原因
+type FieldViolation struct {
+	Field  string //字段名称
+	Reason string //失败的原因
+}
+
+//条目校验错误的接口类型，携带各个字段校验失败的原因
+type ItemValidationError interface {
+	CrawlerError
+	Violations() []FieldViolation //获得字段校验失败的原因
+}
+
+type myItemValidationError struct {
+	myCrawlerError
+	violations []FieldViolation //字段校验失败的原因
+}
+
+//初始化
+func NewItemValidationError(violations []FieldViolation) ItemValidationError {
+	var buffer bytes.Buffer
+	buffer.WriteString("Invalid item:")
+	for i, violation := range violations {
+		if i > 0 {
+			buffer.WriteString(";")
+		}
+		buffer.WriteString(fmt.Sprintf(" field %q %s", violation.Field, violation.Reason))
+	}
+	return &myItemValidationError{
+		myCrawlerError: myCrawlerError{errType: ITEM_PROCESSOR_ERROR, errMsg: buffer.String()},
+		violations:     violations,
+	}
+}
+
+//获得字段校验失败的原因
+func (ve *myItemValidationError) Violations() []FieldViolation {
+	return ve.violations
+}
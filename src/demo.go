@@ -2,17 +2,13 @@ package main
 
 import (
 	"analyzer"
+	"analyzer/parsers"
 	base "base"
 	"errors"
-	"fmt"
-	"github.com/PuerkitoBio/goquery"
-	"io"
 	pipeline "itempipeline"
 	"logging"
 	"net/http"
-	"net/url"
 	sched "scheduler"
-	"strings"
 	"time"
 )
 
@@ -36,75 +32,16 @@ func processItem(item base.Item) (result base.Item, err error) {
 	return result, nil
 }
 
-// 响应解析函数。只解析“A”标签。
-func parseForATag(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
-	// TODO 支持更多的HTTP响应状态
-	if httpResp.StatusCode != 200 {
-		err := errors.New(
-			fmt.Sprintf("Unsupported status code %d. (httpResponse=%v)", httpResp))
-		return nil, []error{err}
-	}
-	var reqUrl *url.URL = httpResp.Request.URL
-	var httpRespBody io.ReadCloser = httpResp.Body
-	defer func() {
-		if httpRespBody != nil {
-			httpRespBody.Close()
-		}
-	}()
-	dataList := make([]base.Data, 0)
-	errs := make([]error, 0)
-	// 开始解析
-	doc, err := goquery.NewDocumentFromReader(httpRespBody)
-	if err != nil {
-		errs = append(errs, err)
-		return dataList, errs
-	}
-	// 查找“A”标签并提取链接地址
-	doc.Find("a").Each(func(index int, sel *goquery.Selection) {
-		href, exists := sel.Attr("href")
-		// 前期过滤
-		if !exists || href == "" || href == "#" || href == "/" {
-			return
-		}
-		href = strings.TrimSpace(href)
-		lowerHref := strings.ToLower(href)
-		// 暂不支持对Javascript代码的解析。
-		if href != "" && !strings.HasPrefix(lowerHref, "javascript") {
-			aUrl, err := url.Parse(href)
-			if err != nil {
-				errs = append(errs, err)
-				return
-			}
-			if !aUrl.IsAbs() {
-				aUrl = reqUrl.ResolveReference(aUrl)
-			}
-			httpReq, err := http.NewRequest("GET", aUrl.String(), nil)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				req := base.NewRequest(httpReq, respDepth)
-				dataList = append(dataList, req)
-			}
-		}
-		text := strings.TrimSpace(sel.Text())
-		if text != "" {
-			imap := make(map[string]interface{})
-			imap["parent_url"] = reqUrl
-			imap["a.text"] = text
-			imap["a.index"] = index
-			item := base.Item(imap)
-			dataList = append(dataList, &item)
-		}
-	})
-	return dataList, errs
-}
-
 // 获得响应解析函数的序列。
 func getResponseParsers() []analyzer.ParseResponse {
-	parsers := []analyzer.ParseResponse{
-		parseForATag,
+	linkParser, err := parsers.NewLinkParser(parsers.DefaultLinkArgs())
+	if err != nil {
+		panic(err)
+	}
+	respParsers := []analyzer.ParseResponse{
+		linkParser,
 	}
-	return parsers
+	return respParsers
 }
 
 // 获得条目处理器的序列。
@@ -170,7 +107,7 @@ func main() {
 		crawlDepth,
 		httpClientGenerator,
 		respParsers,
-		itemProcessors,
+		pipeline.LinearFlow(itemProcessors...),
 		firstHttpReq)
 
 	// 等待监控结束
@@ -0,0 +1,257 @@
+package itempipeline
+
+import (
+	"base"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 被用来批量处理条目的函数类型。
+// 结果值err不为nil表示整个批次处理失败，此时批次中的每个条目都会得到该错误。
+// 否则结果值errs要么为nil（全部成功），要么与参数items一一对应，其中为nil的元素表示对应的条目处理成功。
+type BatchProcessItem func(items []base.Item) (errs []error, err error)
+
+// 与批次中的单个条目对应的错误。
+type BatchItemError struct {
+	Batch uint64    // 批次的序号，从1开始。
+	Index int       // 条目在批次中的位置。
+	Item  base.Item // 条目。
+	Err   error     // 错误。
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("Batch %d item %d: %s", e.Batch, e.Index, e.Err)
+}
+
+// 可以异步地报告错误的组件。
+// 条目处理管道被创建时，会为需要关闭的组件中实现了该接口的组件设置错误处理函数。
+type ErrorReporter interface {
+	SetErrorHandler(errorHandler func(err error))
+}
+
+// 批量处理器的参数容器的描述模板。
+var batchArgsTemplate string = "{ size: %d, interval: %s }"
+
+// 批量处理器的参数容器。
+type BatchArgs struct {
+	Size     int           // 批次的最大条目数量。达到后立即处理。必须大于0。
+	Interval time.Duration // 条目在批次中等待的最长时间。超过后即使批次未满也会被处理。为0表示不限制。
+}
+
+func (args *BatchArgs) Check() error {
+	if args.Size <= 0 {
+		return errors.New("The batch size must be positive!\n")
+	}
+	if args.Interval < 0 {
+		return errors.New("The batch interval can not be negative!\n")
+	}
+	return nil
+}
+
+func (args *BatchArgs) String() string {
+	return fmt.Sprintf(batchArgsTemplate, args.Size, args.Interval)
+}
+
+// 批量处理器的接口类型。它会把逐个到达的条目攒成批次，再交给批量处理函数。
+// 批次在条目数量达到上限、等待时间达到上限、调用Flush或Close时被处理。
+// 处理结果通过错误处理函数以*BatchItemError的形式报告，因此应把它注册为条目处理管道中需要关闭的组件，
+// 以便失败的条目被存入管道的死信存储器、错误被转交给工作者的错误处理函数，并在调度器停止时处理剩余的条目。
+type BatchProcessor interface {
+	// 把条目放入当前批次。结果值中的条目即为参数中的条目，因此该方法可被直接用作条目处理器。
+	// 若条目使批次已满，则该方法会在处理完该批次后才返回，从而对上游施加背压。
+	Process(item base.Item) (result base.Item, err error)
+	// 立即处理当前批次。结果值为该批次中各个条目的错误。
+	Flush() []error
+	// 处理当前批次并关闭。关闭后的批量处理器不再接受条目。
+	Close() error
+	// 设置错误处理函数。
+	SetErrorHandler(errorHandler func(err error))
+	// 获得已处理的批次、已处理的条目以及处理失败的条目的计数值。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建批量处理器。
+func NewBatchProcessor(processor BatchProcessItem, args BatchArgs) (BatchProcessor, error) {
+	if processor == nil {
+		return nil, errors.New("Invalid batch item processor!\n")
+	}
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	return &myBatchProcessor{processor: processor, args: args}, nil
+}
+
+// 批量处理器的实现类型。
+type myBatchProcessor struct {
+	processor    BatchProcessItem // 批量处理函数。
+	args         BatchArgs        // 参数。
+	pending      []base.Item      // 当前批次中的条目。
+	generation   uint64           // 当前批次的代数，用于识别过期的定时器。
+	timer        *time.Timer      // 当前批次的定时器。
+	closed       bool             // 是否已被关闭。
+	mutex        sync.Mutex       // 针对当前批次的互斥锁。
+	flushMutex   sync.Mutex       // 保证批次被依次处理的互斥锁。
+	errorHandler func(err error)  // 错误处理函数。
+	handlerMutex sync.RWMutex     // 针对错误处理函数的读写锁。
+	batches      uint64           // 已处理的批次的数量。
+	items        uint64           // 已处理的条目的数量。
+	failed       uint64           // 处理失败的条目的数量。
+}
+
+func (bp *myBatchProcessor) Process(item base.Item) (result base.Item, err error) {
+	if item == nil {
+		return nil, errors.New("The item is invalid!\n")
+	}
+	bp.mutex.Lock()
+	if bp.closed {
+		bp.mutex.Unlock()
+		return nil, errors.New("The batch processor has been closed!\n")
+	}
+	bp.pending = append(bp.pending, item)
+	if len(bp.pending) == 1 && bp.args.Interval > 0 {
+		generation := bp.generation
+		bp.timer = time.AfterFunc(bp.args.Interval, func() {
+			bp.flushGeneration(generation)
+		})
+	}
+	var batch []base.Item
+	if len(bp.pending) >= bp.args.Size {
+		batch = bp.take()
+	}
+	bp.mutex.Unlock()
+	if batch != nil {
+		bp.process(batch)
+	}
+	return item, nil
+}
+
+// 取出当前批次。调用方需持有互斥锁。
+func (bp *myBatchProcessor) take() []base.Item {
+	batch := bp.pending
+	bp.pending = nil
+	bp.generation++
+	if bp.timer != nil {
+		bp.timer.Stop()
+		bp.timer = nil
+	}
+	return batch
+}
+
+// 由定时器调用，处理指定代数的批次。若该批次已被处理，则什么也不做。
+func (bp *myBatchProcessor) flushGeneration(generation uint64) {
+	bp.mutex.Lock()
+	if bp.generation != generation || len(bp.pending) == 0 {
+		bp.mutex.Unlock()
+		return
+	}
+	batch := bp.take()
+	bp.mutex.Unlock()
+	bp.process(batch)
+}
+
+// 处理批次，并把错误映射到各个条目上。批次会被依次处理。
+func (bp *myBatchProcessor) process(batch []base.Item) []error {
+	bp.flushMutex.Lock()
+	defer bp.flushMutex.Unlock()
+	sequence := atomic.AddUint64(&bp.batches, 1)
+	itemErrs, batchErr := bp.safeProcess(batch)
+	if batchErr == nil && itemErrs != nil && len(itemErrs) != len(batch) {
+		batchErr = errors.New(fmt.Sprintf("The batch item processor returned %d errors for %d items",
+			len(itemErrs), len(batch)))
+	}
+	errs := make([]error, 0)
+	for i, item := range batch {
+		err := batchErr
+		if err == nil && itemErrs != nil {
+			err = itemErrs[i]
+		}
+		if err != nil {
+			errs = append(errs, &BatchItemError{Batch: sequence, Index: i, Item: item, Err: err})
+		}
+	}
+	atomic.AddUint64(&bp.items, uint64(len(batch)))
+	atomic.AddUint64(&bp.failed, uint64(len(errs)))
+	bp.handlerMutex.RLock()
+	errorHandler := bp.errorHandler
+	bp.handlerMutex.RUnlock()
+	if errorHandler != nil {
+		for _, err := range errs {
+			errorHandler(err)
+		}
+	}
+	return errs
+}
+
+// 调用批量处理函数，并把其引发的运行时恐慌转换为整个批次的错误。
+func (bp *myBatchProcessor) safeProcess(batch []base.Item) (itemErrs []error, batchErr error) {
+	defer func() {
+		if p := recover(); p != nil {
+			itemErrs = nil
+			batchErr = errors.New(fmt.Sprintf("Fatal batch processing error: %s", p))
+		}
+	}()
+	return bp.processor(batch)
+}
+
+func (bp *myBatchProcessor) Flush() []error {
+	bp.mutex.Lock()
+	batch := bp.take()
+	bp.mutex.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return bp.process(batch)
+}
+
+func (bp *myBatchProcessor) Close() error {
+	bp.mutex.Lock()
+	if bp.closed {
+		bp.mutex.Unlock()
+		return nil
+	}
+	bp.closed = true
+	batch := bp.take()
+	bp.mutex.Unlock()
+	if len(batch) == 0 {
+		// 等待正在被处理的批次。
+		bp.flushMutex.Lock()
+		bp.flushMutex.Unlock()
+		return nil
+	}
+	if errs := bp.process(batch); len(errs) > 0 {
+		return errors.New(fmt.Sprintf("%d of %d items in the last batch failed, the first error: %s",
+			len(errs), len(batch), errs[0]))
+	}
+	return nil
+}
+
+func (bp *myBatchProcessor) SetErrorHandler(errorHandler func(err error)) {
+	bp.handlerMutex.Lock()
+	defer bp.handlerMutex.Unlock()
+	bp.errorHandler = errorHandler
+}
+
+func (bp *myBatchProcessor) Count() []uint64 {
+	counts := make([]uint64, 3)
+	counts[0] = atomic.LoadUint64(&bp.batches)
+	counts[1] = atomic.LoadUint64(&bp.items)
+	counts[2] = atomic.LoadUint64(&bp.failed)
+	return counts
+}
+
+var batchSummaryTemplate = "size: %d, interval: %s, closed: %v, pending: %d," +
+	" batches: %d, items: %d, failed: %d"
+
+func (bp *myBatchProcessor) Summary() string {
+	counts := bp.Count()
+	bp.mutex.Lock()
+	defer bp.mutex.Unlock()
+	return fmt.Sprintf(batchSummaryTemplate,
+		bp.args.Size, bp.args.Interval, bp.closed, len(bp.pending),
+		counts[0], counts[1], counts[2])
+}
@@ -0,0 +1,221 @@
+package itempipeline
+
+import (
+	"base"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 内存中的死信存储器，仅供测试使用。
+type memoryDeadLetters struct {
+	letters []DeadLetter
+	closed  bool
+	mutex   sync.Mutex
+}
+
+func (store *memoryDeadLetters) Put(letter DeadLetter) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.letters = append(store.letters, letter)
+	return nil
+}
+
+func (store *memoryDeadLetters) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.closed = true
+	return nil
+}
+
+func (store *memoryDeadLetters) Count() uint64 {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return uint64(len(store.letters))
+}
+
+func (store *memoryDeadLetters) Summary() string {
+	return ""
+}
+
+func (store *memoryDeadLetters) all() []DeadLetter {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return append([]DeadLetter(nil), store.letters...)
+}
+
+// 记录各个批次的批量处理函数。
+type batchRecorder struct {
+	batches [][]base.Item
+	mutex   sync.Mutex
+}
+
+func (r *batchRecorder) process(items []base.Item) ([]error, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.batches = append(r.batches, items)
+	return nil, nil
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	sizes := make([]int, len(r.batches))
+	for i, batch := range r.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+func TestBatchProcessorSize(t *testing.T) {
+	recorder := &batchRecorder{}
+	bp, err := NewBatchProcessor(recorder.process, BatchArgs{Size: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for i := 0; i < 7; i++ {
+		if _, err := bp.Process(base.Item{"i": i}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if sizes := recorder.sizes(); len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 3 {
+		t.Errorf("Unexpected batches before closing: %v", sizes)
+	}
+	if err := bp.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if sizes := recorder.sizes(); len(sizes) != 3 || sizes[2] != 1 {
+		t.Errorf("Unexpected batches after closing: %v", sizes)
+	}
+	if _, err := bp.Process(base.Item{}); err == nil {
+		t.Errorf("A closed batch processor should reject items")
+	}
+	if counts := bp.Count(); counts[0] != 3 || counts[1] != 7 || counts[2] != 0 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+func TestBatchProcessorInterval(t *testing.T) {
+	recorder := &batchRecorder{}
+	bp, _ := NewBatchProcessor(recorder.process, BatchArgs{Size: 100, Interval: 20 * time.Millisecond})
+	bp.Process(base.Item{"i": 1})
+	bp.Process(base.Item{"i": 2})
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sizes := recorder.sizes(); len(sizes) != 1 || sizes[0] != 2 {
+		t.Errorf("The batch is not flushed by the interval: %v", sizes)
+	}
+	bp.Close()
+}
+
+func TestBatchProcessorErrors(t *testing.T) {
+	failOdd := func(items []base.Item) ([]error, error) {
+		errs := make([]error, len(items))
+		for i, item := range items {
+			if item["i"].(int)%2 == 1 {
+				errs[i] = errors.New("odd")
+			}
+		}
+		return errs, nil
+	}
+	bp, _ := NewBatchProcessor(failOdd, BatchArgs{Size: 10})
+	var reported []error
+	var mutex sync.Mutex
+	bp.SetErrorHandler(func(err error) {
+		mutex.Lock()
+		reported = append(reported, err)
+		mutex.Unlock()
+	})
+	for i := 0; i < 4; i++ {
+		bp.Process(base.Item{"i": i})
+	}
+	errs := bp.Flush()
+	if len(errs) != 2 || len(reported) != 2 {
+		t.Fatalf("Unexpected errors: %v (reported: %v)", errs, reported)
+	}
+	itemErr, ok := errs[1].(*BatchItemError)
+	if !ok || itemErr.Batch != 1 || itemErr.Index != 3 || itemErr.Item["i"] != 3 {
+		t.Errorf("Unexpected item error: %#v", errs[1])
+	}
+
+	failAll := func(items []base.Item) ([]error, error) {
+		return nil, errors.New("unavailable")
+	}
+	bp, _ = NewBatchProcessor(failAll, BatchArgs{Size: 10})
+	bp.Process(base.Item{"i": 0})
+	bp.Process(base.Item{"i": 1})
+	if err := bp.Close(); err == nil {
+		t.Errorf("The failure of the last batch should be returned by Close")
+	}
+	if counts := bp.Count(); counts[2] != 2 {
+		t.Errorf("Every item of a failed batch should fail: %v", counts)
+	}
+
+	panicking := func(items []base.Item) ([]error, error) {
+		panic("boom")
+	}
+	bp, _ = NewBatchProcessor(panicking, BatchArgs{Size: 1})
+	bp.SetErrorHandler(func(err error) {})
+	if _, err := bp.Process(base.Item{}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if counts := bp.Count(); counts[2] != 1 {
+		t.Errorf("A panic should fail the batch: %v", counts)
+	}
+}
+
+func TestBatchFailuresAreDeadLettered(t *testing.T) {
+	failing := func(items []base.Item) ([]error, error) {
+		errs := make([]error, len(items))
+		for i, item := range items {
+			if item["bad"] == true {
+				errs[i] = errors.New("rejected by the sink")
+			}
+		}
+		return errs, nil
+	}
+	bp, _ := NewBatchProcessor(failing, BatchArgs{Size: 10})
+	mark := func(item base.Item) (base.Item, error) {
+		item["processed"] = true
+		return item, nil
+	}
+	pipeline := NewItempipelineWithClosers([]ProcessItem{mark, bp.Process}, []Closer{bp})
+	store := &memoryDeadLetters{}
+	pipeline.SetDeadLetterStore(store)
+	var reported []error
+	var mutex sync.Mutex
+	if err := pipeline.StartWorkers(2, 4, func(err error) {
+		mutex.Lock()
+		reported = append(reported, err)
+		mutex.Unlock()
+	}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	pipeline.Submit(base.Item{"id": 1})
+	pipeline.Submit(base.Item{"id": 2, "bad": true})
+	pipeline.Submit(base.Item{"id": 3, "bad": true})
+	if errs := pipeline.Close(); len(errs) != 1 {
+		t.Errorf("Unexpected close errors: %v", errs)
+	}
+	letters := store.all()
+	if len(letters) != 2 {
+		t.Fatalf("Unexpected dead letters: %v", letters)
+	}
+	for _, letter := range letters {
+		if letter.Stage != CLOSER_STAGE || letter.Item["bad"] != true ||
+			letter.Item["processed"] != true || letter.Error != "rejected by the sink" {
+			t.Errorf("Unexpected dead letter: %#v", letter)
+		}
+	}
+	if !store.closed {
+		t.Errorf("The dead letter store should be closed with the pipeline")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(reported) != 2 {
+		t.Errorf("The batch errors should still reach the error handler: %v", reported)
+	}
+}
@@ -0,0 +1,350 @@
+package itempipeline
+
+import (
+	"base"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// 存放各个表的结构的桶的名称。
+const BOLT_SCHEMA_BUCKET = "_schema"
+
+// 条目的结构，即嵌入式数据库中的一张表。
+type ItemSchema struct {
+	// 表名，即存放条目的桶的名称。
+	Name string
+	// 列。为空表示保存条目中的所有字段。不为空时只保存其中的字段，且值会被转换为列的类型。
+	Columns []Column
+	// 主键字段。键相同的条目会被覆盖（upsert）。为空表示使用自增序号作为键，即只插入。
+	Keys []string
+}
+
+func (schema *ItemSchema) Check() error {
+	if schema.Name == "" || schema.Name == BOLT_SCHEMA_BUCKET {
+		return errors.New(fmt.Sprintf("Invalid table name %q!\n", schema.Name))
+	}
+	if err := checkColumns(schema.Columns); err != nil {
+		return err
+	}
+	if len(schema.Columns) > 0 {
+		names := make(map[string]bool)
+		for _, column := range schema.Columns {
+			names[column.Name] = true
+		}
+		for _, key := range schema.Keys {
+			if !names[key] {
+				return errors.New(fmt.Sprintf("The key field %q is not a column!\n", key))
+			}
+		}
+	}
+	return nil
+}
+
+// 嵌入式数据库存储器的参数容器的描述模板。
+var boltArgsTemplate string = "{ path: %s, table: %s, keys: %v, columns: %v," +
+	" batchSize: %d, batchInterval: %s }"
+
+// 嵌入式数据库存储器的参数容器。
+type BoltArgs struct {
+	// 数据库文件的路径。
+	Path string
+	// 条目的结构。
+	Schema ItemSchema
+	// 每个事务最多写入的条目数量。必须大于0。
+	BatchSize int
+	// 条目在内存中等待写入的最长时间。超过后即使批次未满也会提交事务。为0表示不限制。
+	BatchInterval time.Duration
+	// 打开数据库时等待文件锁的最长时间。为0表示一直等待。
+	OpenTimeout time.Duration
+}
+
+// 获得默认的嵌入式数据库存储器的参数容器。
+func DefaultBoltArgs(path string, schema ItemSchema) BoltArgs {
+	return BoltArgs{
+		Path:          path,
+		Schema:        schema,
+		BatchSize:     100,
+		BatchInterval: time.Second,
+		OpenTimeout:   5 * time.Second,
+	}
+}
+
+func (args *BoltArgs) Check() error {
+	if args.Path == "" {
+		return errors.New("The database path can not be empty!\n")
+	}
+	if err := args.Schema.Check(); err != nil {
+		return err
+	}
+	if args.BatchSize <= 0 {
+		return errors.New("The batch size must be positive!\n")
+	}
+	if args.BatchInterval < 0 {
+		return errors.New("The batch interval can not be negative!\n")
+	}
+	if args.OpenTimeout < 0 {
+		return errors.New("The open timeout can not be negative!\n")
+	}
+	return nil
+}
+
+func (args *BoltArgs) String() string {
+	return fmt.Sprintf(boltArgsTemplate,
+		args.Path, args.Schema.Name, args.Schema.Keys, args.Schema.Columns,
+		args.BatchSize, args.BatchInterval)
+}
+
+// 等待写入的记录。
+type boltRecord struct {
+	key   []byte // 键。为nil表示使用自增序号。
+	value []byte // JSON格式的值。
+}
+
+// 嵌入式数据库存储器的实现类型。
+type myBoltSink struct {
+	args     BoltArgs       // 参数。
+	db       *bbolt.DB      // 数据库。
+	batch    BatchProcessor // 把条目攒成事务的批量处理器。
+	written  uint64         // 已写入的条目的数量。
+	failed   uint64         // 写入失败的条目的数量。
+	commits  uint64         // 已提交的事务的数量。
+	inserted uint64         // 新插入的条目的数量。
+	updated  uint64         // 覆盖了已有条目的条目的数量。
+}
+
+// 嵌入式数据库存储器的接口类型。
+// 条目会被攒成批次并在一个事务中写入。因此条目被放入批次后Process即返回，
+// 之后提交事务失败时，批次中的每个条目都会以*BatchItemError的形式被报告给错误处理函数。
+// 应把它注册为条目处理管道中需要关闭的组件，以便失败的条目被存入管道的死信存储器。
+type BoltSink interface {
+	ItemSink
+	ErrorReporter
+}
+
+// 创建嵌入式数据库存储器。条目会被写入bbolt数据库中与表同名的桶，值为JSON格式。
+// 桶会在必要时被自动创建，表的结构会被记录在名为BOLT_SCHEMA_BUCKET的桶中。
+// 若数据库中已有的表的主键与参数中的不同，则会返回错误；新的列会被并入已记录的结构。
+func NewBoltSink(args BoltArgs) (BoltSink, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(args.Path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(args.Path, 0644, &bbolt.Options{Timeout: args.OpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		return createTable(tx, args.Schema)
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	sink := &myBoltSink{args: args, db: db}
+	batch, err := NewBatchProcessor(sink.commit, BatchArgs{Size: args.BatchSize, Interval: args.BatchInterval})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	sink.batch = batch
+	return sink, nil
+}
+
+// 创建表并记录或合并其结构。
+func createTable(tx *bbolt.Tx, schema ItemSchema) error {
+	schemas, err := tx.CreateBucketIfNotExists([]byte(BOLT_SCHEMA_BUCKET))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.CreateBucketIfNotExists([]byte(schema.Name)); err != nil {
+		return err
+	}
+	if data := schemas.Get([]byte(schema.Name)); data != nil {
+		var stored ItemSchema
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+		if fmt.Sprint(stored.Keys) != fmt.Sprint(schema.Keys) {
+			return errors.New(fmt.Sprintf("The keys %v of table %q are different from the stored keys %v!\n",
+				schema.Keys, schema.Name, stored.Keys))
+		}
+		names := make(map[string]bool)
+		for _, column := range schema.Columns {
+			names[column.Name] = true
+		}
+		for _, column := range stored.Columns {
+			if !names[column.Name] {
+				schema.Columns = append(schema.Columns, column)
+			}
+		}
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	return schemas.Put([]byte(schema.Name), data)
+}
+
+func (sink *myBoltSink) Process(item base.Item) (result base.Item, err error) {
+	// 先生成一次记录，以便无效的条目被立即拒绝。
+	if _, err := sink.record(item); err != nil {
+		atomic.AddUint64(&sink.failed, 1)
+		return nil, err
+	}
+	if _, err := sink.batch.Process(item); err != nil {
+		atomic.AddUint64(&sink.failed, 1)
+		return nil, err
+	}
+	return item, nil
+}
+
+// 生成条目对应的记录。
+func (sink *myBoltSink) record(item base.Item) (boltRecord, error) {
+	var record boltRecord
+	if item == nil {
+		return record, errors.New("The item is invalid!\n")
+	}
+	row := make(map[string]interface{})
+	if len(sink.args.Schema.Columns) == 0 {
+		for name, value := range item {
+			row[name] = value
+		}
+	} else {
+		for _, column := range sink.args.Schema.Columns {
+			value, err := convertValue(item[column.Name], columnType(column))
+			if err != nil {
+				return record, errors.New(fmt.Sprintf("Invalid value of column %q: %s", column.Name, err))
+			}
+			if value != nil {
+				row[column.Name] = value
+			}
+		}
+	}
+	if len(sink.args.Schema.Keys) > 0 {
+		var key bytes.Buffer
+		for i, name := range sink.args.Schema.Keys {
+			value, ok := row[name]
+			if !ok || value == nil {
+				return record, errors.New(fmt.Sprintf("The key field %q is missing!", name))
+			}
+			text, err := formatValue(value)
+			if err != nil {
+				return record, err
+			}
+			if i > 0 {
+				key.WriteByte(0)
+			}
+			key.WriteString(text)
+		}
+		record.key = key.Bytes()
+	}
+	value, err := json.Marshal(row)
+	if err != nil {
+		return record, err
+	}
+	record.value = value
+	return record, nil
+}
+
+// 在一个事务中写入批次中的条目。事务失败时这些条目都会被计为写入失败。
+func (sink *myBoltSink) commit(items []base.Item) (errs []error, err error) {
+	records := make([]boltRecord, 0, len(items))
+	for i, item := range items {
+		record, err := sink.record(item)
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(items))
+			}
+			errs[i] = err
+			atomic.AddUint64(&sink.failed, 1)
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return errs, nil
+	}
+	var inserted, updated uint64
+	err = sink.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(sink.args.Schema.Name))
+		if bucket == nil {
+			return errors.New(fmt.Sprintf("The table %q does not exist!\n", sink.args.Schema.Name))
+		}
+		for _, record := range records {
+			key := record.key
+			if key == nil {
+				sequence, err := bucket.NextSequence()
+				if err != nil {
+					return err
+				}
+				key = make([]byte, 8)
+				binary.BigEndian.PutUint64(key, sequence)
+			}
+			if bucket.Get(key) == nil {
+				inserted++
+			} else {
+				updated++
+			}
+			if err := bucket.Put(key, record.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		atomic.AddUint64(&sink.failed, uint64(len(records)))
+		return nil, err
+	}
+	atomic.AddUint64(&sink.written, uint64(len(records)))
+	atomic.AddUint64(&sink.commits, 1)
+	atomic.AddUint64(&sink.inserted, inserted)
+	atomic.AddUint64(&sink.updated, updated)
+	return errs, nil
+}
+
+func (sink *myBoltSink) Flush() error {
+	if errs := sink.batch.Flush(); len(errs) > 0 {
+		return errors.New(fmt.Sprintf("%d items failed to be written, the first error: %s", len(errs), errs[0]))
+	}
+	return nil
+}
+
+func (sink *myBoltSink) Close() error {
+	err := sink.batch.Close()
+	if closeErr := sink.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (sink *myBoltSink) SetErrorHandler(errorHandler func(err error)) {
+	sink.batch.SetErrorHandler(errorHandler)
+}
+
+func (sink *myBoltSink) Count() []uint64 {
+	counts := make([]uint64, 3)
+	counts[0] = atomic.LoadUint64(&sink.written)
+	counts[1] = atomic.LoadUint64(&sink.failed)
+	counts[2] = atomic.LoadUint64(&sink.commits)
+	return counts
+}
+
+var boltSummaryTemplate = "path: %s, table: %s, written: %d, failed: %d, commits: %d," +
+	" inserted: %d, updated: %d, batch: { %s }"
+
+func (sink *myBoltSink) Summary() string {
+	counts := sink.Count()
+	return fmt.Sprintf(boltSummaryTemplate,
+		sink.args.Path, sink.args.Schema.Name, counts[0], counts[1], counts[2],
+		atomic.LoadUint64(&sink.inserted), atomic.LoadUint64(&sink.updated),
+		sink.batch.Summary())
+}
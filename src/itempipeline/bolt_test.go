@@ -0,0 +1,134 @@
+package itempipeline
+
+import (
+	"base"
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 读取表中的所有记录。
+func readTable(t *testing.T, path string, table string) []map[string]interface{} {
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Can not open the database: %s", err)
+	}
+	defer db.Close()
+	var rows []map[string]interface{}
+	err = db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(table)).ForEach(func(k, v []byte) error {
+			var row map[string]interface{}
+			if err := json.Unmarshal(v, &row); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Can not read the table: %s", err)
+	}
+	return rows
+}
+
+func TestBoltSinkUpsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.db")
+	schema := ItemSchema{
+		Name:    "pages",
+		Columns: []Column{{Name: "url", Type: COLUMN_STRING}, {Name: "size", Type: COLUMN_INT64}},
+		Keys:    []string{"url"},
+	}
+	args := DefaultBoltArgs(path, schema)
+	args.BatchSize = 2
+	sink, err := NewBoltSink(args)
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	sink.Process(base.Item{"url": "http://a", "size": "1", "extra": true})
+	sink.Process(base.Item{"url": "http://b", "size": 2})
+	sink.Process(base.Item{"url": "http://a", "size": 3})
+	if _, err := sink.Process(base.Item{"size": 4}); err == nil {
+		t.Errorf("An item without the key should be rejected")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if counts := sink.Count(); counts[0] != 3 || counts[1] != 1 || counts[2] != 2 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+	rows := readTable(t, path, "pages")
+	if len(rows) != 2 || rows[0]["url"] != "http://a" || rows[0]["size"] != float64(3) {
+		t.Errorf("Unexpected rows: %v", rows)
+	}
+	if _, ok := rows[0]["extra"]; ok {
+		t.Errorf("A field which is not a column should not be written: %v", rows[0])
+	}
+
+	schema.Keys = []string{"size"}
+	if _, err := NewBoltSink(DefaultBoltArgs(path, schema)); err == nil {
+		t.Errorf("Different keys of an existing table should be rejected")
+	}
+}
+
+func TestBoltSinkIntervalCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.db")
+	args := DefaultBoltArgs(path, ItemSchema{Name: "items"})
+	args.BatchInterval = 20 * time.Millisecond
+	sink, err := NewBoltSink(args)
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	defer sink.Close()
+	sink.Process(base.Item{"a": 1})
+	// 没有后续的条目到达，批次也应在间隔时间后被提交。
+	deadline := time.Now().Add(time.Second)
+	for sink.Count()[2] == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if counts := sink.Count(); counts[0] != 1 || counts[2] != 1 {
+		t.Errorf("The batch is not committed by the interval: %v", counts)
+	}
+}
+
+func TestBoltSinkCommitFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.db")
+	sink, err := NewBoltSink(DefaultBoltArgs(path, ItemSchema{Name: "items"}))
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	var reported []*BatchItemError
+	var mutex sync.Mutex
+	sink.SetErrorHandler(func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if itemErr, ok := err.(*BatchItemError); ok {
+			reported = append(reported, itemErr)
+		}
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := sink.Process(base.Item{"i": i}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	// 关闭底层的数据库，使事务失败。
+	sink.(*myBoltSink).db.Close()
+	if err := sink.Flush(); err == nil {
+		t.Errorf("The commit failure should be returned by Flush")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(reported) != 3 {
+		t.Fatalf("Every item of the failed commit should be reported: %v", reported)
+	}
+	for i, itemErr := range reported {
+		if itemErr.Item["i"] != i {
+			t.Errorf("Unexpected reported item: %v", itemErr.Item)
+		}
+	}
+	if counts := sink.Count(); counts[0] != 0 || counts[1] != 3 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
@@ -0,0 +1,122 @@
+package itempipeline
+
+import (
+	"base"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 生成在给定时间后完成、或在上下文被取消时返回其错误的条目处理器。
+func sleepy(d time.Duration, done *int32) ProcessItemContext {
+	return func(ctx context.Context, item base.Item) (base.Item, error) {
+		select {
+		case <-time.After(d):
+			atomic.AddInt32(done, 1)
+			return item, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestCloseWithinDrains(t *testing.T) {
+	var done int32
+	pipeline := NewContextItempipeline([]ProcessItemContext{sleepy(10*time.Millisecond, &done)}, nil)
+	store := &memoryDeadLetters{}
+	pipeline.SetDeadLetterStore(store)
+	pipeline.StartWorkers(2, 10, nil)
+	for i := 0; i < 6; i++ {
+		pipeline.Submit(base.Item{"i": i})
+	}
+	if errs := pipeline.CloseWithin(5 * time.Second); len(errs) != 0 {
+		t.Errorf("Unexpected close errors: %v", errs)
+	}
+	if done != 6 || store.Count() != 0 {
+		t.Errorf("Every item should be processed in time: done=%d, deadLetters=%d", done, store.Count())
+	}
+}
+
+func TestCloseWithinCancels(t *testing.T) {
+	var done, later int32
+	processors := []ProcessItemContext{
+		sleepy(time.Hour, &done),
+		AdaptProcessor(func(item base.Item) (base.Item, error) {
+			atomic.AddInt32(&later, 1)
+			return item, nil
+		}),
+	}
+	pipeline := NewContextItempipeline(processors, nil)
+	store := &memoryDeadLetters{}
+	pipeline.SetDeadLetterStore(store)
+	pipeline.StartWorkers(2, 10, nil)
+	for i := 0; i < 5; i++ {
+		pipeline.Submit(base.Item{"i": i})
+	}
+	start := time.Now()
+	pipeline.CloseWithin(50 * time.Millisecond)
+	elapsed := time.Since(start)
+	if elapsed < 50*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("CloseWithin should return shortly after the deadline: %s", elapsed)
+	}
+	// 正在处理的和队列中剩余的条目都会在第一个条目处理器处失败并成为死信。
+	letters := store.all()
+	if len(letters) != 5 || done != 0 || later != 0 {
+		t.Fatalf("Unexpected outcome: deadLetters=%d, done=%d, later=%d", len(letters), done, later)
+	}
+	for _, letter := range letters {
+		if letter.Stage != 0 || letter.Error != context.Canceled.Error() {
+			t.Errorf("Unexpected dead letter: %#v", letter)
+		}
+	}
+	if counts := pipeline.Count(); counts[2] != 5 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+func TestSendContext(t *testing.T) {
+	var done int32
+	pipeline := NewContextItempipeline([]ProcessItemContext{sleepy(time.Hour, &done)}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	errs := pipeline.SendContext(ctx, base.Item{})
+	if len(errs) != 1 || errs[0] != context.DeadlineExceeded {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	// 调用方的上下文不影响管道本身。
+	quick := NewContextItempipeline([]ProcessItemContext{sleepy(time.Millisecond, &done)}, nil)
+	if errs := quick.Send(base.Item{}); len(errs) != 0 || done != 1 {
+		t.Errorf("Unexpected outcome: errs=%v, done=%d", errs, done)
+	}
+	quick.Cancel()
+	if errs := quick.Send(base.Item{}); len(errs) != 1 || errs[0] != context.Canceled {
+		t.Errorf("A canceled pipeline should fail items: %v", errs)
+	}
+}
+
+func TestWithContextTimeout(t *testing.T) {
+	var done int32
+	processor := WithContextTimeout(sleepy(time.Hour, &done), 20*time.Millisecond)
+	start := time.Now()
+	if _, err := processor(context.Background(), base.Item{}); err != context.DeadlineExceeded {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("The processor should be canceled at the timeout: %s", elapsed)
+	}
+	plain := PlainProcessor(WithContextTimeout(sleepy(time.Millisecond, &done), time.Second))
+	if _, err := plain(base.Item{}); err != nil || done != 1 {
+		t.Errorf("Unexpected outcome: err=%v, done=%d", err, done)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var called int32
+	adapted := AdaptProcessor(func(item base.Item) (base.Item, error) {
+		atomic.AddInt32(&called, 1)
+		return item, nil
+	})
+	if _, err := adapted(ctx, base.Item{}); err != context.Canceled || called != 0 {
+		t.Errorf("An adapted processor should not be called after cancellation: err=%v", err)
+	}
+}
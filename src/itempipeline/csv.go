@@ -0,0 +1,84 @@
+package itempipeline
+
+import (
+	"base"
+	"encoding/csv"
+	"io"
+)
+
+// 条目存储器共用的列的列表。列为空时会依据第一个被写入的条目推断。
+type columnSchema struct {
+	columns []Column
+}
+
+// 获得列的列表。
+func (schema *columnSchema) resolve(item base.Item) []Column {
+	if schema.columns == nil {
+		schema.columns = inferColumns(item)
+	}
+	return schema.columns
+}
+
+// 创建CSV格式的条目存储器。
+// 参数columns决定了列及其顺序，条目中不在其中的字段会被忽略，缺少的字段为空。
+// 若columns为空，则使用第一个被写入的条目中的字段（按名称排序）。每个文件的第一行都是列名。
+func NewCSVSink(args SinkArgs, columns []Column) (ItemSink, error) {
+	if err := checkColumns(columns); err != nil {
+		return nil, err
+	}
+	schema := &columnSchema{columns: columns}
+	return newSink(args, encoderSpec{
+		extension: "csv",
+		newEncoder: func() itemEncoder {
+			return &csvEncoder{schema: schema}
+		},
+	})
+}
+
+// CSV格式的条目编码器。
+type csvEncoder struct {
+	schema *columnSchema // 列的列表。
+	writer *csv.Writer   // CSV写入器。
+	header bool          // 是否已写入列名。
+}
+
+func (encoder *csvEncoder) begin(w io.Writer) error {
+	encoder.writer = csv.NewWriter(w)
+	return nil
+}
+
+func (encoder *csvEncoder) encode(w io.Writer, item base.Item) error {
+	columns := encoder.schema.resolve(item)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		value, err := formatValue(item[column.Name])
+		if err != nil {
+			return err
+		}
+		record[i] = value
+	}
+	if !encoder.header {
+		names := make([]string, len(columns))
+		for i, column := range columns {
+			names[i] = column.Name
+		}
+		if err := encoder.writer.Write(names); err != nil {
+			return err
+		}
+		encoder.header = true
+	}
+	if err := encoder.writer.Write(record); err != nil {
+		return err
+	}
+	encoder.writer.Flush()
+	return encoder.writer.Error()
+}
+
+func (encoder *csvEncoder) flush(w io.Writer) error {
+	encoder.writer.Flush()
+	return encoder.writer.Error()
+}
+
+func (encoder *csvEncoder) end(w io.Writer) error {
+	return encoder.flush(w)
+}
@@ -0,0 +1,176 @@
+package itempipeline
+
+import (
+	"base"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 由需要关闭的组件（如批量处理器）报告的失败条目所对应的死信的序号。
+const CLOSER_STAGE = -1
+
+// 死信，即处理失败的条目及其失败的原因。
+type DeadLetter struct {
+	Time  time.Time `json:"time"`  // 失败的时间。
+	Stage int       `json:"stage"` // 出错的条目处理器在条目处理管道中的序号，或CLOSER_STAGE。
+	Error string    `json:"error"` // 错误信息。
+	// 进入条目处理管道时的原始条目。序号为CLOSER_STAGE或指向条目处理流程中的接收者时，
+	// 则是交给该组件的条目，即已被之前的条目处理器处理过的条目。
+	Item base.Item `json:"item"`
+}
+
+// 死信存储器的接口类型。
+type DeadLetterStore interface {
+	// 存储死信。
+	Put(letter DeadLetter) error
+	// 关闭存储器。
+	Close() error
+	// 获得已存储的死信的数量。
+	Count() uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建JSON Lines格式的死信存储器。每封死信占一行，文件的命名、切换和压缩方式与条目存储器相同。
+// 可以使用ReadDeadLetters读取其中的死信。
+func NewDeadLetterStore(args SinkArgs) (DeadLetterStore, error) {
+	sink, err := NewJSONLinesSink(args)
+	if err != nil {
+		return nil, err
+	}
+	return &myDeadLetterStore{sink: sink}, nil
+}
+
+// 死信存储器的实现类型。
+type myDeadLetterStore struct {
+	sink  ItemSink // 实际写入文件的条目存储器。
+	count uint64   // 已存储的死信的数量。
+}
+
+func (store *myDeadLetterStore) Put(letter DeadLetter) error {
+	_, err := store.sink.Process(base.Item{
+		"time":  letter.Time,
+		"stage": letter.Stage,
+		"error": letter.Error,
+		"item":  letter.Item,
+	})
+	if err == nil {
+		atomic.AddUint64(&store.count, 1)
+	}
+	return err
+}
+
+func (store *myDeadLetterStore) Close() error {
+	return store.sink.Close()
+}
+
+func (store *myDeadLetterStore) Count() uint64 {
+	return atomic.LoadUint64(&store.count)
+}
+
+func (store *myDeadLetterStore) Summary() string {
+	return store.sink.Summary()
+}
+
+// 依次读取与模式匹配的各个死信文件（按文件名排序）中的死信。压缩的文件会依据扩展名被解压。
+// 条目中的数字会被读取为json.Number，以免丢失精度。handler返回错误时读取会被中止。
+func ReadDeadLetters(pattern string, handler func(letter DeadLetter) error) error {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := readDeadLetterFile(name, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 读取一个死信文件。
+func readDeadLetterFile(name string, handler func(letter DeadLetter) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var reader io.Reader = bufio.NewReader(file)
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case strings.HasSuffix(name, ".zst"):
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	}
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	for line := 1; ; line++ {
+		var letter DeadLetter
+		if err := decoder.Decode(&letter); err == io.EOF {
+			return nil
+		} else if err != nil {
+			// 未被正常关闭的文件的末尾可能不完整。
+			if err == io.ErrUnexpectedEOF {
+				return errors.New(fmt.Sprintf("The dead letter file %s is truncated at record %d", name, line))
+			}
+			return err
+		}
+		if err := handler(letter); err != nil {
+			return err
+		}
+	}
+}
+
+// 把与模式匹配的死信文件中的条目重新发送到条目处理管道。
+// 条目处理器产生的死信由管道的SendDeadLetter方法重新处理，即原始条目会经过管道中的所有条目处理器，
+// 而条目处理流程中的接收者产生的死信只会被交给该接收者；
+// 序号为CLOSER_STAGE的死信中的条目已被各个条目处理器处理过，它们只会被交给参数sink（通常是条目存储器）。
+// sink为nil时后者不会被重放，而是被计为再次失败。再次失败的条目会被管道的死信存储器记录。
+// 结果值为重新发送的条目数量和其中再次失败的条目数量。
+func Replay(pipeline Itempipeline, sink ProcessItem, pattern string) (replayed uint64, failed uint64, err error) {
+	err = ReadDeadLetters(pattern, func(letter DeadLetter) error {
+		replayed++
+		if letter.Stage != CLOSER_STAGE {
+			if errs := pipeline.SendDeadLetter(letter); len(errs) > 0 {
+				failed++
+			}
+			return nil
+		}
+		var sinkErr error
+		if sink == nil {
+			sinkErr = errors.New(letter.Error)
+		} else {
+			_, sinkErr = callProcessor(sink, letter.Item)
+		}
+		if sinkErr == nil {
+			return nil
+		}
+		failed++
+		if store := pipeline.DeadLetterStore(); store != nil {
+			return store.Put(DeadLetter{Time: time.Now(), Stage: CLOSER_STAGE, Error: sinkErr.Error(), Item: letter.Item})
+		}
+		return nil
+	})
+	return
+}
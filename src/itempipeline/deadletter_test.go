@@ -0,0 +1,148 @@
+package itempipeline
+
+import (
+	"base"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDeadLettersAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	var broken int32 = 1
+	mutate := func(item base.Item) (base.Item, error) {
+		item["mutated"] = true
+		return item, nil
+	}
+	flaky := func(item base.Item) (base.Item, error) {
+		if atomic.LoadInt32(&broken) == 1 && item["id"] != "ok" {
+			return nil, errors.New("the backend is down")
+		}
+		return item, nil
+	}
+	var delivered []base.Item
+	collect := func(item base.Item) (base.Item, error) {
+		delivered = append(delivered, item)
+		return item, nil
+	}
+	store, err := NewDeadLetterStore(SinkArgs{Path: filepath.Join(dir, "deadletter"), Compression: COMPRESSION_GZIP})
+	if err != nil {
+		t.Fatalf("Can not create the dead letter store: %s", err)
+	}
+	pipeline := NewItempipeline([]ProcessItem{mutate, flaky, collect})
+	pipeline.SetFailFsat(true)
+	pipeline.SetDeadLetterStore(store)
+	pipeline.Send(base.Item{"id": "ok"})
+	pipeline.Send(base.Item{"id": "a", "size": int64(1) << 60})
+	pipeline.Send(base.Item{"id": "b"})
+	if store.Count() != 2 || len(delivered) != 1 {
+		t.Fatalf("Unexpected outcome: deadLetters=%d, delivered=%d", store.Count(), len(delivered))
+	}
+	if errs := pipeline.Close(); len(errs) != 0 {
+		t.Fatalf("Unexpected close errors: %v", errs)
+	}
+
+	pattern := filepath.Join(dir, "deadletter-*.jsonl.gz")
+	var letters []DeadLetter
+	if err := ReadDeadLetters(pattern, func(letter DeadLetter) error {
+		letters = append(letters, letter)
+		return nil
+	}); err != nil {
+		t.Fatalf("Can not read the dead letters: %s", err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("Unexpected dead letters: %v", letters)
+	}
+	first := letters[0]
+	if first.Stage != 1 || first.Error != "the backend is down" || first.Item["id"] != "a" || first.Time.IsZero() {
+		t.Errorf("Unexpected dead letter: %#v", first)
+	}
+	if _, ok := first.Item["mutated"]; ok {
+		t.Errorf("The dead letter should hold the original item: %v", first.Item)
+	}
+	if first.Item["size"] != json.Number("1152921504606846976") {
+		t.Errorf("The number should be read without losing precision: %#v", first.Item["size"])
+	}
+
+	// 后端恢复后重放死信。
+	atomic.StoreInt32(&broken, 0)
+	delivered = nil
+	replayStore, _ := NewDeadLetterStore(SinkArgs{Path: filepath.Join(dir, "replayed")})
+	replayPipeline := NewItempipeline([]ProcessItem{mutate, flaky, collect})
+	replayPipeline.SetFailFsat(true)
+	replayPipeline.SetDeadLetterStore(replayStore)
+	replayed, failed, err := Replay(replayPipeline, collect, pattern)
+	if err != nil || replayed != 2 || failed != 0 {
+		t.Errorf("Unexpected replay: replayed=%d, failed=%d, err=%v", replayed, failed, err)
+	}
+	replayPipeline.Close()
+	if len(delivered) != 2 || delivered[1]["id"] != "b" || delivered[1]["mutated"] != true {
+		t.Errorf("Unexpected replayed items: %v", delivered)
+	}
+	if replayStore.Count() != 0 {
+		t.Errorf("No item should fail again: %d", replayStore.Count())
+	}
+}
+
+func TestReadTruncatedDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "deadletter-1.jsonl")
+	content := `{"time":"2020-01-01T00:00:00Z","stage":0,"error":"e","item":{"id":1}}` + "\n" + `{"time":"2020-01`
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatalf("Can not write the file: %s", err)
+	}
+	var count int
+	err := ReadDeadLetters(filepath.Join(dir, "*.jsonl"), func(letter DeadLetter) error {
+		count++
+		return nil
+	})
+	if err == nil || count != 1 {
+		t.Errorf("Unexpected result: count=%d, err=%v", count, err)
+	}
+}
+
+func TestReplayCloserDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"time":"2020-01-01T00:00:00Z","stage":0,"error":"e","item":{"id":"a"}}` + "\n" +
+		`{"time":"2020-01-01T00:00:00Z","stage":-1,"error":"e","item":{"id":"b","mutated":true}}` + "\n" +
+		`{"time":"2020-01-01T00:00:00Z","stage":-1,"error":"e","item":{"id":"c","mutated":true}}` + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "deadletter-1.jsonl"), []byte(content), 0644); err != nil {
+		t.Fatalf("Can not write the file: %s", err)
+	}
+	var mutations int32
+	mutate := func(item base.Item) (base.Item, error) {
+		atomic.AddInt32(&mutations, 1)
+		item["mutated"] = true
+		return item, nil
+	}
+	var delivered []base.Item
+	sink := func(item base.Item) (base.Item, error) {
+		if item["id"] == "c" {
+			return nil, errors.New("disk full")
+		}
+		delivered = append(delivered, item)
+		return item, nil
+	}
+	store := &memoryDeadLetters{}
+	pipeline := NewItempipeline([]ProcessItem{mutate, sink})
+	pipeline.SetFailFsat(true)
+	pipeline.SetDeadLetterStore(store)
+	replayed, failed, err := Replay(pipeline, sink, filepath.Join(dir, "*.jsonl"))
+	if err != nil || replayed != 3 || failed != 1 {
+		t.Errorf("Unexpected replay: replayed=%d, failed=%d, err=%v", replayed, failed, err)
+	}
+	// 交给需要关闭的组件的条目不会再次经过条目处理器。
+	if mutations != 1 || len(delivered) != 2 || delivered[1]["id"] != "b" {
+		t.Errorf("Unexpected outcome: mutations=%d, delivered=%v", mutations, delivered)
+	}
+	letters := store.all()
+	if len(letters) != 1 || letters[0].Stage != CLOSER_STAGE || letters[0].Item["id"] != "c" || letters[0].Error != "disk full" {
+		t.Errorf("Unexpected dead letters: %v", letters)
+	}
+	if _, _, err := Replay(pipeline, nil, filepath.Join(dir, "*.jsonl")); err != nil || store.Count() != 3 {
+		t.Errorf("Without a sink the closer dead letters should fail again: count=%d, err=%v", store.Count(), err)
+	}
+}
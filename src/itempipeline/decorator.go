@@ -0,0 +1,324 @@
+package itempipeline
+
+import (
+	"base"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BREAKER_CLOSED    = "closed"    //熔断器闭合，条目正常地被处理
+	BREAKER_OPEN      = "open"      //熔断器断开，条目会直接得到ErrCircuitOpen
+	BREAKER_HALF_OPEN = "half-open" //冷却结束，允许一个条目试探条目处理器是否已恢复
+)
+
+// 熔断器断开时条目得到的错误。
+var ErrCircuitOpen = errors.New("The circuit breaker is open")
+
+// 为条目处理器加上超时限制。超时后会立即返回错误，而条目处理器仍会在后台运行至结束，其结果会被丢弃。
+// 注意，该后台运行的条目处理器不会被取消，也不会被等待；需要取消时应使用WithContextTimeout。
+// 为了避免与后续的条目处理器竞争，条目处理器收到的是条目的副本。若它按时返回且结果值为nil，
+// 则结果值为这个副本，因此就地修改条目的条目处理器所做的修改不会丢失。参数timeout不大于0时不加限制。
+func WithTimeout(processor ProcessItem, timeout time.Duration) ProcessItem {
+	if timeout <= 0 {
+		return processor
+	}
+	type outcome struct {
+		result base.Item
+		err    error
+	}
+	return func(item base.Item) (result base.Item, err error) {
+		copied := copyItem(item)
+		done := make(chan outcome, 1)
+		go func() {
+			result, err := callProcessor(processor, copied)
+			done <- outcome{result, err}
+		}()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case o := <-done:
+			if o.result == nil && o.err == nil {
+				return copied, nil
+			}
+			return o.result, o.err
+		case <-timer.C:
+			return nil, errors.New(fmt.Sprintf("The item processing timed out after %s", timeout))
+		}
+	}
+}
+
+// 为可被取消的条目处理器加上超时限制。超时后传给条目处理器的上下文会被取消。
+// 与WithTimeout不同，它会等待条目处理器返回，因此条目处理器应当遵从上下文的取消。参数timeout不大于0时不加限制。
+func WithContextTimeout(processor ProcessItemContext, timeout time.Duration) ProcessItemContext {
+	if timeout <= 0 {
+		return processor
+	}
+	return func(ctx context.Context, item base.Item) (result base.Item, err error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return processor(ctx, item)
+	}
+}
+
+// 重试的参数容器的描述模板。
+var retryArgsTemplate string = "{ attempts: %d, backoff: %s, maxBackoff: %s }"
+
+// 重试的参数容器。
+type RetryArgs struct {
+	// 最多尝试的次数（包括第一次）。必须大于0。
+	Attempts int
+	// 第一次重试前等待的时间。之后每次重试的等待时间都会翻倍。
+	Backoff time.Duration
+	// 重试前等待的最长时间。为0表示不限制。
+	MaxBackoff time.Duration
+	// 判断错误是否值得重试。为nil表示除ErrItemDropped和ErrCircuitOpen以外的错误都值得重试。
+	Retryable func(err error) bool
+}
+
+// 获得默认的重试的参数容器。
+func DefaultRetryArgs() RetryArgs {
+	return RetryArgs{
+		Attempts:   3,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+	}
+}
+
+func (args *RetryArgs) Check() error {
+	if args.Attempts <= 0 {
+		return errors.New("The attempts must be positive!\n")
+	}
+	if args.Backoff < 0 || args.MaxBackoff < 0 {
+		return errors.New("The backoff can not be negative!\n")
+	}
+	return nil
+}
+
+func (args *RetryArgs) String() string {
+	return fmt.Sprintf(retryArgsTemplate, args.Attempts, args.Backoff, args.MaxBackoff)
+}
+
+// 为条目处理器加上重试。每次尝试使用的都是条目的副本，最后一次尝试的错误会附带已尝试的次数。
+// 成功的尝试的结果值为nil时，结果值为该次尝试使用的副本。重试前的等待无法被中断，需要中断时应使用WithContextRetry。
+func WithRetry(processor ProcessItem, args RetryArgs) (ProcessItem, error) {
+	if processor == nil {
+		return nil, errors.New("Invalid item processor!\n")
+	}
+	retried, err := WithContextRetry(AdaptProcessor(processor), args)
+	if err != nil {
+		return nil, err
+	}
+	return PlainProcessor(retried), nil
+}
+
+// 为可被取消的条目处理器加上重试。与WithRetry相同，但上下文被取消后不会再重试，
+// 重试前的等待也会被中断，此时的错误值为上下文的错误。
+func WithContextRetry(processor ProcessItemContext, args RetryArgs) (ProcessItemContext, error) {
+	if processor == nil {
+		return nil, errors.New("Invalid item processor!\n")
+	}
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	retryable := args.Retryable
+	if retryable == nil {
+		retryable = func(err error) bool {
+			return err != ErrItemDropped && err != ErrCircuitOpen
+		}
+	}
+	return func(ctx context.Context, item base.Item) (result base.Item, err error) {
+		backoff := args.Backoff
+		for attempt := 1; ; attempt++ {
+			copied := copyItem(item)
+			result, err = callContextProcessor(ctx, processor, copied)
+			if err == nil {
+				if result == nil {
+					return copied, nil
+				}
+				return result, nil
+			}
+			if !retryable(err) {
+				return result, err
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			if attempt >= args.Attempts {
+				if attempt == 1 {
+					return nil, err
+				}
+				return nil, errors.New(fmt.Sprintf("%s (after %d attempts)", err, attempt))
+			}
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			backoff *= 2
+			if args.MaxBackoff > 0 && backoff > args.MaxBackoff {
+				backoff = args.MaxBackoff
+			}
+		}
+	}, nil
+}
+
+// 复制条目。
+func copyItem(item base.Item) base.Item {
+	copied := make(base.Item, len(item))
+	for k, v := range item {
+		copied[k] = v
+	}
+	return copied
+}
+
+// 熔断器的参数容器的描述模板。
+var breakerArgsTemplate string = "{ failureThreshold: %d, cooldown: %s }"
+
+// 熔断器的参数容器。
+type BreakerArgs struct {
+	FailureThreshold uint32        // 使熔断器断开的连续失败次数。必须大于0。
+	Cooldown         time.Duration // 熔断器断开后允许试探的等待时间。必须大于0。
+}
+
+// 获得默认的熔断器的参数容器。
+func DefaultBreakerArgs() BreakerArgs {
+	return BreakerArgs{FailureThreshold: 5, Cooldown: 10 * time.Second}
+}
+
+func (args *BreakerArgs) Check() error {
+	if args.FailureThreshold == 0 {
+		return errors.New("The failure threshold must be positive!\n")
+	}
+	if args.Cooldown <= 0 {
+		return errors.New("The cooldown must be positive!\n")
+	}
+	return nil
+}
+
+func (args *BreakerArgs) String() string {
+	return fmt.Sprintf(breakerArgsTemplate, args.FailureThreshold, args.Cooldown)
+}
+
+// 熔断器的接口类型。条目处理器连续失败达到阈值后，熔断器会断开，
+// 此后的条目会直接得到ErrCircuitOpen，直到冷却结束后有一个试探的条目被成功处理。
+type CircuitBreaker interface {
+	// 为条目处理器加上熔断器。
+	Wrap(processor ProcessItem) ProcessItem
+	// 获得熔断器的状态，即BREAKER_CLOSED、BREAKER_OPEN或BREAKER_HALF_OPEN。
+	State() string
+	// 获得经过熔断器的条目、失败的条目、被熔断器拒绝的条目以及熔断器断开次数的计数值。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建熔断器。
+func NewCircuitBreaker(args BreakerArgs) (CircuitBreaker, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	return &myCircuitBreaker{args: args, state: BREAKER_CLOSED}, nil
+}
+
+// 熔断器的实现类型。
+type myCircuitBreaker struct {
+	args      BreakerArgs // 参数。
+	state     string      // 状态。
+	failures  uint32      // 连续失败的次数。
+	openedAt  time.Time   // 最近一次断开的时间。
+	probing   bool        // 是否有试探的条目正在被处理。
+	mutex     sync.Mutex  // 针对状态的互斥锁。
+	calls     uint64      // 经过熔断器的条目的数量。
+	failed    uint64      // 失败的条目的数量。
+	rejected  uint64      // 被拒绝的条目的数量。
+	openTimes uint64      // 断开的次数。
+}
+
+func (cb *myCircuitBreaker) Wrap(processor ProcessItem) ProcessItem {
+	return func(item base.Item) (result base.Item, err error) {
+		atomic.AddUint64(&cb.calls, 1)
+		if !cb.allow() {
+			atomic.AddUint64(&cb.rejected, 1)
+			return nil, ErrCircuitOpen
+		}
+		result, err = callProcessor(processor, item)
+		cb.record(err)
+		return result, err
+	}
+}
+
+// 判断是否允许处理条目。冷却结束后只允许一个试探的条目。
+func (cb *myCircuitBreaker) allow() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	switch cb.state {
+	case BREAKER_OPEN:
+		if time.Since(cb.openedAt) < cb.args.Cooldown {
+			return false
+		}
+		cb.state = BREAKER_HALF_OPEN
+		cb.probing = true
+		return true
+	case BREAKER_HALF_OPEN:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	}
+	return true
+}
+
+// 记录处理结果并更新状态。被丢弃的条目不算失败。
+func (cb *myCircuitBreaker) record(err error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if err == nil || err == ErrItemDropped {
+		cb.failures = 0
+		cb.probing = false
+		cb.state = BREAKER_CLOSED
+		return
+	}
+	atomic.AddUint64(&cb.failed, 1)
+	cb.failures++
+	if cb.state == BREAKER_HALF_OPEN || cb.failures >= cb.args.FailureThreshold {
+		if cb.state != BREAKER_OPEN {
+			atomic.AddUint64(&cb.openTimes, 1)
+		}
+		cb.state = BREAKER_OPEN
+		cb.openedAt = time.Now()
+		cb.probing = false
+	}
+}
+
+func (cb *myCircuitBreaker) State() string {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+func (cb *myCircuitBreaker) Count() []uint64 {
+	counts := make([]uint64, 4)
+	counts[0] = atomic.LoadUint64(&cb.calls)
+	counts[1] = atomic.LoadUint64(&cb.failed)
+	counts[2] = atomic.LoadUint64(&cb.rejected)
+	counts[3] = atomic.LoadUint64(&cb.openTimes)
+	return counts
+}
+
+var breakerSummaryTemplate = "state: %s, failureThreshold: %d, cooldown: %s," +
+	" calls: %d, failed: %d, rejected: %d, opened: %d"
+
+func (cb *myCircuitBreaker) Summary() string {
+	counts := cb.Count()
+	return fmt.Sprintf(breakerSummaryTemplate,
+		cb.State(), cb.args.FailureThreshold, cb.args.Cooldown,
+		counts[0], counts[1], counts[2], counts[3])
+}
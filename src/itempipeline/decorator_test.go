@@ -0,0 +1,187 @@
+package itempipeline
+
+import (
+	"base"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	inPlace := func(item base.Item) (base.Item, error) {
+		item["touched"] = true
+		return nil, nil
+	}
+	result, err := WithTimeout(inPlace, time.Second)(base.Item{"a": 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result["touched"] != true || result["a"] != 1 {
+		t.Errorf("The in-place change is lost: %v", result)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(item base.Item) (base.Item, error) {
+		<-release
+		return item, nil
+	}
+	start := time.Now()
+	_, err = WithTimeout(slow, 20*time.Millisecond)(base.Item{})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("The timeout took too long: %s", elapsed)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	var calls int32
+	flaky := func(item base.Item) (base.Item, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			item["partial"] = true
+			return nil, errors.New("temporary failure")
+		}
+		return nil, nil
+	}
+	retried, err := WithRetry(flaky, RetryArgs{Attempts: 3, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	item := base.Item{"a": 1}
+	result, err := retried(item)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if calls != 3 || result["a"] != 1 {
+		t.Errorf("Unexpected outcome: calls=%d, result=%v", calls, result)
+	}
+	if _, ok := item["partial"]; ok {
+		t.Errorf("The original item should not be modified by a failed attempt")
+	}
+
+	atomic.StoreInt32(&calls, -10)
+	_, err = retried(base.Item{})
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	dropped := func(item base.Item) (base.Item, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, ErrItemDropped
+	}
+	atomic.StoreInt32(&calls, 0)
+	retried, _ = WithRetry(dropped, DefaultRetryArgs())
+	if _, err := retried(base.Item{}); err != ErrItemDropped || calls != 1 {
+		t.Errorf("A dropped item should not be retried: err=%v, calls=%d", err, calls)
+	}
+}
+
+func TestWithContextRetryCanceled(t *testing.T) {
+	failing := func(ctx context.Context, item base.Item) (base.Item, error) {
+		return nil, errors.New("failure")
+	}
+	retried, err := WithContextRetry(failing, RetryArgs{Attempts: 5, Backoff: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = retried(ctx, base.Item{})
+	if err != context.Canceled {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("The backoff is not interrupted: %s", elapsed)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker, err := NewCircuitBreaker(BreakerArgs{FailureThreshold: 2, Cooldown: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var failing int32 = 1
+	processor := breaker.Wrap(func(item base.Item) (base.Item, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("failure")
+		}
+		return item, nil
+	})
+	processor(base.Item{})
+	if state := breaker.State(); state != BREAKER_CLOSED {
+		t.Fatalf("Unexpected state after one failure: %s", state)
+	}
+	processor(base.Item{})
+	if state := breaker.State(); state != BREAKER_OPEN {
+		t.Fatalf("Unexpected state after two failures: %s", state)
+	}
+	if _, err := processor(base.Item{}); err != ErrCircuitOpen {
+		t.Errorf("Unexpected error of an open breaker: %v", err)
+	}
+
+	// 冷却后试探失败会使熔断器再次断开。
+	time.Sleep(40 * time.Millisecond)
+	processor(base.Item{})
+	if state := breaker.State(); state != BREAKER_OPEN {
+		t.Fatalf("Unexpected state after a failed probe: %s", state)
+	}
+
+	// 冷却后试探成功会使熔断器闭合。
+	time.Sleep(40 * time.Millisecond)
+	atomic.StoreInt32(&failing, 0)
+	if _, err := processor(base.Item{}); err != nil {
+		t.Errorf("Unexpected error of the probe: %s", err)
+	}
+	if state := breaker.State(); state != BREAKER_CLOSED {
+		t.Fatalf("Unexpected state after a successful probe: %s", state)
+	}
+	counts := breaker.Count()
+	expected := []uint64{5, 3, 1, 2}
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Errorf("Unexpected counts: %v (expected %v)", counts, expected)
+			break
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	breaker, _ := NewCircuitBreaker(BreakerArgs{FailureThreshold: 1, Cooldown: 10 * time.Millisecond})
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	var fail int32 = 1
+	processor := breaker.Wrap(func(item base.Item) (base.Item, error) {
+		if atomic.LoadInt32(&fail) == 1 {
+			return nil, errors.New("failure")
+		}
+		close(probing)
+		<-release
+		return item, nil
+	})
+	processor(base.Item{})
+	atomic.StoreInt32(&fail, 0)
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		processor(base.Item{})
+		close(done)
+	}()
+	<-probing
+	if state := breaker.State(); state != BREAKER_HALF_OPEN {
+		t.Errorf("Unexpected state while probing: %s", state)
+	}
+	if _, err := processor(base.Item{}); err != ErrCircuitOpen {
+		t.Errorf("Only one probe should be allowed, but got: %v", err)
+	}
+	close(release)
+	<-done
+	if state := breaker.State(); state != BREAKER_CLOSED {
+		t.Errorf("Unexpected state after the probe: %s", state)
+	}
+}
@@ -0,0 +1,374 @@
+package itempipeline
+
+import (
+	"base"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// 磁盘键集合中存放键的桶的名称。
+const DEDUP_KEY_BUCKET = "keys"
+
+// 条目键的集合。
+type KeySet interface {
+	// 加入键。结果值exists表示键在加入前是否已存在。查找与加入是一个原子操作。
+	Add(key string) (exists bool, err error)
+	// 获得键的数量。
+	Count() uint64
+	// 关闭集合。
+	Close() error
+}
+
+// 创建内存中的键集合。
+func NewMemoryKeySet() KeySet {
+	return &myMemoryKeySet{keys: make(map[string]bool)}
+}
+
+// 内存键集合的实现类型。
+type myMemoryKeySet struct {
+	keys  map[string]bool // 键的字典。
+	mutex sync.Mutex      // 互斥锁。
+}
+
+func (set *myMemoryKeySet) Add(key string) (bool, error) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	if set.keys[key] {
+		return true, nil
+	}
+	set.keys[key] = true
+	return false, nil
+}
+
+func (set *myMemoryKeySet) Count() uint64 {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	return uint64(len(set.keys))
+}
+
+func (set *myMemoryKeySet) Close() error {
+	return nil
+}
+
+// 创建基于bbolt数据库文件的键集合，适用于键的数量超出内存承受能力的爬取。
+// 集合只在一次爬取内有效，因此文件中已有的键会被清除。为了速度，写入不会被同步到磁盘。
+func NewDiskKeySet(path string) (KeySet, error) {
+	if path == "" {
+		return nil, errors.New("The key set path can not be empty!\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{NoSync: true})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(DEDUP_KEY_BUCKET)) != nil {
+			if err := tx.DeleteBucket([]byte(DEDUP_KEY_BUCKET)); err != nil {
+				return err
+			}
+		}
+		_, err := tx.CreateBucket([]byte(DEDUP_KEY_BUCKET))
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &myDiskKeySet{db: db}, nil
+}
+
+// 磁盘键集合的实现类型。
+type myDiskKeySet struct {
+	db    *bbolt.DB // 数据库。
+	count uint64    // 键的数量。
+}
+
+func (set *myDiskKeySet) Add(key string) (bool, error) {
+	exists := false
+	err := set.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(DEDUP_KEY_BUCKET))
+		if bucket.Get([]byte(key)) != nil {
+			exists = true
+			return nil
+		}
+		return bucket.Put([]byte(key), []byte{})
+	})
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		atomic.AddUint64(&set.count, 1)
+	}
+	return exists, nil
+}
+
+func (set *myDiskKeySet) Count() uint64 {
+	return atomic.LoadUint64(&set.count)
+}
+
+func (set *myDiskKeySet) Close() error {
+	return set.db.Close()
+}
+
+// 去重处理器的参数容器的描述模板。
+var dedupArgsTemplate string = "{ fields: %v, ignoreFields: %v, merge: %v, sourceField: %s, mergeField: %s }"
+
+// 去重处理器的参数容器。
+type DedupArgs struct {
+	// 组成条目的键的字段。缺少其中任何一个字段的条目会被原样放行。为空表示使用条目内容的散列值作为键。
+	Fields []string
+	// 计算条目内容的散列值时忽略的字段。
+	IgnoreFields []string
+	// 是否合并重复的条目。合并时，首次出现的条目会被暂存，并在去重处理器被关闭时
+	// 连同所有重复条目的来源网页一起被交给Output；否则重复的条目会被直接丢弃。
+	Merge bool
+	// 条目中表示来源网页的字段。
+	SourceField string
+	// 合并后的条目中存放来源网页列表的字段。
+	MergeField string
+	// 合并后的条目的接收者，如条目存储器的Process方法。合并时必须被设置。
+	Output ProcessItem
+}
+
+// 获得默认的去重处理器的参数容器。
+// 分析器生成的条目都带有被解析的网页的网址（parent_url），因此在计算内容的散列值时会被忽略。
+func DefaultDedupArgs() DedupArgs {
+	return DedupArgs{
+		IgnoreFields: []string{"parent_url"},
+		SourceField:  "parent_url",
+		MergeField:   "sources",
+	}
+}
+
+func (args *DedupArgs) Check() error {
+	for i, field := range args.Fields {
+		if field == "" {
+			return errors.New(fmt.Sprintf("The key field[%d] is empty!\n", i))
+		}
+	}
+	if args.Merge {
+		if args.SourceField == "" || args.MergeField == "" {
+			return errors.New("The source field and the merge field can not be empty when merging!\n")
+		}
+		if args.Output == nil {
+			return errors.New("The output of merged items can not be nil when merging!\n")
+		}
+	}
+	return nil
+}
+
+func (args *DedupArgs) String() string {
+	return fmt.Sprintf(dedupArgsTemplate,
+		args.Fields, args.IgnoreFields, args.Merge, args.SourceField, args.MergeField)
+}
+
+// 去重处理器的接口类型。它会在一次爬取内丢弃键相同的条目，也可以把它们合并为一个条目。
+// 合并时应把它注册为条目处理管道中需要关闭的组件，并放在Output所属的组件之前，以便在关闭时输出合并后的条目。
+type DedupProcessor interface {
+	// 处理条目。重复的（合并时则是所有带键的）条目会得到ErrItemDropped。该方法可被直接用作条目处理器。
+	Process(item base.Item) (result base.Item, err error)
+	// 输出合并后的条目（若需要）并关闭键集合。关闭后的去重处理器不再接受条目。
+	Close() error
+	// 设置错误处理函数。它会收到输出合并后的条目时产生的错误。
+	SetErrorHandler(errorHandler func(err error))
+	// 获得已处理的条目、其中唯一的条目以及重复的条目的计数值。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建去重处理器。参数set为nil时使用内存中的键集合。
+func NewDedupProcessor(args DedupArgs, set KeySet) (DedupProcessor, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	if set == nil {
+		set = NewMemoryKeySet()
+	}
+	ignored := make(map[string]bool)
+	for _, field := range args.IgnoreFields {
+		ignored[field] = true
+	}
+	return &myDedupProcessor{
+		args:    args,
+		set:     set,
+		ignored: ignored,
+		merged:  make(map[string]*mergedItem),
+	}, nil
+}
+
+// 合并中的条目。
+type mergedItem struct {
+	item    base.Item       // 首次出现的条目。
+	sources []string        // 来源网页的列表。
+	seen    map[string]bool // 已记录的来源网页。
+}
+
+// 去重处理器的实现类型。
+type myDedupProcessor struct {
+	args         DedupArgs              // 参数。
+	set          KeySet                 // 键集合。
+	ignored      map[string]bool        // 计算散列值时忽略的字段。
+	merged       map[string]*mergedItem // 合并中的条目。
+	order        []string               // 合并中的条目的键，按首次出现的顺序排列。
+	closed       bool                   // 是否已被关闭。
+	mutex        sync.Mutex             // 针对合并中的条目的互斥锁。
+	errorHandler func(err error)        // 错误处理函数。
+	handlerMutex sync.RWMutex           // 针对错误处理函数的读写锁。
+	processed    uint64                 // 已处理的条目的数量。
+	unique       uint64                 // 唯一的条目的数量。
+	duplicates   uint64                 // 重复的条目的数量。
+}
+
+func (dp *myDedupProcessor) Process(item base.Item) (result base.Item, err error) {
+	if item == nil {
+		return nil, errors.New("The item is invalid!\n")
+	}
+	key, ok, err := dp.key(item)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return item, nil
+	}
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	if dp.closed {
+		return nil, errors.New("The dedup processor has been closed!\n")
+	}
+	exists, err := dp.set.Add(key)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&dp.processed, 1)
+	if exists {
+		atomic.AddUint64(&dp.duplicates, 1)
+	} else {
+		atomic.AddUint64(&dp.unique, 1)
+	}
+	if !dp.args.Merge {
+		if exists {
+			return nil, ErrItemDropped
+		}
+		return item, nil
+	}
+	merged, ok := dp.merged[key]
+	if !ok {
+		copied := make(base.Item, len(item))
+		for k, v := range item {
+			copied[k] = v
+		}
+		merged = &mergedItem{item: copied, seen: make(map[string]bool)}
+		dp.merged[key] = merged
+		dp.order = append(dp.order, key)
+	}
+	if source, err := formatValue(item[dp.args.SourceField]); err == nil && source != "" && !merged.seen[source] {
+		merged.seen[source] = true
+		merged.sources = append(merged.sources, source)
+	}
+	return nil, ErrItemDropped
+}
+
+// 计算条目的键。结果值ok为false表示条目缺少组成键的字段。
+func (dp *myDedupProcessor) key(item base.Item) (key string, ok bool, err error) {
+	var data []byte
+	if len(dp.args.Fields) > 0 {
+		values := make([]string, len(dp.args.Fields))
+		for i, field := range dp.args.Fields {
+			value, ok := item[field]
+			if !ok || value == nil {
+				return "", false, nil
+			}
+			if values[i], err = formatValue(value); err != nil {
+				return "", false, err
+			}
+		}
+		data, err = json.Marshal(values)
+	} else {
+		content := make(map[string]interface{}, len(item))
+		for k, v := range item {
+			if !dp.ignored[k] {
+				content[k] = v
+			}
+		}
+		// 字典的键会被排序，因此相同的内容总会得到相同的编码。
+		data, err = json.Marshal(content)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:]), true, nil
+}
+
+func (dp *myDedupProcessor) Close() error {
+	dp.mutex.Lock()
+	if dp.closed {
+		dp.mutex.Unlock()
+		return nil
+	}
+	dp.closed = true
+	order, merged := dp.order, dp.merged
+	dp.order, dp.merged = nil, nil
+	dp.mutex.Unlock()
+	dp.handlerMutex.RLock()
+	errorHandler := dp.errorHandler
+	dp.handlerMutex.RUnlock()
+	var failed int
+	var firstErr error
+	for _, key := range order {
+		entry := merged[key]
+		entry.item[dp.args.MergeField] = entry.sources
+		if _, err := dp.args.Output(entry.item); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			if errorHandler != nil {
+				errorHandler(err)
+			}
+		}
+	}
+	err := dp.set.Close()
+	if firstErr != nil {
+		return errors.New(fmt.Sprintf("%d of %d merged items failed, the first error: %s",
+			failed, len(order), firstErr))
+	}
+	return err
+}
+
+func (dp *myDedupProcessor) SetErrorHandler(errorHandler func(err error)) {
+	dp.handlerMutex.Lock()
+	defer dp.handlerMutex.Unlock()
+	dp.errorHandler = errorHandler
+}
+
+func (dp *myDedupProcessor) Count() []uint64 {
+	counts := make([]uint64, 3)
+	counts[0] = atomic.LoadUint64(&dp.processed)
+	counts[1] = atomic.LoadUint64(&dp.unique)
+	counts[2] = atomic.LoadUint64(&dp.duplicates)
+	return counts
+}
+
+var dedupSummaryTemplate = "merge: %v, closed: %v, keys: %d," +
+	" processed: %d, unique: %d, duplicates: %d"
+
+func (dp *myDedupProcessor) Summary() string {
+	counts := dp.Count()
+	dp.mutex.Lock()
+	closed := dp.closed
+	dp.mutex.Unlock()
+	return fmt.Sprintf(dedupSummaryTemplate,
+		dp.args.Merge, closed, dp.set.Count(),
+		counts[0], counts[1], counts[2])
+}
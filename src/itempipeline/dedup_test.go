@@ -0,0 +1,136 @@
+package itempipeline
+
+import (
+	"base"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDedupDropsDuplicates(t *testing.T) {
+	for _, disk := range []bool{false, true} {
+		var set KeySet
+		if disk {
+			var err error
+			if set, err = NewDiskKeySet(filepath.Join(t.TempDir(), "dedup.db")); err != nil {
+				t.Fatalf("Can not create the key set: %s", err)
+			}
+		}
+		dedup, err := NewDedupProcessor(DefaultDedupArgs(), set)
+		if err != nil {
+			t.Fatalf("Can not create the dedup processor: %s", err)
+		}
+		pipeline := NewItempipelineWithClosers([]ProcessItem{dedup.Process}, []Closer{dedup})
+		pipeline.Send(base.Item{"title": "a", "parent_url": "http://1"})
+		pipeline.Send(base.Item{"title": "a", "parent_url": "http://2"})
+		pipeline.Send(base.Item{"title": "b", "parent_url": "http://1"})
+		pipeline.Send(base.Item{"parent_url": "http://3", "title": "a"})
+		if pipeline.Dropped() != 2 {
+			t.Errorf("Unexpected dropped items (disk=%v): %d", disk, pipeline.Dropped())
+		}
+		if counts := dedup.Count(); !reflect.DeepEqual(counts, []uint64{4, 2, 2}) {
+			t.Errorf("Unexpected counts (disk=%v): %v", disk, counts)
+		}
+		if errs := pipeline.Close(); len(errs) != 0 {
+			t.Errorf("Unexpected close errors: %v", errs)
+		}
+		if _, err := dedup.Process(base.Item{"title": "c"}); err == nil {
+			t.Errorf("A closed dedup processor should reject items")
+		}
+	}
+}
+
+func TestDedupKeyFields(t *testing.T) {
+	args := DefaultDedupArgs()
+	args.Fields = []string{"id", "lang"}
+	dedup, _ := NewDedupProcessor(args, nil)
+	if _, err := dedup.Process(base.Item{"id": 1, "lang": "en", "title": "x"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := dedup.Process(base.Item{"id": 1, "lang": "en", "title": "y"}); err != ErrItemDropped {
+		t.Errorf("An item with the same key should be dropped: %v", err)
+	}
+	if _, err := dedup.Process(base.Item{"id": 1, "lang": "de"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// 缺少组成键的字段的条目会被原样放行。
+	for i := 0; i < 2; i++ {
+		if _, err := dedup.Process(base.Item{"id": 1}); err != nil {
+			t.Errorf("An item without the key should pass: %v", err)
+		}
+	}
+}
+
+func TestDedupMerge(t *testing.T) {
+	var output []base.Item
+	args := DefaultDedupArgs()
+	args.Merge = true
+	args.Output = func(item base.Item) (base.Item, error) {
+		output = append(output, item)
+		return item, nil
+	}
+	dedup, err := NewDedupProcessor(args, nil)
+	if err != nil {
+		t.Fatalf("Can not create the dedup processor: %s", err)
+	}
+	pipeline := NewItempipelineWithClosers([]ProcessItem{dedup.Process}, []Closer{dedup})
+	pipeline.Send(base.Item{"title": "a", "parent_url": "http://1"})
+	pipeline.Send(base.Item{"title": "b", "parent_url": "http://1"})
+	pipeline.Send(base.Item{"title": "a", "parent_url": "http://2"})
+	pipeline.Send(base.Item{"title": "a", "parent_url": "http://1"})
+	if len(output) != 0 {
+		t.Fatalf("Merged items should be emitted only on closing: %v", output)
+	}
+	if errs := pipeline.Close(); len(errs) != 0 {
+		t.Fatalf("Unexpected close errors: %v", errs)
+	}
+	expected := []base.Item{
+		{"title": "a", "parent_url": "http://1", "sources": []string{"http://1", "http://2"}},
+		{"title": "b", "parent_url": "http://1", "sources": []string{"http://1"}},
+	}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("Unexpected merged items: %v", output)
+	}
+}
+
+func TestDedupMergeOutputFailure(t *testing.T) {
+	args := DefaultDedupArgs()
+	args.Merge = true
+	args.Output = func(item base.Item) (base.Item, error) {
+		return nil, errors.New("the sink is closed")
+	}
+	dedup, _ := NewDedupProcessor(args, nil)
+	var reported []error
+	dedup.SetErrorHandler(func(err error) {
+		reported = append(reported, err)
+	})
+	dedup.Process(base.Item{"title": "a"})
+	dedup.Process(base.Item{"title": "b"})
+	if err := dedup.Close(); err == nil || len(reported) != 2 {
+		t.Errorf("Unexpected outcome: err=%v, reported=%v", err, reported)
+	}
+}
+
+func TestDiskKeySetIsReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.db")
+	set, err := NewDiskKeySet(path)
+	if err != nil {
+		t.Fatalf("Can not create the key set: %s", err)
+	}
+	if exists, _ := set.Add("k"); exists {
+		t.Errorf("The key should be new")
+	}
+	if exists, _ := set.Add("k"); !exists {
+		t.Errorf("The key should exist")
+	}
+	set.Close()
+	set, err = NewDiskKeySet(path)
+	if err != nil {
+		t.Fatalf("Can not reopen the key set: %s", err)
+	}
+	defer set.Close()
+	if exists, _ := set.Add("k"); exists || set.Count() != 1 {
+		t.Errorf("The keys of a previous crawl should be cleared")
+	}
+}
@@ -0,0 +1,264 @@
+package itempipeline
+
+import (
+	"base"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 条目处理阶段。
+type Stage struct {
+	// 名称，用于错误信息。可以为空。
+	Name string
+	// 条目处理器。与ContextProcessor二者只能设置其一。
+	Processor ProcessItem
+	// 可被取消的条目处理器。
+	ContextProcessor ProcessItemContext
+	// 执行的条件。结果为false时该阶段会被跳过。为nil表示总是执行。
+	Condition func(item base.Item) bool
+}
+
+// 条目路由，即某些类型的条目专用的处理流程。
+type Route struct {
+	// 名称，用于错误信息。可以为空。
+	Name string
+	// 条目的类型（见base.ITEM_TYPE_FIELD）。为空表示默认路由，即处理未被其他路由匹配的条目。
+	Types []string
+	// 依次执行的处理阶段。快速失败时，某个阶段出错后，后续的阶段和接收者都不会被执行。
+	Stages []Stage
+	// 接收处理后的条目的组件，如以条目存储器的Process方法为条目处理器的阶段。
+	// 它们会并行地各自收到一份条目的副本，其中某个接收者出错不影响其他的接收者。
+	Sinks []Stage
+}
+
+// 条目处理流程，即调度器开启时被用来构建条目处理管道的配置。
+// 条目会先依次经过公共的处理阶段，再按类型进入某个路由。没有匹配的路由的条目在公共阶段之后即处理完毕。
+type ItemFlow struct {
+	Stages []Stage // 所有条目都会经过的公共处理阶段。
+	Routes []Route // 按条目类型选择的路由。
+}
+
+// 创建只由公共处理阶段组成的线性条目处理流程，即与依次执行条目处理器序列等效的流程。
+func LinearFlow(itemProcessors ...ProcessItem) ItemFlow {
+	stages := make([]Stage, len(itemProcessors))
+	for i, itemProcessor := range itemProcessors {
+		stages[i] = Stage{Processor: itemProcessor}
+	}
+	return ItemFlow{Stages: stages}
+}
+
+// 判断条目的类型是否为给定类型之一。可被用作处理阶段的执行条件。
+func TypeIs(types ...string) func(item base.Item) bool {
+	return func(item base.Item) bool {
+		itemType := item.Type()
+		for _, t := range types {
+			if t == itemType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// 判断条目是否含有给定的字段。可被用作处理阶段的执行条件。
+func HasField(name string) func(item base.Item) bool {
+	return func(item base.Item) bool {
+		value, ok := item[name]
+		return ok && value != nil
+	}
+}
+
+func (flow *ItemFlow) Check() error {
+	if len(flow.Stages) == 0 && len(flow.Routes) == 0 {
+		return errors.New("The item flow is empty!\n")
+	}
+	if err := checkStages(flow.Stages, "common stage"); err != nil {
+		return err
+	}
+	types := make(map[string]bool)
+	hasDefault := false
+	for i, route := range flow.Routes {
+		name := routeName(route, i)
+		if len(route.Stages) == 0 && len(route.Sinks) == 0 {
+			return errors.New(fmt.Sprintf("The %s is empty!\n", name))
+		}
+		if len(route.Types) == 0 {
+			if hasDefault {
+				return errors.New("Duplicate default route!\n")
+			}
+			hasDefault = true
+		}
+		for _, t := range route.Types {
+			if t == "" || types[t] {
+				return errors.New(fmt.Sprintf("Invalid or duplicate item type %q in the %s!\n", t, name))
+			}
+			types[t] = true
+		}
+		if err := checkStages(route.Stages, name+" stage"); err != nil {
+			return err
+		}
+		if err := checkStages(route.Sinks, name+" sink"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 检查处理阶段的有效性。
+func checkStages(stages []Stage, kind string) error {
+	for i, stage := range stages {
+		if (stage.Processor == nil) == (stage.ContextProcessor == nil) {
+			return errors.New(fmt.Sprintf("The processor of %s %s is invalid!\n", kind, stageName(stage, i)))
+		}
+	}
+	return nil
+}
+
+// 获得处理阶段在错误信息中的名称。
+func stageName(stage Stage, index int) string {
+	if stage.Name != "" {
+		return fmt.Sprintf("%q", stage.Name)
+	}
+	return fmt.Sprintf("[%d]", index)
+}
+
+// 获得路由在错误信息中的名称。
+func routeName(route Route, index int) string {
+	if route.Name != "" {
+		return fmt.Sprintf("route %q", route.Name)
+	}
+	if len(route.Types) == 0 {
+		return "default route"
+	}
+	return fmt.Sprintf("route %v", route.Types)
+}
+
+// 获得处理阶段的可被取消的条目处理器。
+func (stage *Stage) processor() ProcessItemContext {
+	if stage.ContextProcessor != nil {
+		return stage.ContextProcessor
+	}
+	return AdaptProcessor(stage.Processor)
+}
+
+// 路由中的处理阶段或接收者出错时产生的错误。它在错误信息中说明出错的位置，并保留原始的错误。
+type FlowError struct {
+	Location string // 出错的位置，如`stage "dedup" of route "link"`。
+	Err      error  // 原始的错误。
+}
+
+func (fe *FlowError) Error() string {
+	return fmt.Sprintf("The %s failed: %s", fe.Location, fe.Err)
+}
+
+// 获得原始的错误，如base.ItemValidationError或ErrCircuitOpen。
+func (fe *FlowError) Cause() error {
+	return fe.Err
+}
+
+// 获得原始的错误，以便使用errors.Is和errors.As。
+func (fe *FlowError) Unwrap() error {
+	return fe.Err
+}
+
+// 条目处理器在条目处理流程中的位置。
+type flowStep struct {
+	name  string // 名称，用于统计数据。线性的条目处理管道中为空。
+	route int    // 所属路由的序号。为-1表示公共处理阶段。
+	sink  bool   // 是否为接收者。
+}
+
+// 把条目处理流程展开为可被取消的条目处理器的序列及其位置。
+// 公共处理阶段、各个路由的处理阶段和接收者依次各自对应一个条目处理器，
+// 因此它们各自有统计数据，死信中的序号也指向具体的处理阶段或接收者。
+func (flow *ItemFlow) expand() ([]ProcessItemContext, []flowStep) {
+	itemProcessors := make([]ProcessItemContext, 0)
+	steps := make([]flowStep, 0)
+	for i, stage := range flow.Stages {
+		itemProcessors = append(itemProcessors, conditional(stage))
+		steps = append(steps, flowStep{name: "stage " + stageName(stage, i), route: -1})
+	}
+	for i, route := range flow.Routes {
+		for j, stage := range route.Stages {
+			location := fmt.Sprintf("stage %s of the %s", stageName(stage, j), routeName(route, i))
+			itemProcessors = append(itemProcessors, located(location, conditional(stage)))
+			steps = append(steps, flowStep{name: location, route: i})
+		}
+		for j, sink := range route.Sinks {
+			location := fmt.Sprintf("sink %s of the %s", stageName(sink, j), routeName(route, i))
+			itemProcessors = append(itemProcessors, located(location, conditional(sink)))
+			steps = append(steps, flowStep{name: location, route: i, sink: true})
+		}
+	}
+	return itemProcessors, steps
+}
+
+// 创建按条目处理流程处理条目的、带有需要在关闭时被关闭的组件的条目处理管道。
+// 条目会先依次经过公共处理阶段，然后只经过其所属路由的处理阶段和接收者。
+// 接收者产生的死信中存放的是交给该接收者的条目，重放时它只会被交给该接收者（见SendDeadLetter）。
+func NewFlowItempipeline(flow ItemFlow, closers []Closer) (Itempipeline, error) {
+	if err := flow.Check(); err != nil {
+		return nil, err
+	}
+	itemProcessors, steps := flow.expand()
+	ip := newItemPipeline(itemProcessors, closers)
+	ip.steps = steps
+	if len(flow.Routes) > 0 {
+		ip.router = newRouter(flow.Routes)
+	}
+	return ip, nil
+}
+
+// 生成只在满足条件时执行处理阶段的条目处理器。
+func conditional(stage Stage) ProcessItemContext {
+	processor := stage.processor()
+	if stage.Condition == nil {
+		return processor
+	}
+	return func(ctx context.Context, item base.Item) (result base.Item, err error) {
+		if !stage.Condition(item) {
+			return item, nil
+		}
+		return processor(ctx, item)
+	}
+}
+
+// 生成把错误包装为FlowError的条目处理器。ErrItemDropped不会被包装。
+func located(location string, processor ProcessItemContext) ProcessItemContext {
+	return func(ctx context.Context, item base.Item) (base.Item, error) {
+		result, err := processor(ctx, item)
+		if err != nil && err != ErrItemDropped {
+			return nil, &FlowError{Location: location, Err: err}
+		}
+		return result, err
+	}
+}
+
+// 条目路由器。
+type itemRouter struct {
+	routes       map[string]int // 条目类型与路由序号的映射。
+	defaultRoute int            // 默认路由的序号。为-1表示没有默认路由。
+}
+
+// 创建条目路由器。
+func newRouter(routes []Route) *itemRouter {
+	router := &itemRouter{routes: make(map[string]int), defaultRoute: -1}
+	for i, route := range routes {
+		if len(route.Types) == 0 {
+			router.defaultRoute = i
+		}
+		for _, t := range route.Types {
+			router.routes[t] = i
+		}
+	}
+	return router
+}
+
+// 获得条目所属的路由的序号。为-1表示没有匹配的路由。
+func (router *itemRouter) match(item base.Item) int {
+	if index, ok := router.routes[item.Type()]; ok {
+		return index
+	}
+	return router.defaultRoute
+}
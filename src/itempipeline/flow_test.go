@@ -0,0 +1,250 @@
+package itempipeline
+
+import (
+	"base"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 并发安全地收集条目的接收者。
+type collector struct {
+	items []base.Item
+	mutex sync.Mutex
+}
+
+func (c *collector) process(item base.Item) (base.Item, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = append(c.items, item)
+	return item, nil
+}
+
+func (c *collector) ids() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ids := make([]string, len(c.items))
+	for i, item := range c.items {
+		ids[i], _ = item["id"].(string)
+	}
+	return ids
+}
+
+// 生成为条目加上标记的条目处理器。
+func tag(name string) ProcessItem {
+	return func(item base.Item) (base.Item, error) {
+		item[name] = true
+		return item, nil
+	}
+}
+
+// 生成以条目处理器为接收者的处理阶段。
+func sinks(processors ...ProcessItem) []Stage {
+	stages := make([]Stage, len(processors))
+	for i, processor := range processors {
+		stages[i] = Stage{Processor: processor}
+	}
+	return stages
+}
+
+func newFlowPipeline(t *testing.T, flow ItemFlow) Itempipeline {
+	pipeline, err := NewFlowItempipeline(flow, nil)
+	if err != nil {
+		t.Fatalf("Invalid item flow: %s", err)
+	}
+	return pipeline
+}
+
+func TestFlowRouting(t *testing.T) {
+	links, pages, first, second := &collector{}, &collector{}, &collector{}, &collector{}
+	flow := ItemFlow{
+		Stages: []Stage{
+			{Name: "common", Processor: tag("common")},
+			{Name: "only-links", Processor: tag("checked"), Condition: TypeIs("link")},
+		},
+		Routes: []Route{
+			{Name: "links", Types: []string{"link"}, Stages: []Stage{{Processor: tag("link")}}, Sinks: sinks(links.process)},
+			{Name: "default", Stages: []Stage{
+				{Processor: tag("titled"), Condition: HasField("title")},
+			}, Sinks: sinks(pages.process, first.process, second.process)},
+		},
+	}
+	pipeline := newFlowPipeline(t, flow)
+	pipeline.Send(base.Item{"id": "l1", base.ITEM_TYPE_FIELD: "link"})
+	pipeline.Send(base.Item{"id": "p1", base.ITEM_TYPE_FIELD: "page", "title": "t"})
+	pipeline.Send(base.Item{"id": "u1"})
+	if ids := strings.Join(links.ids(), ","); ids != "l1" {
+		t.Errorf("Unexpected link items: %s", ids)
+	}
+	if ids := strings.Join(pages.ids(), ","); ids != "p1,u1" {
+		t.Errorf("Unexpected default items: %s", ids)
+	}
+	link := links.items[0]
+	if link["common"] != true || link["checked"] != true || link["link"] != true {
+		t.Errorf("Unexpected link item: %v", link)
+	}
+	page, untyped := pages.items[0], pages.items[1]
+	if page["common"] != true || page["checked"] != nil || page["titled"] != true || untyped["titled"] != nil {
+		t.Errorf("Unexpected default items: %v, %v", page, untyped)
+	}
+	// 每个接收者收到的都是条目的副本。
+	if len(first.items) != 2 || len(second.items) != 2 {
+		t.Fatalf("Every sink should receive every item: %v, %v", first.items, second.items)
+	}
+	first.items[0]["changed"] = true
+	if second.items[0]["changed"] != nil || pages.items[0]["changed"] != nil {
+		t.Errorf("The sinks should not share items")
+	}
+	// 每个路由中的处理阶段和接收者都有各自的统计数据，其他路由的条目不被计入。
+	stats := pipeline.ProcessorStats()
+	calls := make([]uint64, len(stats))
+	for i, s := range stats {
+		calls[i] = s.Calls
+	}
+	if !reflect.DeepEqual(calls, []uint64{3, 3, 1, 1, 2, 2, 2, 2}) {
+		t.Errorf("Unexpected calls: %v", calls)
+	}
+	if stats[7].Name != `sink [2] of the route "default"` {
+		t.Errorf("Unexpected processor name: %q", stats[7].Name)
+	}
+}
+
+func TestFlowRouteIsChosenOnce(t *testing.T) {
+	links, pages := &collector{}, &collector{}
+	retype := func(item base.Item) (base.Item, error) {
+		item[base.ITEM_TYPE_FIELD] = "page"
+		return item, nil
+	}
+	flow := ItemFlow{Routes: []Route{
+		{Types: []string{"link"}, Stages: []Stage{{Processor: retype}}, Sinks: sinks(links.process)},
+		{Types: []string{"page"}, Sinks: sinks(pages.process)},
+	}}
+	pipeline := newFlowPipeline(t, flow)
+	pipeline.Send(base.Item{"id": "l", base.ITEM_TYPE_FIELD: "link"})
+	if len(links.items) != 1 || len(pages.items) != 0 {
+		t.Errorf("An item should stay in its route: links=%v, pages=%v", links.items, pages.items)
+	}
+}
+
+func TestFlowWithoutDefaultRoute(t *testing.T) {
+	feeds := &collector{}
+	flow := ItemFlow{Routes: []Route{{Types: []string{"feed"}, Sinks: sinks(feeds.process)}}}
+	pipeline := newFlowPipeline(t, flow)
+	if errs := pipeline.Send(base.Item{"id": "p", base.ITEM_TYPE_FIELD: "page"}); len(errs) != 0 {
+		t.Errorf("An unmatched item should pass: %v", errs)
+	}
+	pipeline.Send(base.Item{"id": "f", base.ITEM_TYPE_FIELD: "feed"})
+	if ids := strings.Join(feeds.ids(), ","); ids != "f" {
+		t.Errorf("Unexpected feed items: %s", ids)
+	}
+}
+
+func TestFlowErrors(t *testing.T) {
+	good := &collector{}
+	failing := func(item base.Item) (base.Item, error) {
+		return nil, errors.New("disk full")
+	}
+	flow := ItemFlow{Routes: []Route{{
+		Name: "pages",
+		Stages: []Stage{{Name: "drop-empty", Processor: func(item base.Item) (base.Item, error) {
+			if item["id"] == nil {
+				return nil, ErrItemDropped
+			}
+			return item, nil
+		}}, {Name: "breaker", Processor: func(item base.Item) (base.Item, error) {
+			if item["id"] == "open" {
+				return nil, ErrCircuitOpen
+			}
+			return item, nil
+		}}},
+		Sinks: sinks(good.process, failing),
+	}}}
+	pipeline := newFlowPipeline(t, flow)
+	pipeline.SetFailFsat(true)
+	errs := pipeline.Send(base.Item{"id": "a"})
+	if len(errs) != 1 || errs[0].Error() != `The sink [1] of the route "pages" failed: disk full` {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if len(good.items) != 1 {
+		t.Errorf("A failing sink should not stop the others: %v", good.items)
+	}
+	if errs := pipeline.Send(base.Item{}); len(errs) != 0 || pipeline.Dropped() != 1 {
+		t.Errorf("A dropped item should stop the route silently: %v", errs)
+	}
+	// 原始的错误可以通过FlowError获得。
+	errs = pipeline.Send(base.Item{"id": "open"})
+	if len(errs) != 1 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	flowErr, ok := errs[0].(*FlowError)
+	if !ok || flowErr.Cause() != ErrCircuitOpen || !errors.Is(errs[0], ErrCircuitOpen) ||
+		flowErr.Location != `stage "breaker" of the route "pages"` {
+		t.Errorf("Unexpected error: %#v", errs[0])
+	}
+}
+
+func TestFlowSinkDeadLetters(t *testing.T) {
+	var broken int32 = 1
+	good := &collector{}
+	flaky := func(ctx context.Context, item base.Item) (base.Item, error) {
+		if atomic.LoadInt32(&broken) == 1 {
+			return nil, errors.New("disk full")
+		}
+		return good.process(item)
+	}
+	others := &collector{}
+	flow := ItemFlow{
+		Stages: []Stage{{Name: "mark", Processor: tag("marked")}},
+		Routes: []Route{{Sinks: []Stage{{Processor: others.process}, {Name: "flaky", ContextProcessor: flaky}}}},
+	}
+	pipeline := newFlowPipeline(t, flow)
+	store := &memoryDeadLetters{}
+	pipeline.SetFailFsat(true)
+	pipeline.SetDeadLetterStore(store)
+	pipeline.Send(base.Item{"id": "a"})
+	letters := store.all()
+	if len(letters) != 1 || letters[0].Stage != 2 || letters[0].Item["marked"] != true {
+		t.Fatalf("Only the failing sink should get a dead letter with the processed item: %v", letters)
+	}
+	// 重放时条目只会被交给出错的接收者。
+	atomic.StoreInt32(&broken, 0)
+	if errs := pipeline.SendDeadLetter(letters[0]); len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if len(others.items) != 1 || len(good.items) != 1 || good.items[0]["id"] != "a" {
+		t.Errorf("Unexpected outcome: others=%v, flaky=%v", others.items, good.items)
+	}
+	// 其他死信中的条目会经过整个处理流程。
+	pipeline.SendDeadLetter(DeadLetter{Stage: 0, Item: base.Item{"id": "b"}})
+	if len(others.items) != 2 || len(good.items) != 2 || good.items[1]["marked"] != true {
+		t.Errorf("Unexpected outcome: others=%v, flaky=%v", others.items, good.items)
+	}
+}
+
+func TestFlowCheck(t *testing.T) {
+	sink := func(item base.Item) (base.Item, error) { return item, nil }
+	sinkStages := sinks(sink)
+	contextProcessor := func(ctx context.Context, item base.Item) (base.Item, error) { return item, nil }
+	invalid := []ItemFlow{
+		{},
+		{Stages: []Stage{{}}},
+		{Stages: []Stage{{Processor: sink, ContextProcessor: contextProcessor}}},
+		{Routes: []Route{{Types: []string{"a"}}}},
+		{Routes: []Route{{Sinks: sinkStages}, {Sinks: sinkStages}}},
+		{Routes: []Route{{Types: []string{"a"}, Sinks: sinkStages}, {Types: []string{"a"}, Sinks: sinkStages}}},
+		{Routes: []Route{{Types: []string{"a"}, Sinks: []Stage{{}}}}},
+	}
+	for i, flow := range invalid {
+		if _, err := NewFlowItempipeline(flow, nil); err == nil {
+			t.Errorf("The flow[%d] should be invalid", i)
+		}
+	}
+	linear, err := NewFlowItempipeline(LinearFlow(sink, sink), nil)
+	if err != nil || len(linear.ProcessorStats()) != 2 {
+		t.Errorf("Unexpected linear flow: err=%v", err)
+	}
+}
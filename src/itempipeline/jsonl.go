@@ -0,0 +1,41 @@
+package itempipeline
+
+import (
+	"base"
+	"encoding/json"
+	"io"
+)
+
+// 创建JSON Lines格式的条目存储器。每个条目占一行，字段按名称排序。
+func NewJSONLinesSink(args SinkArgs) (ItemSink, error) {
+	return newSink(args, encoderSpec{
+		extension: "jsonl",
+		newEncoder: func() itemEncoder {
+			return &jsonLinesEncoder{}
+		},
+	})
+}
+
+// JSON Lines格式的条目编码器。
+type jsonLinesEncoder struct{}
+
+func (encoder *jsonLinesEncoder) begin(w io.Writer) error {
+	return nil
+}
+
+func (encoder *jsonLinesEncoder) encode(w io.Writer, item base.Item) error {
+	line, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+func (encoder *jsonLinesEncoder) flush(w io.Writer) error {
+	return nil
+}
+
+func (encoder *jsonLinesEncoder) end(w io.Writer) error {
+	return nil
+}
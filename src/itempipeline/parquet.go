@@ -0,0 +1,493 @@
+package itempipeline
+
+import (
+	"base"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"math"
+	"strconv"
+)
+
+// 每个行组最多包含的行数。
+const PARQUET_ROW_GROUP_SIZE = 10000
+
+// Parquet文件的魔数。
+const parquetMagic = "PAR1"
+
+// Parquet格式中的常量。
+const (
+	parquetBoolean   int32 = 0 // 物理类型BOOLEAN。
+	parquetInt64     int32 = 2 // 物理类型INT64。
+	parquetDouble    int32 = 5 // 物理类型DOUBLE。
+	parquetByteArray int32 = 6 // 物理类型BYTE_ARRAY。
+	parquetOptional  int32 = 1 // 重复类型OPTIONAL。
+	parquetUtf8      int32 = 0 // 逻辑类型UTF8。
+	parquetDataPage  int32 = 0 // 页类型DATA_PAGE。
+	parquetPlain     int32 = 0 // 编码PLAIN。
+	parquetRle       int32 = 3 // 编码RLE。
+)
+
+// 压缩方式与Parquet压缩编码的对应关系。
+var parquetCodecs = map[string]int32{
+	COMPRESSION_NONE: 0,
+	COMPRESSION_GZIP: 2,
+	COMPRESSION_ZSTD: 6,
+}
+
+// 列的类型与Parquet物理类型的对应关系。
+var parquetTypes = map[string]int32{
+	COLUMN_STRING:  parquetByteArray,
+	COLUMN_INT64:   parquetInt64,
+	COLUMN_DOUBLE:  parquetDouble,
+	COLUMN_BOOLEAN: parquetBoolean,
+}
+
+// 创建Parquet格式的条目存储器。
+// 参数columns决定了列、列的类型及其顺序，条目中不在其中的字段会被忽略，缺少的字段为空值。
+// 若columns为空，则依据第一个被写入的条目推断。所有的列都是可为空的，无法转换为列的类型的条目会写入失败。
+// 压缩在列块内部进行，因此文件本身不会被再次压缩。条目在内存中按行组缓存，Flush会写出当前的行组。
+func NewParquetSink(args SinkArgs, columns []Column) (ItemSink, error) {
+	if err := checkColumns(columns); err != nil {
+		return nil, err
+	}
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	codec := parquetCodecs[args.Compression]
+	schema := &columnSchema{columns: columns}
+	return newSink(args, encoderSpec{
+		extension:      "parquet",
+		selfCompressed: true,
+		newEncoder: func() itemEncoder {
+			return &parquetEncoder{schema: schema, codec: codec}
+		},
+	})
+}
+
+// 列块的元数据。
+type parquetChunk struct {
+	offset       int64 // 列块在文件中的偏移量。
+	values       int64 // 值的数量（包括空值）。
+	uncompressed int64 // 未压缩的尺寸。
+	compressed   int64 // 压缩后的尺寸。
+}
+
+// 行组的元数据。
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+}
+
+// Parquet格式的条目编码器。
+type parquetEncoder struct {
+	schema    *columnSchema     // 列的列表。
+	codec     int32             // 压缩编码。
+	columns   []Column          // 本文件使用的列。
+	values    [][]interface{}   // 当前行组中各列的值。空值为nil。
+	rows      int               // 当前行组的行数。
+	rowGroups []parquetRowGroup // 已写出的行组。
+	offset    int64             // 已写入的字节数。
+}
+
+func (encoder *parquetEncoder) write(w io.Writer, p []byte) error {
+	n, err := w.Write(p)
+	encoder.offset += int64(n)
+	return err
+}
+
+func (encoder *parquetEncoder) begin(w io.Writer) error {
+	return encoder.write(w, []byte(parquetMagic))
+}
+
+func (encoder *parquetEncoder) encode(w io.Writer, item base.Item) error {
+	if encoder.columns == nil {
+		encoder.columns = encoder.schema.resolve(item)
+		encoder.values = make([][]interface{}, len(encoder.columns))
+	}
+	row := make([]interface{}, len(encoder.columns))
+	for i, column := range encoder.columns {
+		value, err := convertValue(item[column.Name], column.Type)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid value of column %q: %s", column.Name, err))
+		}
+		row[i] = value
+	}
+	for i, value := range row {
+		encoder.values[i] = append(encoder.values[i], value)
+	}
+	encoder.rows++
+	if encoder.rows >= PARQUET_ROW_GROUP_SIZE {
+		return encoder.flush(w)
+	}
+	return nil
+}
+
+// 写出当前的行组。
+func (encoder *parquetEncoder) flush(w io.Writer) error {
+	if encoder.rows == 0 {
+		return nil
+	}
+	rowGroup := parquetRowGroup{rows: int64(encoder.rows)}
+	for i, column := range encoder.columns {
+		chunk, err := encoder.writeChunk(w, column, encoder.values[i])
+		if err != nil {
+			return err
+		}
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+		encoder.values[i] = nil
+	}
+	encoder.rowGroups = append(encoder.rowGroups, rowGroup)
+	encoder.rows = 0
+	return nil
+}
+
+// 把一列的值作为只有一个数据页的列块写出。
+func (encoder *parquetEncoder) writeChunk(w io.Writer, column Column, values []interface{}) (parquetChunk, error) {
+	chunk := parquetChunk{offset: encoder.offset, values: int64(len(values))}
+	var page bytes.Buffer
+	levels := encodeLevels(values)
+	binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+	page.Write(levels)
+	encodePlain(&page, values)
+	data, err := compress(encoder.codec, page.Bytes())
+	if err != nil {
+		return chunk, err
+	}
+	tw := newThriftWriter()
+	tw.structBegin()
+	tw.i32Field(1, parquetDataPage)
+	tw.i32Field(2, int32(page.Len()))
+	tw.i32Field(3, int32(len(data)))
+	tw.structField(5)
+	tw.i32Field(1, int32(len(values)))
+	tw.i32Field(2, parquetPlain)
+	tw.i32Field(3, parquetRle)
+	tw.i32Field(4, parquetRle)
+	tw.structEnd()
+	tw.structEnd()
+	header := tw.Bytes()
+	chunk.uncompressed = int64(len(header) + page.Len())
+	chunk.compressed = int64(len(header) + len(data))
+	if err := encoder.write(w, header); err != nil {
+		return chunk, err
+	}
+	return chunk, encoder.write(w, data)
+}
+
+func (encoder *parquetEncoder) end(w io.Writer) error {
+	if err := encoder.flush(w); err != nil {
+		return err
+	}
+	var totalRows int64
+	for _, rowGroup := range encoder.rowGroups {
+		totalRows += rowGroup.rows
+	}
+	tw := newThriftWriter()
+	tw.structBegin()
+	tw.i32Field(1, 1)
+	tw.listField(2, thriftStruct, len(encoder.columns)+1)
+	tw.structBegin()
+	tw.binaryField(4, "schema")
+	tw.i32Field(5, int32(len(encoder.columns)))
+	tw.structEnd()
+	for _, column := range encoder.columns {
+		tw.structBegin()
+		tw.i32Field(1, parquetTypes[columnType(column)])
+		tw.i32Field(3, parquetOptional)
+		tw.binaryField(4, column.Name)
+		if columnType(column) == COLUMN_STRING {
+			tw.i32Field(6, parquetUtf8)
+		}
+		tw.structEnd()
+	}
+	tw.i64Field(3, totalRows)
+	tw.listField(4, thriftStruct, len(encoder.rowGroups))
+	for _, rowGroup := range encoder.rowGroups {
+		var totalSize int64
+		tw.structBegin()
+		tw.listField(1, thriftStruct, len(rowGroup.chunks))
+		for i, chunk := range rowGroup.chunks {
+			column := encoder.columns[i]
+			totalSize += chunk.uncompressed
+			tw.structBegin()
+			tw.i64Field(2, chunk.offset)
+			tw.structField(3)
+			tw.i32Field(1, parquetTypes[columnType(column)])
+			tw.listField(2, thriftI32, 2)
+			tw.i32(parquetPlain)
+			tw.i32(parquetRle)
+			tw.listField(3, thriftBinary, 1)
+			tw.binary(column.Name)
+			tw.i32Field(4, encoder.codec)
+			tw.i64Field(5, chunk.values)
+			tw.i64Field(6, chunk.uncompressed)
+			tw.i64Field(7, chunk.compressed)
+			tw.i64Field(9, chunk.offset)
+			tw.structEnd()
+			tw.structEnd()
+		}
+		tw.i64Field(2, totalSize)
+		tw.i64Field(3, rowGroup.rows)
+		tw.structEnd()
+	}
+	tw.binaryField(6, "webcrawler itempipeline")
+	tw.structEnd()
+	footer := tw.Bytes()
+	if err := encoder.write(w, footer); err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	if err := encoder.write(w, length[:]); err != nil {
+		return err
+	}
+	return encoder.write(w, []byte(parquetMagic))
+}
+
+// 获得列的类型。
+func columnType(column Column) string {
+	if column.Type == "" {
+		return COLUMN_STRING
+	}
+	return column.Type
+}
+
+// 把字段的值转换为列的类型所对应的Go类型（string、int64、float64或bool）。
+func convertValue(value interface{}, typ string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch typ {
+	case COLUMN_INT64:
+		switch v := value.(type) {
+		case int:
+			return int64(v), nil
+		case int8:
+			return int64(v), nil
+		case int16:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case uint:
+			return convertValue(uint64(v), typ)
+		case uint8:
+			return int64(v), nil
+		case uint16:
+			return int64(v), nil
+		case uint32:
+			return int64(v), nil
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, errors.New("integer overflow")
+			}
+			return int64(v), nil
+		case float32:
+			return convertValue(float64(v), typ)
+		case float64:
+			if v != math.Trunc(v) || math.Abs(v) > math.MaxInt64 {
+				return nil, errors.New(fmt.Sprintf("%v is not an integer", v))
+			}
+			return int64(v), nil
+		case json.Number:
+			return v.Int64()
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case COLUMN_DOUBLE:
+		switch v := value.(type) {
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case json.Number:
+			return v.Float64()
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+		if i, err := convertValue(value, COLUMN_INT64); err == nil {
+			return float64(i.(int64)), nil
+		}
+	case COLUMN_BOOLEAN:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	default:
+		return formatValue(value)
+	}
+	return nil, errors.New(fmt.Sprintf("can not convert %T to %s", value, typ))
+}
+
+// 以RLE方式编码定义级别（位宽为1）。非空值的定义级别为1，空值为0。
+func encodeLevels(values []interface{}) []byte {
+	var buffer bytes.Buffer
+	var varint [binary.MaxVarintLen64]byte
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && (values[j] == nil) == (values[i] == nil) {
+			j++
+		}
+		n := binary.PutUvarint(varint[:], uint64(j-i)<<1)
+		buffer.Write(varint[:n])
+		if values[i] == nil {
+			buffer.WriteByte(0)
+		} else {
+			buffer.WriteByte(1)
+		}
+		i = j
+	}
+	return buffer.Bytes()
+}
+
+// 以PLAIN方式编码非空值。
+func encodePlain(buffer *bytes.Buffer, values []interface{}) {
+	var bits []byte
+	var count int
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			binary.Write(buffer, binary.LittleEndian, uint32(len(v)))
+			buffer.WriteString(v)
+		case int64:
+			binary.Write(buffer, binary.LittleEndian, v)
+		case float64:
+			binary.Write(buffer, binary.LittleEndian, math.Float64bits(v))
+		case bool:
+			if count%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v {
+				bits[count/8] |= 1 << uint(count%8)
+			}
+			count++
+		}
+	}
+	buffer.Write(bits)
+}
+
+// 按照压缩编码压缩数据。
+func compress(codec int32, data []byte) ([]byte, error) {
+	switch codec {
+	case parquetCodecs[COMPRESSION_GZIP]:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	case parquetCodecs[COMPRESSION_ZSTD]:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(data, nil), nil
+	}
+	return data, nil
+}
+
+// Thrift紧凑协议中的类型。
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// 按照Thrift紧凑协议编码Parquet元数据的写入器。
+type thriftWriter struct {
+	bytes.Buffer
+	lastId  int16   // 当前结构中上一个字段的序号。
+	lastIds []int16 // 外层结构中上一个字段的序号。
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{}
+}
+
+func (tw *thriftWriter) uvarint(v uint64) {
+	var varint [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(varint[:], v)
+	tw.Write(varint[:n])
+}
+
+func (tw *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - tw.lastId; delta > 0 && delta <= 15 {
+		tw.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		tw.WriteByte(typ)
+		tw.uvarint(uint64(uint16((id << 1) ^ (id >> 15))))
+	}
+	tw.lastId = id
+}
+
+func (tw *thriftWriter) i32(v int32) {
+	tw.uvarint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (tw *thriftWriter) binary(s string) {
+	tw.uvarint(uint64(len(s)))
+	tw.WriteString(s)
+}
+
+func (tw *thriftWriter) i32Field(id int16, v int32) {
+	tw.fieldHeader(id, thriftI32)
+	tw.i32(v)
+}
+
+func (tw *thriftWriter) i64Field(id int16, v int64) {
+	tw.fieldHeader(id, thriftI64)
+	tw.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (tw *thriftWriter) binaryField(id int16, s string) {
+	tw.fieldHeader(id, thriftBinary)
+	tw.binary(s)
+}
+
+// 写入列表类型的字段的头部。随后应依次写入各个元素。
+func (tw *thriftWriter) listField(id int16, elemType byte, size int) {
+	tw.fieldHeader(id, thriftList)
+	if size < 15 {
+		tw.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		tw.WriteByte(0xf0 | elemType)
+		tw.uvarint(uint64(size))
+	}
+}
+
+// 写入结构类型的字段的头部。随后应写入结构的各个字段并调用structEnd。
+func (tw *thriftWriter) structField(id int16) {
+	tw.fieldHeader(id, thriftStruct)
+	tw.structBegin()
+}
+
+// 开始一个结构（顶层结构或列表中的结构）。
+func (tw *thriftWriter) structBegin() {
+	tw.lastIds = append(tw.lastIds, tw.lastId)
+	tw.lastId = 0
+}
+
+// 结束当前的结构。
+func (tw *thriftWriter) structEnd() {
+	tw.WriteByte(0)
+	tw.lastId = tw.lastIds[len(tw.lastIds)-1]
+	tw.lastIds = tw.lastIds[:len(tw.lastIds)-1]
+}
@@ -0,0 +1,354 @@
+package itempipeline
+
+import (
+	"base"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// 是否重新生成testdata中的标准文件。
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// 按照Thrift紧凑协议解码的读取器，仅供测试使用。
+// 结构被解码为字段序号与值的映射，列表被解码为切片。
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (tr *thriftReader) byte() byte {
+	b := tr.data[tr.pos]
+	tr.pos++
+	return b
+}
+
+func (tr *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(tr.data[tr.pos:])
+	if n <= 0 {
+		panic("invalid varint")
+	}
+	tr.pos += n
+	return v
+}
+
+func (tr *thriftReader) zigzag() int64 {
+	v := tr.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (tr *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(tr.byte()))
+	case 4, 5, 6:
+		return tr.zigzag()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(tr.data[tr.pos:]))
+		tr.pos += 8
+		return v
+	case 8:
+		n := int(tr.uvarint())
+		s := string(tr.data[tr.pos : tr.pos+n])
+		tr.pos += n
+		return s
+	case 9:
+		header := tr.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(tr.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = tr.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return tr.structValue()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", typ))
+}
+
+func (tr *thriftReader) structValue() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var lastId int16
+	for {
+		header := tr.byte()
+		if header == 0 {
+			return fields
+		}
+		id := lastId + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(tr.zigzag())
+		}
+		fields[id] = tr.value(header & 0x0f)
+		lastId = id
+	}
+}
+
+// 读取Parquet文件中的所有行，仅支持写入器产生的由可为空的平坦列组成的文件。
+func readParquet(path string) (names []string, rows []base.Item, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.New(fmt.Sprint(p))
+		}
+	}()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		return nil, nil, errors.New("invalid magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-footerLength : len(data)-8]}
+	meta := footer.structValue()
+	schema := meta[2].([]interface{})
+	types := make([]int64, 0)
+	for _, element := range schema[1:] {
+		fields := element.(map[int16]interface{})
+		names = append(names, fields[4].(string))
+		types = append(types, fields[1].(int64))
+	}
+	for _, rg := range meta[4].([]interface{}) {
+		rowGroup := rg.(map[int16]interface{})
+		count := int(rowGroup[3].(int64))
+		groupRows := make([]base.Item, count)
+		for i := range groupRows {
+			groupRows[i] = base.Item{}
+		}
+		for c, ch := range rowGroup[1].([]interface{}) {
+			chunk := ch.(map[int16]interface{})[3].(map[int16]interface{})
+			offset := int(chunk[9].(int64))
+			values, err := readColumnChunk(data, offset, chunk[4].(int64), types[c])
+			if err != nil {
+				return nil, nil, err
+			}
+			for i, value := range values {
+				groupRows[i][names[c]] = value
+			}
+		}
+		rows = append(rows, groupRows...)
+	}
+	if total := meta[3].(int64); int(total) != len(rows) {
+		return nil, nil, errors.New(fmt.Sprintf("%d rows are declared, but %d are read", total, len(rows)))
+	}
+	return names, rows, nil
+}
+
+// 读取只有一个数据页的列块。
+func readColumnChunk(data []byte, offset int, codec int64, typ int64) ([]interface{}, error) {
+	tr := &thriftReader{data: data, pos: offset}
+	header := tr.structValue()
+	compressedSize := int(header[3].(int64))
+	page := data[tr.pos : tr.pos+compressedSize]
+	switch int32(codec) {
+	case parquetCodecs[COMPRESSION_GZIP]:
+		reader, err := gzip.NewReader(bytes.NewReader(page))
+		if err != nil {
+			return nil, err
+		}
+		if page, err = ioutil.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	case parquetCodecs[COMPRESSION_ZSTD]:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		if page, err = decoder.DecodeAll(page, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(page) != int(header[2].(int64)) {
+		return nil, errors.New("unexpected uncompressed page size")
+	}
+	count := int(header[5].(map[int16]interface{})[1].(int64))
+	levelsLength := int(binary.LittleEndian.Uint32(page))
+	levels := &thriftReader{data: page[4 : 4+levelsLength]}
+	defined := make([]bool, 0, count)
+	for levels.pos < len(levels.data) {
+		runHeader := levels.uvarint()
+		if runHeader&1 == 1 {
+			return nil, errors.New("bit-packed levels are not supported")
+		}
+		value := levels.byte() == 1
+		for i := uint64(0); i < runHeader>>1; i++ {
+			defined = append(defined, value)
+		}
+	}
+	if len(defined) != count {
+		return nil, errors.New("unexpected number of levels")
+	}
+	plain := page[4+levelsLength:]
+	pos, bit := 0, 0
+	values := make([]interface{}, count)
+	for i := range values {
+		if !defined[i] {
+			continue
+		}
+		switch int32(typ) {
+		case parquetByteArray:
+			n := int(binary.LittleEndian.Uint32(plain[pos:]))
+			values[i] = string(plain[pos+4 : pos+4+n])
+			pos += 4 + n
+		case parquetInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(plain[pos:]))
+			pos += 8
+		case parquetDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(plain[pos:]))
+			pos += 8
+		case parquetBoolean:
+			values[i] = plain[bit/8]&(1<<uint(bit%8)) != 0
+			bit++
+		}
+	}
+	return values, nil
+}
+
+func TestParquetSinkRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "url", Type: COLUMN_STRING},
+		{Name: "size", Type: COLUMN_INT64},
+		{Name: "score", Type: COLUMN_DOUBLE},
+		{Name: "ok", Type: COLUMN_BOOLEAN},
+	}
+	items := []base.Item{
+		{"url": "http://a", "size": 1, "score": 0.5, "ok": true, "ignored": "x"},
+		{"url": "http://b", "size": "2", "ok": false},
+		{"url": "http://c", "score": 3, "ok": "true"},
+		{"size": int64(-4), "score": -1.25},
+	}
+	expected := []base.Item{
+		{"url": "http://a", "size": int64(1), "score": 0.5, "ok": true},
+		{"url": "http://b", "size": int64(2), "score": nil, "ok": false},
+		{"url": "http://c", "size": nil, "score": 3.0, "ok": true},
+		{"url": nil, "size": int64(-4), "score": -1.25, "ok": nil},
+	}
+	for _, compression := range []string{COMPRESSION_NONE, COMPRESSION_GZIP, COMPRESSION_ZSTD} {
+		t.Run(fmt.Sprintf("compression=%q", compression), func(t *testing.T) {
+			dir := t.TempDir()
+			sink, err := NewParquetSink(SinkArgs{Path: filepath.Join(dir, "items"), Compression: compression}, columns)
+			if err != nil {
+				t.Fatalf("Can not create the sink: %s", err)
+			}
+			for _, item := range items {
+				if _, err := sink.Process(item); err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+			}
+			if _, err := sink.Process(base.Item{"size": "not a number"}); err == nil {
+				t.Errorf("An inconvertible value should be rejected")
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			files, _ := filepath.Glob(filepath.Join(dir, "items-*.parquet"))
+			if len(files) != 1 {
+				t.Fatalf("Unexpected files: %v", files)
+			}
+			names, rows, err := readParquet(files[0])
+			if err != nil {
+				t.Fatalf("Can not read the parquet file: %s", err)
+			}
+			if !reflect.DeepEqual(names, []string{"url", "size", "score", "ok"}) {
+				t.Errorf("Unexpected columns: %v", names)
+			}
+			if !reflect.DeepEqual(rows, expected) {
+				t.Errorf("Unexpected rows:\n%v\nexpected:\n%v", rows, expected)
+			}
+		})
+	}
+}
+
+func TestParquetSinkRowGroups(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewParquetSink(SinkArgs{Path: filepath.Join(dir, "items")}, nil)
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	total := PARQUET_ROW_GROUP_SIZE + 10
+	for i := 0; i < total; i++ {
+		sink.Process(base.Item{"i": i, "even": i%2 == 0})
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "items-*.parquet"))
+	names, rows, err := readParquet(files[0])
+	if err != nil {
+		t.Fatalf("Can not read the parquet file: %s", err)
+	}
+	if !reflect.DeepEqual(names, []string{"even", "i"}) || len(rows) != total {
+		t.Fatalf("Unexpected result: columns=%v, rows=%d", names, len(rows))
+	}
+	for _, i := range []int{0, 1, PARQUET_ROW_GROUP_SIZE - 1, PARQUET_ROW_GROUP_SIZE, total - 1} {
+		if rows[i]["i"] != int64(i) || rows[i]["even"] != (i%2 == 0) {
+			t.Errorf("Unexpected row %d: %v", i, rows[i])
+		}
+	}
+}
+
+// 写入标准文件中的条目，覆盖了各种列的类型以及空值。
+func writeGoldenParquet(t *testing.T, path string, compression string) string {
+	columns := []Column{
+		{Name: "url", Type: COLUMN_STRING},
+		{Name: "size", Type: COLUMN_INT64},
+		{Name: "score", Type: COLUMN_DOUBLE},
+		{Name: "ok", Type: COLUMN_BOOLEAN},
+	}
+	sink, err := NewParquetSink(SinkArgs{Path: path, Compression: compression}, columns)
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	sink.Process(base.Item{"url": "http://example.com/", "size": 1024, "score": 0.5, "ok": true})
+	sink.Process(base.Item{"url": "http://example.com/中文", "size": -1, "ok": false})
+	sink.Process(base.Item{"score": 1e10, "ok": true})
+	sink.Process(base.Item{"url": "", "size": int64(1) << 62})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	files, _ := filepath.Glob(path + "-*.parquet")
+	if len(files) != 1 {
+		t.Fatalf("Unexpected files: %v", files)
+	}
+	return files[0]
+}
+
+// 文件名中带有时间，但文件的内容（包括尾部的元数据）不含时间等可变的信息，因此可以逐字节地比较。
+// testdata中的标准文件已用独立的实现（github.com/xitongsys/parquet-go v1.6.2的ParquetColumnReader）
+// 读取并核对过各列的值与定义级别，以-update重新生成后需要再次核对。
+func TestParquetSinkGolden(t *testing.T) {
+	golden := filepath.Join("testdata", "items.parquet")
+	name := writeGoldenParquet(t, filepath.Join(t.TempDir(), "items"), COMPRESSION_NONE)
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("Can not read the file: %s", err)
+	}
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, data, 0644); err != nil {
+			t.Fatalf("Can not write the golden file: %s", err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Can not read the golden file: %s", err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("The file differs from %s:\n%x\nexpected:\n%x", golden, data, expected)
+	}
+}
@@ -2,52 +2,236 @@ package itempipeline
 
 import (
 	"base"
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_WORKER_NUMBER uint32 = 8   //默认的工作者数量
+	DEFAULT_QUEUE_LEN     uint32 = 100 //默认的条目队列长度
 )
 
 type Itempipeline interface {
 	//发送条目
 	Send(item base.Item) []error
+	//使用给定的上下文发送条目。管道被取消时不会影响该上下文
+	SendContext(ctx context.Context, item base.Item) []error
 	//条目是否快速失败 ，快速失败指处理流程出错，如果出错则忽略后续操作
 	FailFast() bool
 	//设置是否快速失败
 	SetFailFsat(failRast bool)
 	//获得已发送、已接受、已处理的条目计数值
 	Count() []uint64
+	//获得被条目处理器丢弃（见ErrItemDropped）的条目数量
+	Dropped() uint64
+	//获得各个条目处理器的调用次数、出错次数和耗时，顺序与条目处理器的序列一致
+	ProcessorStats() []ProcessorStats
 	//获取正在被处理的条目总数
 	ProcessingNumber() uint64
 	//获取摘要信息
 	Summary() string
+	//启动固定数量的工作者，由它们处理经Submit提交的条目。参数为0时使用默认值
+	//参数errorHandler会收到处理条目时产生的每个错误，它会被多个工作者并发地调用
+	//需要关闭的组件中实现了ErrorReporter接口的组件（如批量处理器）报告的错误也会被转交给该函数
+	StartWorkers(workerNumber uint32, queueLen uint32, errorHandler func(err error)) error
+	//提交条目。队列已满时会阻塞，直到有工作者取走条目，以此对上游施加背压
+	Submit(item base.Item) error
+	//获得队列中等待处理的条目数量和队列的容量
+	QueueLength() (length uint32, capacity uint32)
+	//获得工作者的总数和正在处理条目的工作者数量
+	Workers() (total uint32, busy uint32)
+	//获得自工作者启动以来工作者的利用率，即处理条目的时间占总时间的比例
+	Utilization() float64
+	//设置死信存储器。处理失败的条目会连同出错的条目处理器的序号和错误信息一起被存入其中
+	//需要关闭的组件以*BatchItemError报告的失败条目也会被存入其中，其序号为CLOSER_STAGE
+	//死信存储器会在条目处理管道关闭时被关闭
+	SetDeadLetterStore(store DeadLetterStore)
+	//重新处理死信中的条目。由条目处理流程中的接收者产生的死信中的条目只会被交给该接收者，
+	//其他死信中的条目（即原始条目）会经过所有的条目处理器。序号为CLOSER_STAGE的死信不能由此重新处理
+	SendDeadLetter(letter DeadLetter) []error
+	//获得死信存储器。未设置时结果值为nil
+	DeadLetterStore() DeadLetterStore
+	//关闭条目处理管道。它会等待队列中和正在被处理的条目处理完毕，然后依次关闭各个需要关闭的组件（如条目存储器）。
+	//关闭后发送或提交的条目会被拒绝
+	Close() []error
+	//取消条目处理管道的上下文。正在被处理的条目会被取消，队列中剩余的条目也不会再被条目处理器处理，
+	//它们都会被视为处理失败并被存入死信存储器（若已设置）
+	Cancel()
+	//在时限内排空并关闭条目处理管道。超过时限后管道会被取消，以免在关闭时无限期地等待。参数不大于0时会立即取消
+	CloseWithin(timeout time.Duration) []error
 }
 
 type myItemPipeline struct {
-	itemProcessors   []ProcessItem //条目处理器的列表
-	failFast         bool          //表示处理是否需要快速失败的标志位
-	sent             uint64        //已被发送的条目的数量
-	accepted         uint64        //已被接受的条目的数量
-	processed        uint64        //已被处理的条目的数量
-	processingNumber uint64        //正在被处理的数量
+	itemProcessors   []ProcessItemContext //条目处理器的列表
+	steps            []flowStep           //各个条目处理器在条目处理流程中的位置
+	router           *itemRouter          //条目路由器。没有路由时为nil
+	failFast         bool                 //表示处理是否需要快速失败的标志位
+	sent             uint64               //已被发送的条目的数量
+	accepted         uint64               //已被接受的条目的数量
+	processed        uint64               //已被处理的条目的数量
+	processingNumber uint64               //正在被处理的数量
+	closers          []Closer             //需要在关闭时被关闭的组件的列表
+	closed           bool                 //是否已被关闭
+	closeLock        sync.RWMutex         //针对关闭的读写锁。发送条目时持有读锁，关闭时持有写锁
+	queue            chan base.Item       //等待处理的条目的队列。工作者未启动时为nil
+	workerNumber     uint32               //工作者的数量
+	busyWorkers      uint32               //正在处理条目的工作者的数量
+	busyNanos        int64                //工作者处理条目的累计时间（纳秒）
+	startTime        time.Time            //工作者的启动时间
+	workerWaitGroup  sync.WaitGroup       //针对工作者的等待组
+	workerLock       sync.RWMutex         //针对队列及工作者数量等参数的读写锁
+	deadLetterStore  DeadLetterStore      //死信存储器
+	dropped          uint64               //被条目处理器丢弃的条目的数量
+	processorStats   []processorStat      //各个条目处理器的统计数据
+	ctx              context.Context      //条目处理管道的上下文
+	cancel           context.CancelFunc   //取消上下文的函数
+	errorHandler     func(err error)      //工作者的错误处理函数，需要关闭的组件报告的错误也会被转交给它
+}
+
+// 条目处理器的统计数据。
+type ProcessorStats struct {
+	Name         string        // 名称，如`sink [0] of the route "link"`。只有由条目处理流程创建的管道才有。
+	Calls        uint64        // 调用的次数。
+	Errors       uint64        // 出错的次数。丢弃条目不算出错。
+	TotalLatency time.Duration // 累计的耗时。
+	MaxLatency   time.Duration // 最长的耗时。
+}
+
+// 平均的耗时。
+func (stats ProcessorStats) AvgLatency() time.Duration {
+	if stats.Calls == 0 {
+		return 0
+	}
+	return stats.TotalLatency / time.Duration(stats.Calls)
+}
+
+// 条目处理器的统计数据的内部表示，其字段都以原子操作读写。
+type processorStat struct {
+	calls    uint64 //调用的次数
+	errors   uint64 //出错的次数
+	nanos    int64  //累计的耗时（纳秒）
+	maxNanos int64  //最长的耗时（纳秒）
+}
+
+// 记录一次调用。
+func (stat *processorStat) record(elapsed time.Duration, err error) {
+	atomic.AddUint64(&stat.calls, 1)
+	if err != nil && err != ErrItemDropped {
+		atomic.AddUint64(&stat.errors, 1)
+	}
+	atomic.AddInt64(&stat.nanos, int64(elapsed))
+	for {
+		max := atomic.LoadInt64(&stat.maxNanos)
+		if int64(elapsed) <= max || atomic.CompareAndSwapInt64(&stat.maxNanos, max, int64(elapsed)) {
+			return
+		}
+	}
 }
 
 func NewItempipeline(itemProcessors []ProcessItem) Itempipeline {
+	return NewItempipelineWithClosers(itemProcessors, nil)
+}
+
+// 创建带有需要在关闭时被关闭的组件的条目处理管道。
+func NewItempipelineWithClosers(itemProcessors []ProcessItem, closers []Closer) Itempipeline {
 	if itemProcessors == nil {
 		panic(errors.New(fmt.Sprintln("Invalid item processor list")))
 	}
-	innerItemProcessors := make([]ProcessItem, 0)
+	contextProcessors := make([]ProcessItemContext, 0)
+	for i, ip := range itemProcessors {
+		if ip == nil {
+			panic(errors.New(fmt.Sprintf("Invalid item processor[%d]!\n", i)))
+		}
+		contextProcessors = append(contextProcessors, AdaptProcessor(ip))
+	}
+	return NewContextItempipeline(contextProcessors, closers)
+}
+
+// 创建由可被取消的条目处理器组成的、带有需要在关闭时被关闭的组件的条目处理管道。
+func NewContextItempipeline(itemProcessors []ProcessItemContext, closers []Closer) Itempipeline {
+	return newItemPipeline(itemProcessors, closers)
+}
+
+// 创建条目处理管道。各个条目处理器都被视为公共处理阶段
+func newItemPipeline(itemProcessors []ProcessItemContext, closers []Closer) *myItemPipeline {
+	if itemProcessors == nil {
+		panic(errors.New(fmt.Sprintln("Invalid item processor list")))
+	}
+	innerItemProcessors := make([]ProcessItemContext, 0)
 	for i, ip := range itemProcessors {
 		if ip == nil {
 			panic(errors.New(fmt.Sprintf("Invalid item processor[%d]!\n", i)))
 		}
 		innerItemProcessors = append(innerItemProcessors, ip)
 	}
-	return &myItemPipeline{itemProcessors: innerItemProcessors}
+	innerClosers := make([]Closer, 0)
+	for i, closer := range closers {
+		if closer == nil {
+			panic(errors.New(fmt.Sprintf("Invalid closer[%d]!\n", i)))
+		}
+		innerClosers = append(innerClosers, closer)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	steps := make([]flowStep, len(innerItemProcessors))
+	for i := range steps {
+		steps[i].route = -1
+	}
+	ip := &myItemPipeline{
+		itemProcessors: innerItemProcessors,
+		steps:          steps,
+		closers:        innerClosers,
+		processorStats: make([]processorStat, len(innerItemProcessors)),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+	//即使工作者未被启动，需要关闭的组件报告的失败条目也要被存入死信存储器
+	for _, closer := range innerClosers {
+		if reporter, ok := closer.(ErrorReporter); ok {
+			reporter.SetErrorHandler(ip.handleCloserError)
+		}
+	}
+	return ip
+}
+
+// 处理需要关闭的组件报告的错误。失败的条目会被存入死信存储器，错误会被转交给工作者的错误处理函数
+func (ip *myItemPipeline) handleCloserError(err error) {
+	ip.workerLock.RLock()
+	store, errorHandler := ip.deadLetterStore, ip.errorHandler
+	ip.workerLock.RUnlock()
+	if itemErr, ok := err.(*BatchItemError); ok && store != nil {
+		letter := DeadLetter{Time: time.Now(), Stage: CLOSER_STAGE, Error: itemErr.Err.Error(), Item: itemErr.Item}
+		if putErr := store.Put(letter); putErr != nil && errorHandler != nil {
+			errorHandler(errors.New(fmt.Sprintf("Dead letter error: %s", putErr)))
+		}
+	}
+	if errorHandler != nil {
+		errorHandler(err)
+	}
 }
 
 func (ip *myItemPipeline) Send(item base.Item) []error {
+	return ip.SendContext(ip.ctx, item)
+}
+
+func (ip *myItemPipeline) SendContext(ctx context.Context, item base.Item) []error {
 	atomic.AddUint64(&ip.processingNumber, 1)
-	defer atomic.AddUint64(&ip.processingNumber, ^uint64(1))
+	defer atomic.AddUint64(&ip.processingNumber, ^uint64(0))
+	ip.closeLock.RLock()
+	defer ip.closeLock.RUnlock()
+	if ip.closed {
+		atomic.AddUint64(&ip.sent, 1)
+		return []error{errors.New("The item pipeline has been closed")}
+	}
+	return ip.send(ctx, item)
+}
+
+// 依次用各个条目处理器处理条目
+func (ip *myItemPipeline) send(ctx context.Context, item base.Item) []error {
 	atomic.AddUint64(&ip.sent, 1)
 	errs := make([]error, 0)
 	if item == nil {
@@ -55,12 +239,65 @@ func (ip *myItemPipeline) Send(item base.Item) []error {
 		return errs
 	}
 	atomic.AddUint64(&ip.accepted, 1)
+	store := ip.DeadLetterStore()
+	var original base.Item
+	if store != nil {
+		//条目处理器可能会直接修改条目，因此需要先复制原始条目
+		original = make(base.Item, len(item))
+		for k, v := range item {
+			original[k] = v
+		}
+	}
 	var currentItem base.Item = item
-	for _, itemProcessor := range ip.itemProcessors {
-		processedItem, err := itemProcessor(currentItem)
+	route, routed := -1, false
+	for i := 0; i < len(ip.itemProcessors); i++ {
+		step := ip.steps[i]
+		//条目所属的路由在条目到达第一个路由中的条目处理器时确定，此后其他路由中的条目处理器都会被跳过
+		if step.route >= 0 {
+			if !routed {
+				route, routed = ip.router.match(currentItem), true
+			}
+			if step.route != route {
+				continue
+			}
+		}
+		//同一路由的接收者是连续的，它们会被一起并行地调用。已有死信时不再为接收者单独记录死信
+		if step.sink {
+			last := i
+			for last+1 < len(ip.steps) && ip.steps[last+1].sink && ip.steps[last+1].route == step.route {
+				last++
+			}
+			sinkStore := store
+			if original == nil {
+				sinkStore = nil
+			}
+			errs = append(errs, ip.fanOut(ctx, i, last, currentItem, sinkStore)...)
+			i = last
+			continue
+		}
+		//上下文被取消后，条目不再被处理，而是在当前的条目处理器处失败
+		var processedItem base.Item
+		err := ctx.Err()
+		if err == nil {
+			start := time.Now()
+			processedItem, err = callContextProcessor(ctx, ip.itemProcessors[i], currentItem)
+			ip.processorStats[i].record(time.Since(start), err)
+		}
+		if err == ErrItemDropped {
+			atomic.AddUint64(&ip.dropped, 1)
+			break
+		}
 		if err != nil {
 			errs = append(errs, err)
-			if ip.failFast {
+			//每个条目只记录第一个失败的条目处理器
+			if original != nil {
+				letter := DeadLetter{Time: time.Now(), Stage: i, Error: err.Error(), Item: original}
+				if err := store.Put(letter); err != nil {
+					errs = append(errs, errors.New(fmt.Sprintf("Dead letter error: %s", err)))
+				}
+				original = nil
+			}
+			if ip.failFast || ctx.Err() != nil {
 				break
 			}
 		}
@@ -72,6 +309,98 @@ func (ip *myItemPipeline) Send(item base.Item) []error {
 	return errs
 }
 
+// 并行地调用序号从first到last的接收者，每个接收者收到的都是条目的副本。
+// 某个接收者出错不影响其他的接收者。死信存储器不为nil时，出错的接收者会各自产生一个存放交给它的条目的死信。
+func (ip *myItemPipeline) fanOut(ctx context.Context, first int, last int, item base.Item, store DeadLetterStore) []error {
+	sinkErrs := make([]error, last-first+1)
+	var wg sync.WaitGroup
+	for i := first; i <= last; i++ {
+		copied := item
+		if last > first {
+			copied = copyItem(item)
+		}
+		var letterItem base.Item
+		if store != nil {
+			letterItem = copyItem(item)
+		}
+		wg.Add(1)
+		go func(i int, copied base.Item, letterItem base.Item) {
+			defer wg.Done()
+			err := ctx.Err()
+			if err == nil {
+				start := time.Now()
+				_, err = callContextProcessor(ctx, ip.itemProcessors[i], copied)
+				ip.processorStats[i].record(time.Since(start), err)
+			}
+			if err == nil || err == ErrItemDropped {
+				return
+			}
+			sinkErrs[i-first] = err
+			if store != nil {
+				letter := DeadLetter{Time: time.Now(), Stage: i, Error: err.Error(), Item: letterItem}
+				if putErr := store.Put(letter); putErr != nil {
+					sinkErrs[i-first] = errors.New(fmt.Sprintf("%s; Dead letter error: %s", err, putErr))
+				}
+			}
+		}(i, copied, letterItem)
+	}
+	wg.Wait()
+	errs := make([]error, 0)
+	for _, err := range sinkErrs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (ip *myItemPipeline) SendDeadLetter(letter DeadLetter) []error {
+	if letter.Stage < 0 || letter.Stage >= len(ip.steps) || !ip.steps[letter.Stage].sink {
+		return ip.Send(letter.Item)
+	}
+	atomic.AddUint64(&ip.processingNumber, 1)
+	defer atomic.AddUint64(&ip.processingNumber, ^uint64(0))
+	ip.closeLock.RLock()
+	defer ip.closeLock.RUnlock()
+	atomic.AddUint64(&ip.sent, 1)
+	if ip.closed {
+		return []error{errors.New("The item pipeline has been closed")}
+	}
+	if letter.Item == nil {
+		return []error{errors.New("The item is invalid")}
+	}
+	atomic.AddUint64(&ip.accepted, 1)
+	errs := ip.fanOut(ip.ctx, letter.Stage, letter.Stage, letter.Item, ip.DeadLetterStore())
+	atomic.AddUint64(&ip.processed, 1)
+	return errs
+}
+
+// 调用条目处理器，并把其引发的运行时恐慌转换为错误。
+func callProcessor(itemProcessor ProcessItem, item base.Item) (result base.Item, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			result = nil
+			err = errors.New(fmt.Sprintf("Fatal item processing error: %s", p))
+		}
+	}()
+	return itemProcessor(item)
+}
+
+// 调用可被取消的条目处理器，并把其引发的运行时恐慌转换为错误。
+func callContextProcessor(ctx context.Context, itemProcessor ProcessItemContext, item base.Item) (result base.Item, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			result = nil
+			err = errors.New(fmt.Sprintf("Fatal item processing error: %s", p))
+		}
+	}()
+	return itemProcessor(ctx, item)
+}
+
+func (ip *myItemPipeline) Dropped() uint64 {
+	return atomic.LoadUint64(&ip.dropped)
+}
+
 func (ip *myItemPipeline) FailFast() bool {
 	return ip.failFast
 }
@@ -88,17 +417,191 @@ func (ip *myItemPipeline) Count() []uint64 {
 	return counts
 }
 
+func (ip *myItemPipeline) ProcessorStats() []ProcessorStats {
+	stats := make([]ProcessorStats, len(ip.processorStats))
+	for i := range ip.processorStats {
+		stat := &ip.processorStats[i]
+		stats[i] = ProcessorStats{
+			Name:         ip.steps[i].name,
+			Calls:        atomic.LoadUint64(&stat.calls),
+			Errors:       atomic.LoadUint64(&stat.errors),
+			TotalLatency: time.Duration(atomic.LoadInt64(&stat.nanos)),
+			MaxLatency:   time.Duration(atomic.LoadInt64(&stat.maxNanos)),
+		}
+	}
+	return stats
+}
+
 func (ip *myItemPipeline) ProcessingNumber() uint64 {
 	return atomic.LoadUint64(&ip.processingNumber)
 }
 
+func (ip *myItemPipeline) StartWorkers(workerNumber uint32, queueLen uint32, errorHandler func(err error)) error {
+	ip.closeLock.Lock()
+	defer ip.closeLock.Unlock()
+	if ip.closed {
+		return errors.New("The item pipeline has been closed")
+	}
+	if ip.queue != nil {
+		return errors.New("The workers have been started")
+	}
+	if workerNumber == 0 {
+		workerNumber = DEFAULT_WORKER_NUMBER
+	}
+	if queueLen == 0 {
+		queueLen = DEFAULT_QUEUE_LEN
+	}
+	ip.workerLock.Lock()
+	ip.errorHandler = errorHandler
+	ip.queue = make(chan base.Item, queueLen)
+	ip.workerNumber = workerNumber
+	ip.startTime = time.Now()
+	ip.workerLock.Unlock()
+	ip.workerWaitGroup.Add(int(workerNumber))
+	for i := uint32(0); i < workerNumber; i++ {
+		go ip.work(ip.queue, errorHandler)
+	}
+	return nil
+}
+
+// 工作者。它会不断地从队列中取出条目并处理，直到队列被关闭
+func (ip *myItemPipeline) work(queue <-chan base.Item, errorHandler func(err error)) {
+	defer ip.workerWaitGroup.Done()
+	for item := range queue {
+		atomic.AddUint32(&ip.busyWorkers, 1)
+		start := time.Now()
+		errs := ip.send(ip.ctx, item)
+		atomic.AddInt64(&ip.busyNanos, int64(time.Since(start)))
+		atomic.AddUint32(&ip.busyWorkers, ^uint32(0))
+		atomic.AddUint64(&ip.processingNumber, ^uint64(0))
+		if errorHandler != nil {
+			for _, err := range errs {
+				errorHandler(err)
+			}
+		}
+	}
+}
+
+func (ip *myItemPipeline) Submit(item base.Item) error {
+	ip.closeLock.RLock()
+	defer ip.closeLock.RUnlock()
+	if ip.closed {
+		return errors.New("The item pipeline has been closed")
+	}
+	if ip.queue == nil {
+		return errors.New("The workers have not been started")
+	}
+	//在队列中等待的条目也被视为正在被处理的条目
+	atomic.AddUint64(&ip.processingNumber, 1)
+	ip.queue <- item
+	return nil
+}
+
+func (ip *myItemPipeline) QueueLength() (length uint32, capacity uint32) {
+	ip.workerLock.RLock()
+	queue := ip.queue
+	ip.workerLock.RUnlock()
+	return uint32(len(queue)), uint32(cap(queue))
+}
+
+func (ip *myItemPipeline) Workers() (total uint32, busy uint32) {
+	ip.workerLock.RLock()
+	total = ip.workerNumber
+	ip.workerLock.RUnlock()
+	return total, atomic.LoadUint32(&ip.busyWorkers)
+}
+
+func (ip *myItemPipeline) Utilization() float64 {
+	ip.workerLock.RLock()
+	total, startTime := ip.workerNumber, ip.startTime
+	ip.workerLock.RUnlock()
+	elapsed := time.Since(startTime)
+	if total == 0 || elapsed <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&ip.busyNanos)) / (float64(elapsed) * float64(total))
+}
+
+func (ip *myItemPipeline) SetDeadLetterStore(store DeadLetterStore) {
+	ip.workerLock.Lock()
+	defer ip.workerLock.Unlock()
+	ip.deadLetterStore = store
+}
+
+func (ip *myItemPipeline) DeadLetterStore() DeadLetterStore {
+	ip.workerLock.RLock()
+	defer ip.workerLock.RUnlock()
+	return ip.deadLetterStore
+}
+
+func (ip *myItemPipeline) Close() []error {
+	ip.closeLock.Lock()
+	defer ip.closeLock.Unlock()
+	if ip.closed {
+		return nil
+	}
+	ip.closed = true
+	if ip.queue != nil {
+		close(ip.queue)
+		ip.workerWaitGroup.Wait()
+	}
+	errs := make([]error, 0)
+	for _, closer := range ip.closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if store := ip.DeadLetterStore(); store != nil {
+		if err := store.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (ip *myItemPipeline) Cancel() {
+	ip.cancel()
+}
+
+func (ip *myItemPipeline) CloseWithin(timeout time.Duration) []error {
+	if timeout <= 0 {
+		ip.Cancel()
+	} else {
+		timer := time.AfterFunc(timeout, ip.Cancel)
+		defer timer.Stop()
+	}
+	return ip.Close()
+}
+
 var summaryTemplate = "failFast: %v, processorNumber: %d," +
-	" sent: %d, accepted: %d, processed: %d, processingNumber: %d"
+	" sent: %d, accepted: %d, processed: %d, dropped: %d, processingNumber: %d, canceled: %v," +
+	" queue: %d/%d, busyWorkers: %d/%d, utilization: %.2f, deadLetters: %d," +
+	" processors: [%s]"
+
+var processorStatsTemplate = "%s: { calls: %d, errors: %d, avgLatency: %s, maxLatency: %s }"
 
 func (ip *myItemPipeline) Summary() string {
 	counts := ip.Count()
+	queueLength, queueCap := ip.QueueLength()
+	total, busy := ip.Workers()
+	var deadLetters uint64
+	if store := ip.DeadLetterStore(); store != nil {
+		deadLetters = store.Count()
+	}
+	processorStats := ip.ProcessorStats()
+	processorSummaries := make([]string, len(processorStats))
+	for i, stats := range processorStats {
+		label := fmt.Sprintf("%d", i)
+		if stats.Name != "" {
+			label = fmt.Sprintf("%d (%s)", i, stats.Name)
+		}
+		processorSummaries[i] = fmt.Sprintf(processorStatsTemplate,
+			label, stats.Calls, stats.Errors, stats.AvgLatency(), stats.MaxLatency)
+	}
 	summary := fmt.Sprintf(summaryTemplate,
 		ip.failFast, len(ip.itemProcessors),
-		counts[0], counts[1], counts[2], ip.ProcessingNumber())
+		counts[0], counts[1], counts[2], ip.Dropped(), ip.ProcessingNumber(), ip.ctx.Err() != nil,
+		queueLength, queueCap, busy, total, ip.Utilization(), deadLetters,
+		strings.Join(processorSummaries, ", "))
 	return summary
 }
@@ -0,0 +1,189 @@
+package itempipeline
+
+import (
+	"base"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 记录关闭顺序的组件。
+type recordingCloser struct {
+	name   string
+	order  *[]string
+	mutex  *sync.Mutex
+	closed int32
+}
+
+func (closer *recordingCloser) Close() error {
+	atomic.AddInt32(&closer.closed, 1)
+	closer.mutex.Lock()
+	defer closer.mutex.Unlock()
+	*closer.order = append(*closer.order, closer.name)
+	return nil
+}
+
+func TestPipelineSend(t *testing.T) {
+	var seen []base.Item
+	double := func(item base.Item) (base.Item, error) {
+		return base.Item{"n": item["n"].(int) * 2}, nil
+	}
+	failOdd := func(item base.Item) (base.Item, error) {
+		if item["n"].(int)%4 != 0 {
+			return nil, errors.New("not a multiple of 4")
+		}
+		return nil, nil
+	}
+	record := func(item base.Item) (base.Item, error) {
+		seen = append(seen, item)
+		return item, nil
+	}
+	pipeline := NewItempipeline([]ProcessItem{double, failOdd, record})
+	if errs := pipeline.Send(base.Item{"n": 2}); len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if errs := pipeline.Send(base.Item{"n": 1}); len(errs) != 1 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	// 不快速失败时，出错后的条目处理器仍会处理条目。
+	if len(seen) != 2 || seen[0]["n"] != 4 || seen[1]["n"] != 2 {
+		t.Errorf("Unexpected items: %v", seen)
+	}
+	pipeline.SetFailFsat(true)
+	pipeline.Send(base.Item{"n": 3})
+	if len(seen) != 2 {
+		t.Errorf("A failed item should not reach later processors when failing fast: %v", seen)
+	}
+	if errs := pipeline.Send(nil); len(errs) != 1 {
+		t.Errorf("A nil item should be rejected: %v", errs)
+	}
+	if counts := pipeline.Count(); counts[0] != 4 || counts[1] != 3 || counts[2] != 3 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+	stats := pipeline.ProcessorStats()
+	if stats[1].Calls != 3 || stats[1].Errors != 2 || stats[2].Calls != 2 {
+		t.Errorf("Unexpected processor stats: %+v", stats)
+	}
+}
+
+func TestPipelineDropAndPanic(t *testing.T) {
+	var reached int32
+	drop := func(item base.Item) (base.Item, error) {
+		if item["drop"] == true {
+			return nil, ErrItemDropped
+		}
+		if item["panic"] == true {
+			panic("boom")
+		}
+		return item, nil
+	}
+	last := func(item base.Item) (base.Item, error) {
+		atomic.AddInt32(&reached, 1)
+		return item, nil
+	}
+	pipeline := NewItempipeline([]ProcessItem{drop, last})
+	if errs := pipeline.Send(base.Item{"drop": true}); len(errs) != 0 {
+		t.Errorf("A dropped item is not a failure: %v", errs)
+	}
+	errs := pipeline.Send(base.Item{"panic": true})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "boom") {
+		t.Errorf("A panic should become an error: %v", errs)
+	}
+	pipeline.Send(base.Item{})
+	if pipeline.Dropped() != 1 || reached != 2 {
+		t.Errorf("Unexpected outcome: dropped=%d, reached=%d", pipeline.Dropped(), reached)
+	}
+}
+
+func TestPipelineWorkers(t *testing.T) {
+	release := make(chan struct{})
+	var processed, concurrent, maxConcurrent int32
+	slow := func(item base.Item) (base.Item, error) {
+		n := atomic.AddInt32(&concurrent, 1)
+		for {
+			max := atomic.LoadInt32(&maxConcurrent)
+			if n <= max || atomic.CompareAndSwapInt32(&maxConcurrent, max, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&concurrent, -1)
+		atomic.AddInt32(&processed, 1)
+		if item["fail"] == true {
+			return nil, errors.New("failure")
+		}
+		return item, nil
+	}
+	var order []string
+	var mutex sync.Mutex
+	first := &recordingCloser{name: "first", order: &order, mutex: &mutex}
+	second := &recordingCloser{name: "second", order: &order, mutex: &mutex}
+	pipeline := NewItempipelineWithClosers([]ProcessItem{slow}, []Closer{first, second})
+	if err := pipeline.Submit(base.Item{}); err == nil {
+		t.Errorf("Submitting before starting the workers should fail")
+	}
+	var handled int32
+	if err := pipeline.StartWorkers(3, 2, func(err error) {
+		atomic.AddInt32(&handled, 1)
+	}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := pipeline.StartWorkers(3, 2, nil); err == nil {
+		t.Errorf("Starting the workers twice should fail")
+	}
+	// 3个工作者忙碌、队列中有2个条目时，再提交的条目会被阻塞。
+	for i := 0; i < 5; i++ {
+		if err := pipeline.Submit(base.Item{"fail": i == 0}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	submitted := make(chan struct{})
+	go func() {
+		pipeline.Submit(base.Item{})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Errorf("Submit should block when the queue is full")
+	case <-time.After(30 * time.Millisecond):
+	}
+	if length, capacity := pipeline.QueueLength(); length != 2 || capacity != 2 {
+		t.Errorf("Unexpected queue: %d/%d", length, capacity)
+	}
+	if total, busy := pipeline.Workers(); total != 3 || busy != 3 {
+		t.Errorf("Unexpected workers: %d/%d", busy, total)
+	}
+	if pipeline.ProcessingNumber() != 6 {
+		t.Errorf("Unexpected processing number: %d", pipeline.ProcessingNumber())
+	}
+	close(release)
+	<-submitted
+	if errs := pipeline.Close(); len(errs) != 0 {
+		t.Errorf("Unexpected close errors: %v", errs)
+	}
+	if processed != 6 || handled != 1 || maxConcurrent != 3 {
+		t.Errorf("Unexpected outcome: processed=%d, handled=%d, maxConcurrent=%d",
+			processed, handled, maxConcurrent)
+	}
+	if pipeline.ProcessingNumber() != 0 {
+		t.Errorf("Unexpected processing number after closing: %d", pipeline.ProcessingNumber())
+	}
+	if utilization := pipeline.Utilization(); utilization <= 0 || utilization > 1 {
+		t.Errorf("Unexpected utilization: %f", utilization)
+	}
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("The closers should be closed in order: %v", order)
+	}
+	if err := pipeline.Submit(base.Item{}); err == nil {
+		t.Errorf("Submitting after closing should fail")
+	}
+	if errs := pipeline.Send(base.Item{}); len(errs) != 1 {
+		t.Errorf("Sending after closing should fail: %v", errs)
+	}
+	if errs := pipeline.Close(); len(errs) != 0 || first.closed != 1 {
+		t.Errorf("Closing twice should do nothing: %v", errs)
+	}
+}
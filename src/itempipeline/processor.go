@@ -2,7 +2,35 @@ package itempipeline
 
 import (
 	"base"
+	"context"
+	"errors"
 )
 
 // 被用来处理条目的函数类型。
 type ProcessItem func(item base.Item) (result base.Item, err error)
+
+// 被用来处理条目的、可被取消的函数类型。
+// 条目处理管道被取消（如调度器停止且排空超时）时ctx会被取消，条目处理器应尽快返回ctx.Err()。
+type ProcessItemContext func(ctx context.Context, item base.Item) (result base.Item, err error)
+
+// 把条目处理器转换为可被取消的条目处理器。上下文已被取消时不会再调用条目处理器，
+// 但正在执行的条目处理器无法被中断。
+func AdaptProcessor(processor ProcessItem) ProcessItemContext {
+	return func(ctx context.Context, item base.Item) (result base.Item, err error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return processor(item)
+	}
+}
+
+// 把可被取消的条目处理器转换为条目处理器。调用时使用不会被取消的上下文。
+func PlainProcessor(processor ProcessItemContext) ProcessItem {
+	return func(item base.Item) (result base.Item, err error) {
+		return processor(context.Background(), item)
+	}
+}
+
+// 条目处理器可以返回该错误以丢弃条目（如重复的条目）。
+// 被丢弃的条目不会再被后续的条目处理器处理，也不会被视为处理失败。
+var ErrItemDropped = errors.New("The item has been dropped")
@@ -0,0 +1,408 @@
+package itempipeline
+
+import (
+	"base"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 压缩方式。
+const (
+	COMPRESSION_NONE = ""
+	COMPRESSION_GZIP = "gzip"
+	COMPRESSION_ZSTD = "zstd"
+)
+
+// 列的类型。
+const (
+	COLUMN_STRING  = "string"
+	COLUMN_INT64   = "int64"
+	COLUMN_DOUBLE  = "double"
+	COLUMN_BOOLEAN = "boolean"
+)
+
+// 列，即条目中的一个字段在输出文件中的表示。
+type Column struct {
+	Name string // 条目中的字段名称，同时也是列名。
+	Type string // 列的类型。为空表示COLUMN_STRING。CSV文件只使用列名。
+}
+
+// 条目存储器的参数容器的描述模板。
+var sinkArgsTemplate string = "{ path: %s, maxSize: %d, maxAge: %s, compression: %q }"
+
+// 条目存储器的参数容器。
+type SinkArgs struct {
+	// 输出文件的路径前缀，如"output/items"。
+	// 实际的文件名会附加创建时间、序号和扩展名，如"output/items-20060102-150405-0001.jsonl.gz"。
+	Path string
+	// 单个文件的最大尺寸（按写入的未压缩字节数计算）。超过后会切换到新文件。为0表示不限制。
+	MaxSize int64
+	// 单个文件的最长写入时间。超过后会切换到新文件。为0表示不限制。
+	MaxAge time.Duration
+	// 压缩方式。
+	Compression string
+}
+
+func (args *SinkArgs) Check() error {
+	if args.Path == "" {
+		return errors.New("The sink path can not be empty!\n")
+	}
+	if args.MaxSize < 0 {
+		return errors.New("The max file size can not be negative!\n")
+	}
+	if args.MaxAge < 0 {
+		return errors.New("The max file age can not be negative!\n")
+	}
+	switch args.Compression {
+	case COMPRESSION_NONE, COMPRESSION_GZIP, COMPRESSION_ZSTD:
+	default:
+		return errors.New(fmt.Sprintf("Unsupported compression %q!\n", args.Compression))
+	}
+	return nil
+}
+
+func (args *SinkArgs) String() string {
+	return fmt.Sprintf(sinkArgsTemplate,
+		args.Path, args.MaxSize, args.MaxAge, args.Compression)
+}
+
+// 需要在条目处理管道关闭时被关闭的组件，如条目存储器。
+type Closer interface {
+	Close() error
+}
+
+// 条目存储器的接口类型。它会把条目写入文件，通常被用作条目处理管道的终点。
+type ItemSink interface {
+	// 写入条目。结果值中的条目即为参数中的条目，因此该方法可被直接用作条目处理器。
+	Process(item base.Item) (result base.Item, err error)
+	// 把已写入的条目刷入文件。
+	Flush() error
+	// 刷入并关闭当前文件。关闭后的存储器不再接受条目。
+	Close() error
+	// 获得已写入的条目、写入失败的条目的计数值，以及已创建的文件（或已提交的事务）的数量。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 条目编码器。每个文件都会使用一个新的编码器。
+type itemEncoder interface {
+	// 在文件的开头写入必要的内容，如文件头。
+	begin(w io.Writer) error
+	// 编码并写入条目。
+	encode(w io.Writer, item base.Item) error
+	// 把编码器中缓存的内容写入。
+	flush(w io.Writer) error
+	// 在文件的末尾写入必要的内容，如文件尾。
+	end(w io.Writer) error
+}
+
+// 条目编码器的描述。
+type encoderSpec struct {
+	extension string // 文件扩展名，不含压缩方式对应的扩展名。
+	// 编码器是否自行压缩。为true时整个文件不会被再次压缩。
+	selfCompressed bool
+	// 创建条目编码器。
+	newEncoder func() itemEncoder
+}
+
+// 计数的写入器。
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.writer.Write(p)
+	cw.count += int64(n)
+	return n, err
+}
+
+// 条目存储器正在写入的文件。
+type sinkFile struct {
+	name       string          // 文件名。
+	file       *os.File        // 文件。
+	buffer     *bufio.Writer   // 文件的缓冲写入器。
+	compressor io.WriteCloser  // 压缩写入器。不压缩时为nil。
+	counter    *countingWriter // 最外层的写入器，统计写入的未压缩字节数。
+	encoder    itemEncoder     // 条目编码器。
+	opened     time.Time       // 创建时间。
+}
+
+// 刷入文件。
+func (sf *sinkFile) flush() error {
+	if err := sf.encoder.flush(sf.counter); err != nil {
+		return err
+	}
+	if flusher, ok := sf.compressor.(interface {
+		Flush() error
+	}); ok {
+		if err := flusher.Flush(); err != nil {
+			return err
+		}
+	}
+	return sf.buffer.Flush()
+}
+
+// 写入文件尾并关闭文件。即使出错也会关闭文件，结果值为第一个错误。
+func (sf *sinkFile) close() error {
+	errs := []error{sf.encoder.end(sf.counter)}
+	if sf.compressor != nil {
+		errs = append(errs, sf.compressor.Close())
+	}
+	errs = append(errs, sf.buffer.Flush(), sf.file.Close())
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 条目存储器的实现类型。
+type mySink struct {
+	args     SinkArgs    // 参数。
+	spec     encoderSpec // 条目编码器的描述。
+	current  *sinkFile   // 正在写入的文件。
+	sequence uint32      // 已创建的文件的序号。
+	closed   bool        // 是否已被关闭。
+	mutex    sync.Mutex  // 互斥锁。
+	written  uint64      // 已写入的条目的数量。
+	failed   uint64      // 写入失败的条目的数量。
+}
+
+// 创建条目存储器。
+func newSink(args SinkArgs, spec encoderSpec) (ItemSink, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	return &mySink{args: args, spec: spec}, nil
+}
+
+func (sink *mySink) Process(item base.Item) (result base.Item, err error) {
+	defer func() {
+		if err != nil {
+			atomic.AddUint64(&sink.failed, 1)
+		} else {
+			atomic.AddUint64(&sink.written, 1)
+		}
+	}()
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil, errors.New("The item sink has been closed!\n")
+	}
+	if sink.current != nil && sink.expired() {
+		file := sink.current
+		sink.current = nil
+		if err := file.close(); err != nil {
+			return nil, err
+		}
+	}
+	if sink.current == nil {
+		if sink.current, err = sink.open(); err != nil {
+			return nil, err
+		}
+	}
+	if err := sink.current.encoder.encode(sink.current.counter, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// 判断当前文件是否已达到切换的条件。
+func (sink *mySink) expired() bool {
+	if sink.args.MaxSize > 0 && sink.current.counter.count >= sink.args.MaxSize {
+		return true
+	}
+	if sink.args.MaxAge > 0 && time.Since(sink.current.opened) >= sink.args.MaxAge {
+		return true
+	}
+	return false
+}
+
+// 创建新的文件。
+func (sink *mySink) open() (*sinkFile, error) {
+	sink.sequence++
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s-%04d.%s",
+		sink.args.Path, now.Format("20060102-150405"), sink.sequence, sink.spec.extension)
+	if !sink.spec.selfCompressed {
+		switch sink.args.Compression {
+		case COMPRESSION_GZIP:
+			name += ".gz"
+		case COMPRESSION_ZSTD:
+			name += ".zst"
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	sf := &sinkFile{
+		name:    name,
+		file:    file,
+		buffer:  bufio.NewWriter(file),
+		encoder: sink.spec.newEncoder(),
+		opened:  now,
+	}
+	var writer io.Writer = sf.buffer
+	if !sink.spec.selfCompressed {
+		switch sink.args.Compression {
+		case COMPRESSION_GZIP:
+			sf.compressor = gzip.NewWriter(sf.buffer)
+		case COMPRESSION_ZSTD:
+			if sf.compressor, err = zstd.NewWriter(sf.buffer); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+		if sf.compressor != nil {
+			writer = sf.compressor
+		}
+	}
+	sf.counter = &countingWriter{writer: writer}
+	if err := sf.encoder.begin(sf.counter); err != nil {
+		sf.close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+func (sink *mySink) Flush() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.current == nil {
+		return nil
+	}
+	return sink.current.flush()
+}
+
+func (sink *mySink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+	if sink.current == nil {
+		return nil
+	}
+	file := sink.current
+	sink.current = nil
+	return file.close()
+}
+
+func (sink *mySink) Count() []uint64 {
+	counts := make([]uint64, 3)
+	counts[0] = atomic.LoadUint64(&sink.written)
+	counts[1] = atomic.LoadUint64(&sink.failed)
+	sink.mutex.Lock()
+	counts[2] = uint64(sink.sequence)
+	sink.mutex.Unlock()
+	return counts
+}
+
+var sinkSummaryTemplate = "path: %s, compression: %q, closed: %v," +
+	" written: %d, failed: %d, files: %d, current: %s"
+
+func (sink *mySink) Summary() string {
+	counts := sink.Count()
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	var current string
+	if sink.current != nil {
+		current = sink.current.name
+	}
+	return fmt.Sprintf(sinkSummaryTemplate,
+		sink.args.Path, sink.args.Compression, sink.closed,
+		counts[0], counts[1], counts[2], current)
+}
+
+// 依据条目推断列。列按名称排序，以保证顺序稳定。
+func inferColumns(item base.Item) []Column {
+	names := make([]string, 0, len(item))
+	for name := range item {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		column := Column{Name: name, Type: COLUMN_STRING}
+		switch item[name].(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			column.Type = COLUMN_INT64
+		case float32, float64:
+			column.Type = COLUMN_DOUBLE
+		case bool:
+			column.Type = COLUMN_BOOLEAN
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// 检查列的有效性。
+func checkColumns(columns []Column) error {
+	names := make(map[string]bool)
+	for i, column := range columns {
+		if column.Name == "" {
+			return errors.New(fmt.Sprintf("The name of column[%d] is empty!\n", i))
+		}
+		if names[column.Name] {
+			return errors.New(fmt.Sprintf("Duplicate column %q!\n", column.Name))
+		}
+		names[column.Name] = true
+		switch column.Type {
+		case "", COLUMN_STRING, COLUMN_INT64, COLUMN_DOUBLE, COLUMN_BOOLEAN:
+		default:
+			return errors.New(fmt.Sprintf("Unsupported type %q of column %q!\n",
+				column.Type, column.Name))
+		}
+	}
+	return nil
+}
+
+// 把字段的值格式化为字符串。复合值会被编码为JSON。
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,193 @@
+package itempipeline
+
+import (
+	"base"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 读取（必要时解压）输出文件的内容。
+func readSinkFile(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("Can not read %s: %s", name, err)
+	}
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Invalid gzip file %s: %s", name, err)
+		}
+		if data, err = ioutil.ReadAll(reader); err != nil {
+			t.Fatalf("Invalid gzip file %s: %s", name, err)
+		}
+	case strings.HasSuffix(name, ".zst"):
+		decoder, _ := zstd.NewReader(nil)
+		defer decoder.Close()
+		if data, err = decoder.DecodeAll(data, nil); err != nil {
+			t.Fatalf("Invalid zstd file %s: %s", name, err)
+		}
+	}
+	return data
+}
+
+// 获得目录中按名称排序的输出文件。
+func sinkFiles(t *testing.T, pattern string) []string {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("Invalid pattern: %s", err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// 读取JSON Lines文件中的条目序号。
+func readJSONLines(t *testing.T, name string) []int {
+	var ids []int
+	for _, line := range strings.Split(strings.TrimSpace(string(readSinkFile(t, name))), "\n") {
+		if line == "" {
+			continue
+		}
+		var item map[string]int
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			t.Fatalf("Invalid line %q in %s: %s", line, name, err)
+		}
+		ids = append(ids, item["id"])
+	}
+	return ids
+}
+
+func TestJSONLinesSinkCompression(t *testing.T) {
+	extensions := map[string]string{
+		COMPRESSION_NONE: ".jsonl",
+		COMPRESSION_GZIP: ".jsonl.gz",
+		COMPRESSION_ZSTD: ".jsonl.zst",
+	}
+	for compression, extension := range extensions {
+		t.Run(fmt.Sprintf("compression=%q", compression), func(t *testing.T) {
+			dir := t.TempDir()
+			sink, err := NewJSONLinesSink(SinkArgs{Path: filepath.Join(dir, "items"), Compression: compression})
+			if err != nil {
+				t.Fatalf("Can not create the sink: %s", err)
+			}
+			for i := 0; i < 5; i++ {
+				sink.Process(base.Item{"id": i})
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			files := sinkFiles(t, filepath.Join(dir, "items-*"))
+			if len(files) != 1 || !strings.HasSuffix(files[0], extension) {
+				t.Fatalf("Unexpected files: %v", files)
+			}
+			if ids := readJSONLines(t, files[0]); !reflect.DeepEqual(ids, []int{0, 1, 2, 3, 4}) {
+				t.Errorf("Unexpected items: %v", ids)
+			}
+		})
+	}
+}
+
+func TestJSONLinesSinkRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	// 每行为9个字节（{"id":N}和换行符），因此每个文件有两行。
+	sink, err := NewJSONLinesSink(SinkArgs{Path: filepath.Join(dir, "items"), MaxSize: 18, Compression: COMPRESSION_GZIP})
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := sink.Process(base.Item{"id": i}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if counts := sink.Count(); counts[0] != 5 || counts[2] != 3 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+	files := sinkFiles(t, filepath.Join(dir, "items-*.jsonl.gz"))
+	expected := [][]int{{0, 1}, {2, 3}, {4}}
+	if len(files) != len(expected) {
+		t.Fatalf("Unexpected files: %v", files)
+	}
+	for i, file := range files {
+		if ids := readJSONLines(t, file); !reflect.DeepEqual(ids, expected[i]) {
+			t.Errorf("Unexpected items in %s: %v", file, ids)
+		}
+	}
+}
+
+func TestCSVSinkRotateByAge(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewCSVSink(SinkArgs{Path: filepath.Join(dir, "items"), MaxAge: 30 * time.Millisecond,
+		Compression: COMPRESSION_ZSTD}, []Column{{Name: "id"}, {Name: "name"}})
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	sink.Process(base.Item{"id": 1, "name": "a, b"})
+	sink.Process(base.Item{"id": 2})
+	time.Sleep(40 * time.Millisecond)
+	sink.Process(base.Item{"id": 3, "name": "c", "extra": true})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	files := sinkFiles(t, filepath.Join(dir, "items-*.csv.zst"))
+	expected := [][][]string{
+		{{"id", "name"}, {"1", "a, b"}, {"2", ""}},
+		{{"id", "name"}, {"3", "c"}},
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("Unexpected files: %v", files)
+	}
+	for i, file := range files {
+		records, err := csv.NewReader(bytes.NewReader(readSinkFile(t, file))).ReadAll()
+		if err != nil {
+			t.Fatalf("Invalid csv file %s: %s", file, err)
+		}
+		if !reflect.DeepEqual(records, expected[i]) {
+			t.Errorf("Unexpected records in %s: %v", file, records)
+		}
+	}
+}
+
+func TestCSVSinkInferColumns(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewCSVSink(SinkArgs{Path: filepath.Join(dir, "items"), Compression: COMPRESSION_GZIP}, nil)
+	if err != nil {
+		t.Fatalf("Can not create the sink: %s", err)
+	}
+	sink.Process(base.Item{"b": 1.5, "a": "x"})
+	sink.Process(base.Item{"a": "y", "c": "ignored"})
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := sink.Process(base.Item{"a": "z"}); err == nil {
+		t.Errorf("A closed sink should reject items")
+	}
+	files := sinkFiles(t, filepath.Join(dir, "items-*.csv.gz"))
+	if len(files) != 1 {
+		t.Fatalf("Unexpected files: %v", files)
+	}
+	records, err := csv.NewReader(bytes.NewReader(readSinkFile(t, files[0]))).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid csv file: %s", err)
+	}
+	expected := [][]string{{"a", "b"}, {"x", "1.5"}, {"y", ""}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Unexpected records: %v", records)
+	}
+}
@@ -0,0 +1,259 @@
+package itempipeline
+
+import (
+	"base"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 条目中的一个字段的声明。
+type FieldSchema struct {
+	// 字段名称。
+	Name string
+	// 字段的类型，即COLUMN_STRING、COLUMN_INT64、COLUMN_DOUBLE或COLUMN_BOOLEAN。
+	// 字段的值会被转换（如"42"转换为42）为该类型，无法转换时条目会被拒绝。为空表示不限制类型。
+	Type string
+	// 是否必须存在。值为nil视同不存在。缺少且没有默认值时条目会被拒绝。
+	Required bool
+	// 字段的文本形式必须匹配的正则表达式。为空表示不限制。
+	Pattern string
+	// 字段的文本形式的可选值。为空表示不限制。
+	Enum []string
+	// 字段不存在时使用的默认值。为nil表示没有默认值。默认值同样会被转换为字段的类型。
+	Default interface{}
+}
+
+// 条目校验器的参数容器的描述模板。
+var validatorArgsTemplate string = "{ fields: %v, dropUnknown: %v }"
+
+// 条目校验器的参数容器。
+type ValidatorArgs struct {
+	Fields      []FieldSchema // 字段的声明。
+	DropUnknown bool          // 是否删除未被声明的字段。否则未被声明的字段会被原样保留。
+}
+
+func (args *ValidatorArgs) Check() error {
+	if len(args.Fields) == 0 {
+		return errors.New("The field list can not be empty!\n")
+	}
+	columns := make([]Column, len(args.Fields))
+	for i, field := range args.Fields {
+		columns[i] = Column{Name: field.Name, Type: field.Type}
+	}
+	if err := checkColumns(columns); err != nil {
+		return err
+	}
+	for _, field := range args.Fields {
+		if _, err := compileField(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (args *ValidatorArgs) String() string {
+	names := make([]string, len(args.Fields))
+	for i, field := range args.Fields {
+		names[i] = field.Name
+	}
+	return fmt.Sprintf(validatorArgsTemplate, names, args.DropUnknown)
+}
+
+// 条目校验器的接口类型。
+type ItemValidator interface {
+	// 校验条目。结果值是经过类型转换和默认值填充的新条目，参数中的条目不会被修改。
+	// 条目无效时，错误值为携带全部字段错误的base.ItemValidationError，其错误类型为ITEM_PROCESSOR_ERROR。
+	// 该方法可被直接用作条目处理器。
+	Process(item base.Item) (result base.Item, err error)
+	// 获得各个字段的校验失败次数。
+	Violations() map[string]uint64
+	// 获得已校验的条目、被拒绝的条目、被转换的字段值以及被填充的默认值的计数值。
+	Count() []uint64
+	// 获取摘要信息。
+	Summary() string
+}
+
+// 创建条目校验器。
+func NewItemValidator(args ValidatorArgs) (ItemValidator, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	fields := make([]validatorField, len(args.Fields))
+	for i, field := range args.Fields {
+		fields[i], _ = compileField(field)
+	}
+	return &myItemValidator{
+		args:       args,
+		fields:     fields,
+		violations: make(map[string]uint64),
+	}, nil
+}
+
+// 经过预处理的字段声明。
+type validatorField struct {
+	FieldSchema
+	pattern      *regexp.Regexp  // 编译后的正则表达式。
+	enum         map[string]bool // 可选值的集合。
+	defaultValue interface{}     // 转换后的默认值。
+}
+
+// 预处理字段声明，并检查其默认值的有效性。
+func compileField(field FieldSchema) (validatorField, error) {
+	compiled := validatorField{FieldSchema: field}
+	if field.Pattern != "" {
+		pattern, err := regexp.Compile(field.Pattern)
+		if err != nil {
+			return compiled, errors.New(fmt.Sprintf("Invalid pattern of field %q: %s!\n", field.Name, err))
+		}
+		compiled.pattern = pattern
+	}
+	if len(field.Enum) > 0 {
+		compiled.enum = make(map[string]bool)
+		for _, value := range field.Enum {
+			compiled.enum[value] = true
+		}
+	}
+	if field.Default != nil {
+		value, _, reason := compiled.check(field.Default)
+		if reason != "" {
+			return compiled, errors.New(fmt.Sprintf("Invalid default value of field %q: %s!\n", field.Name, reason))
+		}
+		compiled.defaultValue = value
+	}
+	return compiled, nil
+}
+
+// 检查并转换字段的值。reason不为空表示校验失败。
+func (field *validatorField) check(value interface{}) (result interface{}, coerced bool, reason string) {
+	if field.Type != "" {
+		converted, err := convertValue(value, field.Type)
+		if err != nil {
+			return nil, false, fmt.Sprintf("is not a valid %s (%s)", field.Type, err)
+		}
+		coerced = reflect.TypeOf(converted) != reflect.TypeOf(value)
+		value = converted
+	}
+	if field.pattern == nil && field.enum == nil {
+		return value, coerced, ""
+	}
+	text, err := formatValue(value)
+	if err != nil {
+		return nil, false, fmt.Sprintf("can not be formatted (%s)", err)
+	}
+	if field.pattern != nil && !field.pattern.MatchString(text) {
+		return nil, false, fmt.Sprintf("%q does not match %q", text, field.Pattern)
+	}
+	if field.enum != nil && !field.enum[text] {
+		return nil, false, fmt.Sprintf("%q is not one of %v", text, field.Enum)
+	}
+	return value, coerced, ""
+}
+
+// 条目校验器的实现类型。
+type myItemValidator struct {
+	args       ValidatorArgs     // 参数。
+	fields     []validatorField  // 经过预处理的字段声明。
+	violations map[string]uint64 // 各个字段的校验失败次数。
+	mutex      sync.Mutex        // 针对校验失败次数的互斥锁。
+	validated  uint64            // 已校验的条目的数量。
+	rejected   uint64            // 被拒绝的条目的数量。
+	coerced    uint64            // 被转换类型的字段值的数量。
+	defaulted  uint64            // 被填充的默认值的数量。
+}
+
+func (validator *myItemValidator) Process(item base.Item) (result base.Item, err error) {
+	if item == nil {
+		return nil, errors.New("The item is invalid!\n")
+	}
+	atomic.AddUint64(&validator.validated, 1)
+	result = make(base.Item, len(item))
+	if !validator.args.DropUnknown {
+		for name, value := range item {
+			result[name] = value
+		}
+	}
+	var violations []base.FieldViolation
+	var coerced, defaulted uint64
+	for i := range validator.fields {
+		field := &validator.fields[i]
+		value, ok := item[field.Name]
+		if !ok || value == nil {
+			switch {
+			case field.defaultValue != nil:
+				result[field.Name] = field.defaultValue
+				defaulted++
+			case field.Required:
+				violations = append(violations, base.FieldViolation{Field: field.Name, Reason: "is missing"})
+			case ok:
+				result[field.Name] = nil
+			}
+			continue
+		}
+		checked, isCoerced, reason := field.check(value)
+		if reason != "" {
+			violations = append(violations, base.FieldViolation{Field: field.Name, Reason: reason})
+			continue
+		}
+		if isCoerced {
+			coerced++
+		}
+		result[field.Name] = checked
+	}
+	if len(violations) > 0 {
+		atomic.AddUint64(&validator.rejected, 1)
+		validator.mutex.Lock()
+		for _, violation := range violations {
+			validator.violations[violation.Field]++
+		}
+		validator.mutex.Unlock()
+		return nil, base.NewItemValidationError(violations)
+	}
+	atomic.AddUint64(&validator.coerced, coerced)
+	atomic.AddUint64(&validator.defaulted, defaulted)
+	return result, nil
+}
+
+func (validator *myItemValidator) Violations() map[string]uint64 {
+	validator.mutex.Lock()
+	defer validator.mutex.Unlock()
+	violations := make(map[string]uint64, len(validator.violations))
+	for name, count := range validator.violations {
+		violations[name] = count
+	}
+	return violations
+}
+
+func (validator *myItemValidator) Count() []uint64 {
+	counts := make([]uint64, 4)
+	counts[0] = atomic.LoadUint64(&validator.validated)
+	counts[1] = atomic.LoadUint64(&validator.rejected)
+	counts[2] = atomic.LoadUint64(&validator.coerced)
+	counts[3] = atomic.LoadUint64(&validator.defaulted)
+	return counts
+}
+
+var validatorSummaryTemplate = "fields: %d, validated: %d, rejected: %d," +
+	" coerced: %d, defaulted: %d, violations: [%s]"
+
+func (validator *myItemValidator) Summary() string {
+	counts := validator.Count()
+	violations := validator.Violations()
+	names := make([]string, 0, len(violations))
+	for name := range violations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %d", name, violations[name])
+	}
+	return fmt.Sprintf(validatorSummaryTemplate,
+		len(validator.fields), counts[0], counts[1], counts[2], counts[3],
+		strings.Join(parts, ", "))
+}
@@ -0,0 +1,104 @@
+package itempipeline
+
+import (
+	"base"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func newTestValidator(t *testing.T, dropUnknown bool) ItemValidator {
+	validator, err := NewItemValidator(ValidatorArgs{
+		Fields: []FieldSchema{
+			{Name: "url", Type: COLUMN_STRING, Required: true, Pattern: `^https?://`},
+			{Name: "size", Type: COLUMN_INT64, Required: true},
+			{Name: "score", Type: COLUMN_DOUBLE, Default: "0.5"},
+			{Name: "ok", Type: COLUMN_BOOLEAN},
+			{Name: "kind", Enum: []string{"page", "feed"}, Default: "page"},
+		},
+		DropUnknown: dropUnknown,
+	})
+	if err != nil {
+		t.Fatalf("Can not create the validator: %s", err)
+	}
+	return validator
+}
+
+func TestValidatorCoercionAndDefaults(t *testing.T) {
+	validator := newTestValidator(t, false)
+	item := base.Item{"url": "http://a", "size": "42", "ok": "true", "extra": 1}
+	result, err := validator.Process(item)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := base.Item{"url": "http://a", "size": int64(42), "score": 0.5, "ok": true, "kind": "page", "extra": 1}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected result: %v", result)
+	}
+	if item["size"] != "42" {
+		t.Errorf("The original item should not be modified: %v", item)
+	}
+	result, err = validator.Process(base.Item{"url": "https://b", "size": json.Number("7"), "ok": nil, "kind": "feed"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result["size"] != int64(7) || result["kind"] != "feed" || result["ok"] != nil {
+		t.Errorf("Unexpected result: %v", result)
+	}
+	if counts := validator.Count(); !reflect.DeepEqual(counts, []uint64{2, 0, 3, 3}) {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	validator = newTestValidator(t, true)
+	result, _ = validator.Process(base.Item{"url": "http://a", "size": 1, "extra": 1})
+	if _, ok := result["extra"]; ok {
+		t.Errorf("An unknown field should be dropped: %v", result)
+	}
+}
+
+func TestValidatorViolations(t *testing.T) {
+	validator := newTestValidator(t, false)
+	_, err := validator.Process(base.Item{"url": "ftp://a", "size": "big", "kind": "video"})
+	validationErr, ok := err.(base.ItemValidationError)
+	if !ok {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+	if validationErr.Type() != base.ITEM_PROCESSOR_ERROR {
+		t.Errorf("Unexpected error type: %s", validationErr.Type())
+	}
+	fields := make([]string, 0)
+	for _, violation := range validationErr.Violations() {
+		fields = append(fields, violation.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"url", "size", "kind"}) {
+		t.Errorf("Unexpected violations: %v", validationErr.Violations())
+	}
+	_, err = validator.Process(base.Item{"url": "http://a"})
+	if err == nil {
+		t.Fatalf("A missing required field should be rejected")
+	}
+	violations := validator.Violations()
+	if violations["url"] != 1 || violations["size"] != 2 || violations["kind"] != 1 {
+		t.Errorf("Unexpected violation counts: %v", violations)
+	}
+	if counts := validator.Count(); counts[0] != 2 || counts[1] != 2 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+func TestValidatorArgsCheck(t *testing.T) {
+	invalid := []ValidatorArgs{
+		{},
+		{Fields: []FieldSchema{{Name: ""}}},
+		{Fields: []FieldSchema{{Name: "a"}, {Name: "a"}}},
+		{Fields: []FieldSchema{{Name: "a", Type: "date"}}},
+		{Fields: []FieldSchema{{Name: "a", Pattern: "("}}},
+		{Fields: []FieldSchema{{Name: "a", Type: COLUMN_INT64, Default: "x"}}},
+		{Fields: []FieldSchema{{Name: "a", Enum: []string{"x"}, Default: "y"}}},
+	}
+	for i, args := range invalid {
+		if _, err := NewItemValidator(args); err == nil {
+			t.Errorf("The args[%d] should be invalid: %s", i, args.String())
+		}
+	}
+}
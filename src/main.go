@@ -2,111 +2,176 @@ package main
 
 import (
 	"analyzer"
+	"analyzer/parsers"
 	"base"
 	"errors"
-	"fmt"
-	"goquery"
-	"io"
+	"flag"
 	"itempipeline"
 	"logging"
 	"net/http"
-	"net/url"
 	"scheduler"
-	"strings"
 	"time"
 )
 
 var logger logging.Logger = logging.NewSimpleLogger()
 
+// 死信文件的路径前缀。
+const deadLetterPath = "output/deadletter"
+
+// 修复条目处理器后，可以用该参数指定死信文件（如"output/deadletter-*.jsonl"）并重新处理其中的条目。
+var replayPattern = flag.String("replay", "", "replay the items in the dead letter files matching the pattern")
+
 func main() {
+	flag.Parse()
 	channelArgs := base.NewChannelArgs(10, 10, 10, 10)
 	poolBaseArgs := base.NewPoolBaseArgs(3, 3)
 	crawlDepth := uint32(3)
 	httpClientGenerator := genHttpClient
 	respParsers := getRespParsers()
-	itemProcessors := getItemProcessors()
+	itemSink, err := itempipeline.NewJSONLinesSink(itempipeline.SinkArgs{
+		Path:        "output/items",
+		MaxSize:     64 << 20,
+		Compression: itempipeline.COMPRESSION_GZIP,
+	})
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	deadLetters, err := itempipeline.NewDeadLetterStore(itempipeline.SinkArgs{Path: deadLetterPath})
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	itemFlow := getItemFlow(itemSink)
+	if *replayPattern != "" {
+		replay(itemFlow, itemSink, deadLetters)
+		return
+	}
 	startUrl := "http://127.0.0.1:9001"
 	firstHttpReq, err := http.NewRequest("GET", startUrl, nil)
 	if err != nil {
 		logger.Errorln(err)
 		return
 	}
+	// 站点的robots.txt和/sitemap.xml由ParseForSitemap解析，其中的网址会被优先爬取。
+	sitemapSeeds, err := scheduler.GenSitemapSeeds(firstHttpReq)
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
 
 	scheduler := scheduler.NewScheduler()
-	scheduler.Start(channelArgs, poolBaseArgs, crawlDepth, httpClientGenerator, respParsers, itemProcessors, firstHttpReq)
+	scheduler.Seed(sitemapSeeds...)
+	// 调度器停止时会关闭条目存储器，以写出缓存的条目。
+	scheduler.AddCloser(itemSink)
+	scheduler.SetDeadLetterStore(deadLetters)
+	// 停止时最多等待5秒以处理完剩余的条目，未处理完的条目会被写入死信文件。
+	scheduler.SetDrainTimeout(5 * time.Second)
+	scheduler.Start(channelArgs, poolBaseArgs, crawlDepth, httpClientGenerator, respParsers, itemFlow, firstHttpReq)
+}
+
+// 把死信文件中的条目重新发送到条目处理管道。再次失败的条目会被写入新的死信文件。
+func replay(itemFlow itempipeline.ItemFlow,
+	itemSink itempipeline.ItemSink, deadLetters itempipeline.DeadLetterStore) {
+	itemPipeline, err := itempipeline.NewFlowItempipeline(itemFlow, []itempipeline.Closer{itemSink})
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	// 与调度器一样快速失败，以免再次失败的条目在被写入死信文件的同时也被写入条目存储器。
+	itemPipeline.SetFailFsat(true)
+	itemPipeline.SetDeadLetterStore(deadLetters)
+	replayed, failed, err := itempipeline.Replay(itemPipeline, itemSink.Process, *replayPattern)
+	if err != nil {
+		logger.Errorln(err)
+	}
+	for _, err := range itemPipeline.Close() {
+		logger.Errorln(err)
+	}
+	logger.Infof("Replayed %d dead-lettered items, %d failed again.\n", replayed, failed)
 }
 
 func genHttpClient() *http.Client {
 	return &http.Client{}
 }
 
-func parseForATag(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
-	// TODO 支持更多的HTTP响应状态
-	if httpResp.StatusCode != 200 {
-		err := errors.New(
-			fmt.Sprintf("Unsupported status code %d. (httpResponse=%v)", httpResp))
-		return nil, []error{err}
-	}
-	var reqUrl *url.URL = httpResp.Request.URL
-	var httpRespBody io.ReadCloser = httpResp.Body
-	defer func() {
-		if httpRespBody != nil {
-			httpRespBody.Close()
-		}
-	}()
-	dataList := make([]base.Data, 0)
-	errs := make([]error, 0)
-	// 开始解析
-	doc, err := goquery.NewDocumentFromReader(httpRespBody)
+func getRespParsers() []analyzer.ParseResponse {
+	// 内部审计时可将Ignore设为true，以忽略网页级爬取指令和链接上的rel="nofollow"。
+	robotsFilter, err := analyzer.NewRobotsFilter(analyzer.RobotsArgs{Ignore: false})
 	if err != nil {
-		errs = append(errs, err)
-		return dataList, errs
-	}
-	// 查找“A”标签并提取链接地址
-	doc.Find("a").Each(func(index int, sel *goquery.Selection) {
-		href, exists := sel.Attr("href")
-		// 前期过滤
-		if !exists || href == "" || href == "#" || href == "/" {
-			return
-		}
-		href = strings.TrimSpace(href)
-		lowerHref := strings.ToLower(href)
-		// 暂不支持对Javascript代码的解析。
-		if href != "" && !strings.HasPrefix(lowerHref, "javascript") {
-			aUrl, err := url.Parse(href)
-			if err != nil {
-				errs = append(errs, err)
-				return
-			}
-			if !aUrl.IsAbs() {
-				aUrl = reqUrl.ResolveReference(aUrl)
-			}
-			httpReq, err := http.NewRequest("GET", aUrl.String(), nil)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				req := base.NewRequest(httpReq, respDepth)
-				dataList = append(dataList, req)
-			}
-		}
-		text := strings.TrimSpace(sel.Text())
-		if text != "" {
-			imap := make(map[string]interface{})
-			imap["parent_url"] = reqUrl
-			imap["a.text"] = text
-			imap["a.index"] = index
-			item := base.Item(imap)
-			dataList = append(dataList, &item)
+		panic(err)
+	}
+	linkArgs := parsers.DefaultLinkArgs()
+	linkArgs.Robots = robotsFilter
+	// 下一页只由翻页解析函数请求，以免翻页消耗爬取深度。
+	paginationArgs := parsers.DefaultPaginationArgs()
+	linkArgs.Pagination = &paginationArgs
+	linkParser, err := parsers.NewLinkParser(linkArgs)
+	if err != nil {
+		panic(err)
+	}
+	paginationParser, err := parsers.NewPaginationParser(paginationArgs)
+	if err != nil {
+		panic(err)
+	}
+	feedParser, err := parsers.NewFeedParser(parsers.DefaultFeedArgs())
+	if err != nil {
+		panic(err)
+	}
+	feedDiscoveryParser, err := parsers.NewFeedDiscoveryParser(
+		parsers.ResourceArgs{Follow: true})
+	if err != nil {
+		panic(err)
+	}
+	router := analyzer.NewParserRouter()
+	routes := []analyzer.Route{
+		{
+			Name:      "link",
+			Parser:    linkParser,
+			MimeTypes: []string{"text/html", "application/xhtml+xml"},
+		},
+		{
+			Name:      "pagination",
+			Parser:    paginationParser,
+			MimeTypes: []string{"text/html", "application/xhtml+xml"},
+		},
+		{
+			Name:      "feed-discovery",
+			Parser:    feedDiscoveryParser,
+			MimeTypes: []string{"text/html", "application/xhtml+xml"},
+		},
+		{
+			Name:   "feed",
+			Parser: feedParser,
+			MimeTypes: []string{"application/rss+xml", "application/atom+xml",
+				"application/rdf+xml", "application/xml", "text/xml"},
+		},
+		{
+			Name:      "sitemap",
+			Parser:    analyzer.ParseForSitemap,
+			UrlRegexp: `(?i)/(robots\.txt|[^/]*sitemap[^/]*)$`,
+		},
+	}
+	for _, route := range routes {
+		if err := router.Add(route); err != nil {
+			panic(err)
 		}
-	})
-	return dataList, errs
-}
-
-func getRespParsers() []analyzer.ParseResponse {
-	parsers := []analyzer.ParseResponse{
-		parseForATag,
 	}
-	return parsers
+	// 每次爬取使用单独的指纹索引。
+	nearDupFilter, err := analyzer.NewNearDupFilter(
+		analyzer.DefaultNearDupArgs(), analyzer.NewFingerprintIndex())
+	if err != nil {
+		panic(err)
+	}
+	// 设置Targets（如[]string{"zh", "en"}）可以只跟随目标语言网页中的链接。
+	languageFilter, err := analyzer.NewLanguageFilter(analyzer.DefaultLanguageArgs())
+	if err != nil {
+		panic(err)
+	}
+	respParsers := []analyzer.ParseResponse{
+		nearDupFilter.Wrap(languageFilter.Wrap(robotsFilter.Wrap(router.Parse))),
+	}
+	return respParsers
 }
 
 func processItem(item base.Item) (result base.Item, err error) {
@@ -125,9 +190,44 @@ func processItem(item base.Item) (result base.Item, err error) {
 
 }
 
-func getItemProcessors() []itempipeline.ProcessItem {
-	itemp := []itempipeline.ProcessItem{
-		processItem,
+func getItemFlow(itemSink itempipeline.ItemSink) itempipeline.ItemFlow {
+	// 分析器生成的每个条目都带有被解析的网页的网址。
+	validator, err := itempipeline.NewItemValidator(itempipeline.ValidatorArgs{
+		Fields: []itempipeline.FieldSchema{
+			{Name: "parent_url", Type: itempipeline.COLUMN_STRING, Required: true, Pattern: `^https?://`},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	// 同一个链接会在包含它的每个网页中被重复生成条目。
+	// 设置Merge和Output可以把它们合并为一个条目，并在sources字段中记录所有来源网页。
+	dedupArgs := itempipeline.DefaultDedupArgs()
+	dedupArgs.Fields = []string{"link.url"}
+	dedupProcessor, err := itempipeline.NewDedupProcessor(dedupArgs, itempipeline.NewMemoryKeySet())
+	if err != nil {
+		panic(err)
+	}
+	// 调用外部系统的条目处理器可以再用WithRetry和NewCircuitBreaker加上重试和熔断。
+	// 所有条目都会先被校验，链接条目在去重后才会被存储。
+	// 在路由的Sinks中追加其他条目存储器即可把条目并行地写入多处。
+	itemFlow := itempipeline.ItemFlow{
+		Stages: []itempipeline.Stage{
+			{Name: "validate", Processor: validator.Process},
+			{Name: "process", Processor: itempipeline.WithTimeout(processItem, time.Second)},
+		},
+		Routes: []itempipeline.Route{
+			{
+				Name:   "link",
+				Types:  []string{"link"},
+				Stages: []itempipeline.Stage{{Name: "dedup", Processor: dedupProcessor.Process}},
+				Sinks:  []itempipeline.Stage{{Name: "store", Processor: itemSink.Process}},
+			},
+			{
+				Name:  "default",
+				Sinks: []itempipeline.Stage{{Name: "store", Processor: itemSink.Process}},
+			},
+		},
 	}
-	return itemp
+	return itemFlow
 }
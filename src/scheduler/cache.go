@@ -3,6 +3,7 @@ package scheduler
 import (
 	"base"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -16,7 +17,7 @@ var statusMap = map[byte]string{
 type requestCache interface {
 	// 将请求放入请求缓存。
 	put(req *base.Request) bool
-	// 从请求缓存获取最早被放入且仍在其中的请求。
+	// 从请求缓存获取优先级最高且最早被放入的请求。
 	get() *base.Request
 	// 获得请求缓存的容量。
 	capacity() int
@@ -52,7 +53,13 @@ func (rcache *reqCacheBySlice) put(req *base.Request) bool {
 	}
 	rcache.mutex.Lock()
 	defer rcache.mutex.Unlock()
-	rcache.cache = append(rcache.cache, req)
+	// 缓存中的请求按优先级从高到低排列，优先级相同的请求保持放入的顺序。
+	index := sort.Search(len(rcache.cache), func(i int) bool {
+		return rcache.cache[i].Priority() < req.Priority()
+	})
+	rcache.cache = append(rcache.cache, nil)
+	copy(rcache.cache[index+1:], rcache.cache[index:])
+	rcache.cache[index] = req
 	return true
 }
 
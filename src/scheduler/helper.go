@@ -8,8 +8,11 @@ import (
 	"fmt"
 	"itempipeline"
 	"middleware"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
+	"time"
 )
 
 func generateChannelManager(channalArgs base.ChannelArgs) middleware.ChannelManager {
@@ -26,9 +29,9 @@ func generatePageDownloaderPool(poolSize uint32, gen GenHttpClient) (downloader.
 	return dlPool, err
 }
 
-func generateAnalyzerPool(poolSize uint32) (analyzer.AnalyzerPool, error) {
+func generateAnalyzerPool(poolSize uint32, parserTimeout time.Duration) (analyzer.AnalyzerPool, error) {
 	aPool, err := analyzer.NewAnalyzerPool(poolSize, func() analyzer.Analyzer {
-		return analyzer.NewAnalyzer()
+		return analyzer.NewAnalyzerWithTimeout(parserTimeout)
 	})
 	if err != nil {
 		return nil, err
@@ -36,8 +39,31 @@ func generateAnalyzerPool(poolSize uint32) (analyzer.AnalyzerPool, error) {
 	return aPool, err
 }
 
-func generateItemPipeline(itemProcessors []itempipeline.ProcessItem) itempipeline.Itempipeline {
-	return itempipeline.NewItempipeline(itemProcessors)
+func generateItemPipeline(itemFlow itempipeline.ItemFlow, closers []itempipeline.Closer) (itempipeline.Itempipeline, error) {
+	return itempipeline.NewFlowItempipeline(itemFlow, closers)
+}
+
+// 生成用于发现站点地图的种子请求，即针对首次请求所在站点的robots.txt和/sitemap.xml的请求。
+// 这些请求应与analyzer.ParseForSitemap配合使用。
+func GenSitemapSeeds(firstHttpReq *http.Request) ([]*base.Request, error) {
+	if firstHttpReq == nil || firstHttpReq.URL == nil {
+		return nil, errors.New("The first http request is invalid!")
+	}
+	seeds := make([]*base.Request, 0, 2)
+	for _, path := range []string{"/robots.txt", "/sitemap.xml"} {
+		seedUrl := url.URL{
+			Scheme: firstHttpReq.URL.Scheme,
+			Host:   firstHttpReq.URL.Host,
+			Path:   path,
+		}
+		httpReq, err := http.NewRequest("GET", seedUrl.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds,
+			base.NewRequestWithPriority(httpReq, 0, analyzer.SITEMAP_REQUEST_PRIORITY))
+	}
+	return seeds, nil
 }
 
 var regexpForIp = regexp.MustCompile(`((?:(?:25[0-5]|2[0-4]\d|[01]?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|[01]?\d?\d))`)
@@ -33,14 +33,15 @@ type Scheduler interface {
 	// 参数crawlDepth代表了需要被爬取的网页的最大深度值。深度大于此值的网页会被忽略。
 	// 参数httpClientGenerator代表的是被用来生成HTTP客户端的函数。
 	// 参数respParsers的值应为分析器所需的被用来解析HTTP响应的函数的序列。
-	// 参数itemProcessors的值应为需要被置入条目处理管道中的条目处理器的序列。
+	// 参数itemFlow代表条目处理流程，包括公共的处理阶段、按条目类型选择的路由及其并行的接收者。
+	// 简单的条目处理器序列可以用itempipeline.LinearFlow转换为条目处理流程。
 	// 参数firstHttpReq即代表首次请求。调度器会以此为起始点开始执行爬取流程。
 	Start(channelArgs base.ChannelArgs,
 		poolBaseArgs base.PoolBaseArgs,
 		crawDepth uint32,
 		httpClientGenerator GenHttpClient,
 		respParsers []analyzer.ParseResponse,
-		itemProcessors []itempipeline.ProcessItem,
+		itemFlow itempipeline.ItemFlow,
 		firstHttpReq *http.Request,
 	) (err error)
 	// 调用该方法会停止调度器的运行。所有处理模块执行的流程都会被中止。
@@ -54,6 +55,20 @@ type Scheduler interface {
 	Idle() bool
 	// 获取摘要信息。
 	Summary(prefix string) SchedSummary
+	// 追加种子请求。种子请求与分析器生成的请求一样受主域名、爬取深度等条件的约束。
+	// 若调度器尚未开启，则种子请求会在开启时随首次请求一并被放入请求缓存。
+	// 结果值代表被接受的种子请求的数量。
+	Seed(seeds ...*base.Request) int
+	// 追加需要在调度器停止时被关闭的组件，如条目存储器。应在开启调度器之前调用。
+	// 调度器停止后，它们会在已进入条目处理管道的条目都被处理完毕后依次被关闭，且只会被关闭一次。
+	AddCloser(closers ...itempipeline.Closer)
+	// 设置死信存储器。应在开启调度器之前调用。
+	// 处理失败的条目会被存入其中，它会在条目处理管道关闭时被关闭。
+	SetDeadLetterStore(store itempipeline.DeadLetterStore)
+	// 设置排空条目处理管道的时限。调度器停止后，条目处理管道会在该时限内处理完剩余的条目，
+	// 超时后正在被处理的条目会被取消，剩余的条目会被视为处理失败并被存入死信存储器（若已设置）。
+	// 为0（默认值）表示停止时立即取消。
+	SetDrainTimeout(timeout time.Duration)
 }
 
 type GenHttpClient func() *http.Client
@@ -71,6 +86,12 @@ type myScheduler struct {
 	running       uint32                        //0表示未运行，1表示已运行，2表示已停止
 	reqCache      requestCache                  //请求缓存
 	urlMap        map[string]bool               //已请求的url字典
+	pendingSeeds  []*base.Request               //等待调度器开启的种子请求
+	seedMutex     sync.Mutex                    //针对种子请求的互斥锁
+	closers       []itempipeline.Closer         //需要在停止时被关闭的组件
+	closerMutex   sync.Mutex                    //针对需要关闭的组件的互斥锁
+	deadLetters   itempipeline.DeadLetterStore  //等待调度器开启的死信存储器
+	drainTimeout  int64                         //排空条目处理管道的时限（纳秒）
 	wg            sync.WaitGroup
 }
 
@@ -84,7 +105,7 @@ func (sched *myScheduler) Start(
 	crawDepth uint32,
 	httpClientGenerator GenHttpClient,
 	respParsers []analyzer.ParseResponse,
-	itemProcessors []itempipeline.ProcessItem,
+	itemFlow itempipeline.ItemFlow,
 	firstHttpReq *http.Request) (err error) {
 	defer func() {
 		if p := recover(); p != nil {
@@ -116,21 +137,29 @@ func (sched *myScheduler) Start(
 		return errors.New(errMsg)
 	}
 	sched.dlpool = dlPool
-	analyzerPool, err := generateAnalyzerPool(sched.poolBaseArgs.AnalyzerPoolSize())
+	analyzerPool, err := generateAnalyzerPool(
+		sched.poolBaseArgs.AnalyzerPoolSize(), sched.poolBaseArgs.ParserTimeout())
 	if err != nil {
 		errMsg := fmt.Sprintf("Occur error shen get analyzer pool: %s\n", err)
 		return errors.New(errMsg)
 	}
 	sched.analyzerPool = analyzerPool
-	if itemProcessors == nil {
-		return errors.New("THe item processor list is invalid")
+	if err := itemFlow.Check(); err != nil {
+		return errors.New(fmt.Sprintf("The item flow is invalid: %s", err))
+	}
+	sched.closerMutex.Lock()
+	closers := sched.closers
+	sched.closers = nil
+	deadLetters := sched.deadLetters
+	sched.deadLetters = nil
+	sched.closerMutex.Unlock()
+	sched.itemPipeline, err = generateItemPipeline(itemFlow, closers)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Occur error when create the item pipeline: %s", err))
 	}
-	for i, ip := range itemProcessors {
-		if ip == nil {
-			return errors.New(fmt.Sprintf("The %dth item processor is invalid", i))
-		}
+	if deadLetters != nil {
+		sched.itemPipeline.SetDeadLetterStore(deadLetters)
 	}
-	sched.itemPipeline = generateItemPipeline(itemProcessors)
 	if sched.stopSign == nil {
 		sched.stopSign = middleware.NewStopSign()
 	} else {
@@ -138,12 +167,6 @@ func (sched *myScheduler) Start(
 	}
 	sched.urlMap = make(map[string]bool)
 	sched.reqCache = newRequestCache()
-	sched.wg.Add(4)
-	sched.startDownloading()
-	sched.activateAnalyzers(respParsers)
-	sched.openItemPipeline()
-	sched.schedule(10 * time.Millisecond)
-	//
 	if firstHttpReq == nil {
 		return errors.New("The first http request is invalid")
 	}
@@ -151,9 +174,23 @@ func (sched *myScheduler) Start(
 	if err != nil {
 		return err
 	}
-	sched.primaryDomain = pd
 	firstReq := base.NewRequest(firstHttpReq, 0)
 	sched.reqCache.put(firstReq)
+	sched.urlMap[firstReq.Key()] = true
+	// 主域名在持有种子请求的互斥锁时被设置，且早于各个处理模块的启动，
+	// 因此Seed和处理模块中的请求过滤都不会与之竞争。
+	sched.seedMutex.Lock()
+	sched.primaryDomain = pd
+	for _, seed := range sched.pendingSeeds {
+		sched.saveReqToCache(*seed, SCHEDULER_CODE)
+	}
+	sched.pendingSeeds = nil
+	sched.seedMutex.Unlock()
+	sched.wg.Add(4)
+	sched.startDownloading()
+	sched.activateAnalyzers(respParsers)
+	sched.openItemPipeline()
+	sched.schedule(10 * time.Millisecond)
 	sched.wg.Wait()
 	return nil
 }
@@ -257,7 +294,11 @@ func (sched *myScheduler) sendError(err error, code string) bool {
 	case ITEMPIPELINE_CODE:
 		errorType = base.ITEM_PROCESSOR_ERROR
 	}
-	cError := base.NewCrawlerError(errorType, err.Error())
+	// 已是爬虫错误的错误（如携带响应解析函数序号的分析器错误）不再重复包装。
+	cError, ok := err.(base.CrawlerError)
+	if !ok {
+		cError = base.NewCrawlerError(errorType, err.Error())
+	}
 	if sched.stopSign.Signed() {
 		sched.stopSign.Deal(code)
 		return false
@@ -358,7 +399,7 @@ func (sched *myScheduler) saveReqToCache(req base.Request, code string) bool {
 		return false
 	}
 
-	if _, ok := sched.urlMap[reqUrl.String()]; ok {
+	if _, ok := sched.urlMap[req.Key()]; ok {
 		logger.Warnf("Ignore the request! it's url is repeated.(requestUrl=%s)\n", reqUrl)
 		return false
 	}
@@ -375,7 +416,7 @@ func (sched *myScheduler) saveReqToCache(req base.Request, code string) bool {
 		return false
 	}
 	sched.reqCache.put(&req)
-	sched.urlMap[reqUrl.String()] = true
+	sched.urlMap[req.Key()] = true
 	return true
 }
 
@@ -384,21 +425,26 @@ func (sched *myScheduler) openItemPipeline() {
 		defer sched.wg.Done()
 		sched.itemPipeline.SetFailFsat(true)
 		code := ITEMPIPELINE_CODE
+		err := sched.itemPipeline.StartWorkers(
+			sched.poolBaseArgs.ItemWorkerPoolSize(),
+			sched.poolBaseArgs.ItemQueueLen(),
+			func(err error) {
+				sched.sendError(err, code)
+			})
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Fatal item pipeline error: %s\n", err))
+			return
+		}
+		// 队列已满时Submit会阻塞，条目通道随之被填满，从而使分析器放缓。
 		for item := range sched.getItemChan() {
-			go func(item base.Item) {
-				defer func() {
-					if p := recover(); p != nil {
-						errMsg := fmt.Sprintf("Fatal Item processing error:%s\n", p)
-						logger.Fatal(errMsg)
-					}
-				}()
-				errs := sched.itemPipeline.Send(item)
-				if errs != nil {
-					for _, err := range errs {
-						sched.sendError(err, code)
-					}
-				}
-			}(item)
+			if err := sched.itemPipeline.Submit(item); err != nil {
+				sched.sendError(err, code)
+			}
+		}
+		// 条目通道在调度器停止时被关闭。关闭条目处理管道时会在时限内等待剩余的条目处理完毕。
+		drainTimeout := time.Duration(atomic.LoadInt64(&sched.drainTimeout))
+		for _, err := range sched.itemPipeline.CloseWithin(drainTimeout) {
+			logger.Errorf("Item pipeline closing error: %s\n", err)
 		}
 	}()
 }
@@ -458,6 +504,46 @@ func (sched *myScheduler) Idle() bool {
 	return false
 }
 
+func (sched *myScheduler) Seed(seeds ...*base.Request) int {
+	var count int
+	sched.seedMutex.Lock()
+	defer sched.seedMutex.Unlock()
+	for _, seed := range seeds {
+		if seed == nil || !seed.Valid() {
+			continue
+		}
+		if sched.primaryDomain == "" {
+			sched.pendingSeeds = append(sched.pendingSeeds, seed)
+			count++
+			continue
+		}
+		if sched.saveReqToCache(*seed, SCHEDULER_CODE) {
+			count++
+		}
+	}
+	return count
+}
+
+func (sched *myScheduler) AddCloser(closers ...itempipeline.Closer) {
+	sched.closerMutex.Lock()
+	defer sched.closerMutex.Unlock()
+	for _, closer := range closers {
+		if closer != nil {
+			sched.closers = append(sched.closers, closer)
+		}
+	}
+}
+
+func (sched *myScheduler) SetDrainTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&sched.drainTimeout, int64(timeout))
+}
+
+func (sched *myScheduler) SetDeadLetterStore(store itempipeline.DeadLetterStore) {
+	sched.closerMutex.Lock()
+	defer sched.closerMutex.Unlock()
+	sched.deadLetters = store
+}
+
 func (sched *myScheduler) Summary(prefix string) SchedSummary {
 	return NewSchedSummary(sched, prefix)
 }